@@ -16,9 +16,16 @@ package api
 
 import (
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"math"
 
 	"github.com/blockcypher/libgrin/v5/core"
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/core/pmmr"
+	"github.com/blockcypher/libgrin/v5/core/pow"
+	"github.com/blockcypher/libgrin/v5/util/secp"
 )
 
 // BlockPrintable is the result of the Grin block API
@@ -29,6 +36,39 @@ type BlockPrintable struct {
 	Kernels []TxKernelsPrintables `json:"kernels"`
 }
 
+// ToBlock converts the printable block to a core.Block, which can be
+// validated. The outputs must include their range proofs. The features of the
+// inputs are not printed, the inputs are plain inputs of the commitments, as
+// serialized from protocol version 3.
+func (b *BlockPrintable) ToBlock() (core.Block, error) {
+	block := core.Block{
+		Header: b.Header.ToBlockHeader(),
+		Body: core.TransactionBody{
+			Inputs:  make([]core.Input, len(b.Inputs)),
+			Outputs: make([]core.Output, len(b.Outputs)),
+			Kernels: make([]core.TxKernel, len(b.Kernels)),
+		},
+	}
+	for i, commit := range b.Inputs {
+		block.Body.Inputs[i] = core.Input{Features: core.PlainOutput, Commit: commit}
+	}
+	for i := range b.Outputs {
+		output, err := b.Outputs[i].ToOutput()
+		if err != nil {
+			return core.Block{}, err
+		}
+		block.Body.Outputs[i] = output
+	}
+	for i := range b.Kernels {
+		kernel, err := b.Kernels[i].ToTxKernel()
+		if err != nil {
+			return core.Block{}, err
+		}
+		block.Body.Kernels[i] = kernel
+	}
+	return block, nil
+}
+
 // BlockHeaderPrintable is the header of the BlockPrintable
 type BlockHeaderPrintable struct {
 	// Hash
@@ -45,10 +85,14 @@ type BlockHeaderPrintable struct {
 	Timestamp string `json:"timestamp"`
 	// Merklish root of all the commitments in the TxHashSet
 	OutputRoot string `json:"output_root"`
+	// Size of the output MMR
+	OutputMmrSize uint64 `json:"output_mmr_size"`
 	// Merklish root of all range proofs in the TxHashSet
 	RangeProofRoot string `json:"range_proof_root"`
 	// Merklish root of all transaction kernels in the TxHashSet
 	KernelRoot string `json:"kernel_root"`
+	// Size of the kernel MMR
+	KernelMmrSize uint64 `json:"kernel_mmr_size"`
 	// Nonce increment used to mine this block.
 	Nonce uint64 `json:"nonce"`
 	// Size of the cuckoo graph
@@ -63,6 +107,55 @@ type BlockHeaderPrintable struct {
 	TotalKernelOffset string `json:"total_kernel_offset"`
 }
 
+// ToBlockHeader converts the printable header to a core.BlockHeader, which
+// can be hashed and have its proof of work verified
+func (h *BlockHeaderPrintable) ToBlockHeader() core.BlockHeader {
+	return core.BlockHeader{
+		Version:           h.Version,
+		Height:            h.Height,
+		PrevHash:          h.Previous,
+		PrevRoot:          h.PrevRoot,
+		Timestamp:         h.Timestamp,
+		OutputRoot:        h.OutputRoot,
+		RangeProofRoot:    h.RangeProofRoot,
+		KernelRoot:        h.KernelRoot,
+		TotalKernelOffset: h.TotalKernelOffset,
+		OutputMmrSize:     h.OutputMmrSize,
+		KernelMmrSize:     h.KernelMmrSize,
+		PoW: pow.ProofOfWork{
+			TotalDifficulty:  h.TotalDifficulty,
+			SecondaryScaling: uint32(h.SecondaryScaling),
+			Nonce:            h.Nonce,
+			Proof: pow.Proof{
+				EdgeBits: h.EdgeBits,
+				Nonces:   h.CuckooSolution,
+			},
+		},
+	}
+}
+
+// NewVerifier creates the verifier of the proof of work of the header, from
+// its version, edge bits and pre-PoW
+func (h *BlockHeaderPrintable) NewVerifier(chainType consensus.ChainType) (pow.PowContext, error) {
+	header := h.ToBlockHeader()
+	prePoW, err := header.PrePoW()
+	if err != nil {
+		return nil, err
+	}
+	return pow.NewVerifier(chainType, h.Version, h.EdgeBits, prePoW)
+}
+
+// VerifyPoW verifies the cuckoo solution of the header. Invalid solutions
+// return the verification errors of the pow package, such as
+// pow.ErrBranchInCycle.
+func (h *BlockHeaderPrintable) VerifyPoW(chainType consensus.ChainType) error {
+	verifier, err := h.NewVerifier(chainType)
+	if err != nil {
+		return err
+	}
+	return verifier.Verify(pow.NewProof(h.EdgeBits, h.CuckooSolution))
+}
+
 // OutputPrintable represents the output of a block
 type OutputPrintable struct {
 	// The type of output Coinbase|Transaction
@@ -84,15 +177,120 @@ type OutputPrintable struct {
 	MMRIndex uint64 `json:"mmr_index"`
 }
 
+// Output features of the output type
+func (o *OutputPrintable) features() core.OutputFeatures {
+	if o.OutputType == CoinbaseOutputType {
+		return core.CoinbaseOutput
+	}
+	return core.PlainOutput
+}
+
+// ToOutput converts the printable output to an output, the range proof must
+// have been included
+func (o *OutputPrintable) ToOutput() (core.Output, error) {
+	if o.Proof == nil {
+		return core.Output{}, errors.New("missing range proof")
+	}
+	return core.Output{Features: o.features(), Commit: o.Commit, Proof: *o.Proof}, nil
+}
+
+// VerifyMerkleProof verifies that the output is in the output MMR whose root
+// the header commits to, the Merkle proof must have been included. The node
+// only includes it for unspent coinbase outputs, against the header of their
+// block.
+func (o *OutputPrintable) VerifyMerkleProof(header *BlockHeaderPrintable) error {
+	if o.MerkleProof == nil {
+		return errors.New("missing merkle proof")
+	}
+	if o.MMRIndex == 0 {
+		return errors.New("missing mmr index")
+	}
+	proof, err := pmmr.MerkleProofFromHex(*o.MerkleProof)
+	if err != nil {
+		return err
+	}
+	root, err := pmmr.HashFromHex(header.OutputRoot)
+	if err != nil {
+		return err
+	}
+	id := core.OutputIdentifier{Features: o.features(), Commit: o.Commit}
+	// The MMR index is 1-based
+	return proof.Verify(root, &id, o.MMRIndex-1)
+}
+
 // TxKernelsPrintables is the tx kernel
 type TxKernelsPrintables struct {
 	Features   string `json:"features"`
+	FeeShift   uint8  `json:"fee_shift"`
 	Fee        uint64 `json:"fee"`
 	LockHeight uint64 `json:"lock_height"`
 	Excess     string `json:"excess"`
 	ExcessSig  string `json:"excess_sig"`
 }
 
+// NewTxKernelsPrintables converts a kernel to its printable representation,
+// the relative height of no recent duplicate kernels is printed as the lock
+// height and the excess signature is printed in its raw form
+func NewTxKernelsPrintables(kernel core.TxKernel) (TxKernelsPrintables, error) {
+	sig, err := secp.SignatureFromCompactHex(kernel.ExcessSig)
+	if err != nil {
+		return TxKernelsPrintables{}, err
+	}
+	feeFields := kernel.FeeFields()
+	printable := TxKernelsPrintables{
+		Features:  kernel.Features.String(),
+		FeeShift:  feeFields.FeeShift(),
+		Fee:       feeFields.Fee(),
+		Excess:    kernel.Excess,
+		ExcessSig: sig.String(),
+	}
+	switch kernel.Features {
+	case core.HeightLockedKernel:
+		printable.LockHeight = uint64(kernel.LockHeight)
+	case core.NoRecentDuplicateKernel:
+		printable.LockHeight = uint64(kernel.RelativeHeight)
+	}
+	return printable, nil
+}
+
+// ToTxKernel converts the printable kernel to a kernel, with the excess
+// signature in its compact form
+func (k *TxKernelsPrintables) ToTxKernel() (core.TxKernel, error) {
+	features, err := core.ParseKernelFeatures(k.Features)
+	if err != nil {
+		return core.TxKernel{}, err
+	}
+	rawSig, err := hex.DecodeString(k.ExcessSig)
+	if err != nil {
+		return core.TxKernel{}, err
+	}
+	sig, err := secp.SignatureFromRaw(rawSig)
+	if err != nil {
+		return core.TxKernel{}, err
+	}
+	kernel := core.TxKernel{Features: features, Excess: k.Excess, ExcessSig: sig.CompactHex()}
+	if features == core.CoinbaseKernel {
+		return kernel, nil
+	}
+	if k.Fee != 0 || k.FeeShift != 0 {
+		feeFields, err := core.NewFeeFields(k.FeeShift, k.Fee)
+		if err != nil {
+			return core.TxKernel{}, err
+		}
+		kernel.Fee = core.Uint64(feeFields)
+	}
+	switch features {
+	case core.HeightLockedKernel:
+		kernel.LockHeight = core.Uint64(k.LockHeight)
+	case core.NoRecentDuplicateKernel:
+		if k.LockHeight > math.MaxUint16 {
+			return core.TxKernel{}, errors.New("invalid relative height")
+		}
+		kernel.RelativeHeight = uint16(k.LockHeight)
+	}
+	return kernel, nil
+}
+
 // The Status represents various statistics about the network
 type Status struct {
 	ProtocolVersion uint32  `json:"protocol_version"`
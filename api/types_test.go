@@ -18,6 +18,10 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/blockcypher/libgrin/v5/core"
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/core/pmmr"
+	"github.com/blockcypher/libgrin/v5/core/pow"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -46,3 +50,176 @@ func TestMarshalOutputType(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, string(transactionb), "\"Transaction\"")
 }
+
+func TestBlockHeaderPrintableToBlockHeader(t *testing.T) {
+	// Mainnet genesis header as returned by get_header
+	headerb := []byte(`{
+		"hash": "40adad0aec27797b48840aa9e00472015c21baea118ce7a2ff1a82c0f8f5bf82",
+		"version": 1,
+		"height": 0,
+		"previous": "0000000000000000000000000000000000000000000000000000000000000000",
+		"prev_root": "0000000000000000002a8bc32f43277fe9c063b9c99ea252b483941dcd06e217",
+		"timestamp": "2019-01-15T16:01:26+00:00",
+		"output_root": "fa7566d275006c6c467876758f2bc87e4cebd2020ae9cf9f294c6217828d6872",
+		"output_mmr_size": 1,
+		"range_proof_root": "1b7fff259aee3edfb5867c4775e4e1717826b843cda6685e5140442ece7bfc2e",
+		"kernel_root": "e8bb096a73cbe6e099968965f5342fc1702ee2802802902286dcf0f279e326bf",
+		"kernel_mmr_size": 1,
+		"nonce": 41,
+		"edge_bits": 29,
+		"cuckoo_solution": [4391451, 36730677, 38198400, 38797304, 60700446, 72910191, 73050441,
+			110099816, 140885802, 145512513, 149311222, 149994636, 157557529, 160778700,
+			162870981, 179649435, 194194460, 227378628, 230933064, 252046196, 272053956,
+			277878683, 288331253, 290266880, 293973036, 305315023, 321927758, 353841539,
+			356489212, 373843111, 381697287, 389274717, 403108317, 409994705, 411629694,
+			431823422, 441976653, 521469643, 521868369, 523044572, 524964447, 530250249],
+		"total_difficulty": 17179869184,
+		"secondary_scaling": 1856,
+		"total_kernel_offset": "0000000000000000000000000000000000000000000000000000000000000000"
+	}`)
+	var printable BlockHeaderPrintable
+	assert.NoError(t, json.Unmarshal(headerb, &printable))
+	header := printable.ToBlockHeader()
+	assert.Equal(t, uint64(1), header.OutputMmrSize)
+	assert.Equal(t, uint32(1856), header.PoW.SecondaryScaling)
+
+	hash, err := header.Hash()
+	assert.NoError(t, err)
+	assert.Equal(t, printable.Hash, hash)
+	assert.NoError(t, header.VerifyPoW(consensus.Mainnet))
+
+	// The printable header is verified without going through core
+	assert.NoError(t, printable.VerifyPoW(consensus.Mainnet))
+	verifier, err := printable.NewVerifier(consensus.Mainnet)
+	assert.NoError(t, err)
+	assert.IsType(t, &pow.CuckarooContext{}, verifier)
+	solution := append([]uint64{}, printable.CuckooSolution...)
+	printable.CuckooSolution[0], printable.CuckooSolution[1] = solution[1], solution[0]
+	assert.Equal(t, pow.ErrEdgesNotAscending, printable.VerifyPoW(consensus.Mainnet))
+	printable.CuckooSolution = solution[1:]
+	assert.Equal(t, pow.ErrWrongCycleLength, printable.VerifyPoW(consensus.Mainnet))
+	printable.CuckooSolution = solution
+	printable.Version = 9
+	assert.Equal(t, pow.ErrUnknownHeaderVersion, printable.VerifyPoW(consensus.Mainnet))
+}
+
+// Mainnet genesis kernel excess signature, compact and raw
+const (
+	genesisKernelExcess = "096385d86c5cfda718aa0b7295be0adf7e5ac051edfe130593a2a257f09f78a3b1"
+	genesisKernelSig    = "142a6a482a0c64ba71ba216ba5361612696fc76fe8d5c03c79fae01cab29d050ed7567e9a6d4fbbc1b6c0bf4434b8450dafa3f29b31612fda815f6e4b2bcfd43"
+	genesisKernelSigRaw = "50d029ab1ce0fa793cc0d5e86fc76f69121636a56b21ba71ba640c2a486a2a1443fdbcb2e4f615a8fd1216b3293ffada50844b43f40b6c1bbcfbd4a6e96775ed"
+)
+
+func TestTxKernelsPrintables(t *testing.T) {
+	feeFields, err := core.NewFeeFields(1, 7000000)
+	assert.NoError(t, err)
+	kernels := []core.TxKernel{
+		{Features: core.PlainKernel, Fee: core.Uint64(feeFields), Excess: "08b6", ExcessSig: genesisKernelSig},
+		{Features: core.CoinbaseKernel, Excess: genesisKernelExcess, ExcessSig: genesisKernelSig},
+		{Features: core.HeightLockedKernel, Fee: 8000000, LockHeight: 10, Excess: "09b6", ExcessSig: genesisKernelSig},
+		{Features: core.NoRecentDuplicateKernel, Fee: 9000000, RelativeHeight: 1440, Excess: "0ab6", ExcessSig: genesisKernelSig},
+	}
+	printables := []TxKernelsPrintables{
+		{Features: "Plain", FeeShift: 1, Fee: 7000000, Excess: "08b6", ExcessSig: genesisKernelSigRaw},
+		{Features: "Coinbase", Excess: genesisKernelExcess, ExcessSig: genesisKernelSigRaw},
+		{Features: "HeightLocked", Fee: 8000000, LockHeight: 10, Excess: "09b6", ExcessSig: genesisKernelSigRaw},
+		{Features: "NoRecentDuplicate", Fee: 9000000, LockHeight: 1440, Excess: "0ab6", ExcessSig: genesisKernelSigRaw},
+	}
+	for i, kernel := range kernels {
+		printable, err := NewTxKernelsPrintables(kernel)
+		assert.NoError(t, err)
+		assert.Equal(t, printables[i], printable)
+		converted, err := printables[i].ToTxKernel()
+		assert.NoError(t, err)
+		assert.Equal(t, kernel, converted)
+	}
+
+	// The genesis kernel from the node API verifies once converted
+	genesisKernel, err := printables[1].ToTxKernel()
+	assert.NoError(t, err)
+	assert.NoError(t, genesisKernel.VerifySignature())
+
+	_, err = NewTxKernelsPrintables(core.TxKernel{Features: core.PlainKernel, ExcessSig: "66"})
+	assert.Error(t, err)
+	_, err = (&TxKernelsPrintables{Features: "Unknown"}).ToTxKernel()
+	assert.Error(t, err)
+	_, err = (&TxKernelsPrintables{Features: "Plain", ExcessSig: "66"}).ToTxKernel()
+	assert.Error(t, err)
+	_, err = (&TxKernelsPrintables{Features: "NoRecentDuplicate", Fee: 1, LockHeight: 1 << 16, ExcessSig: genesisKernelSigRaw}).ToTxKernel()
+	assert.Error(t, err)
+}
+
+func TestBlockPrintableToBlock(t *testing.T) {
+	proof := "9330"
+	printable := BlockPrintable{
+		Header:  BlockHeaderPrintable{Version: 4, Height: 10, TotalKernelOffset: "0000000000000000000000000000000000000000000000000000000000000000"},
+		Inputs:  []string{"08b6"},
+		Outputs: []OutputPrintable{{OutputType: CoinbaseOutputType, Commit: "08b7", Proof: &proof}},
+		Kernels: []TxKernelsPrintables{{Features: "Coinbase", Excess: genesisKernelExcess, ExcessSig: genesisKernelSigRaw}},
+	}
+	block, err := printable.ToBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, printable.Header.ToBlockHeader(), block.Header)
+	assert.Equal(t, []core.Input{{Features: core.PlainOutput, Commit: "08b6"}}, block.Body.Inputs)
+	assert.Equal(t, []core.Output{{Features: core.CoinbaseOutput, Commit: "08b7", Proof: "9330"}}, block.Body.Outputs)
+	assert.Equal(t, []core.TxKernel{{Features: core.CoinbaseKernel, Excess: genesisKernelExcess, ExcessSig: genesisKernelSig}}, block.Body.Kernels)
+
+	printable.Outputs[0].Proof = nil
+	_, err = printable.ToBlock()
+	assert.Error(t, err)
+	printable.Outputs = nil
+	printable.Kernels[0].Features = "Unknown"
+	_, err = printable.ToBlock()
+	assert.Error(t, err)
+}
+
+func TestOutputPrintableToOutput(t *testing.T) {
+	proof := "9330"
+	printable := OutputPrintable{OutputType: CoinbaseOutputType, Commit: "08b7", Proof: &proof}
+	output, err := printable.ToOutput()
+	assert.NoError(t, err)
+	assert.Equal(t, core.Output{Features: core.CoinbaseOutput, Commit: "08b7", Proof: "9330"}, output)
+
+	printable.OutputType = TransactionOutputType
+	output, err = printable.ToOutput()
+	assert.NoError(t, err)
+	assert.Equal(t, core.PlainOutput, output.Features)
+
+	printable.Proof = nil
+	_, err = printable.ToOutput()
+	assert.Error(t, err)
+}
+
+func TestOutputPrintableVerifyMerkleProof(t *testing.T) {
+	commits := []string{
+		"08b7e57c448db5ef25aa119dde2312c64d7ff1b890c416c6dda5ec73cbfed2edea",
+		"09b7e57c448db5ef25aa119dde2312c64d7ff1b890c416c6dda5ec73cbfed2edea",
+		"0ab7e57c448db5ef25aa119dde2312c64d7ff1b890c416c6dda5ec73cbfed2edea",
+	}
+	var outputMMR pmmr.VecPMMR
+	var positions []uint64
+	for _, commit := range commits {
+		pos0, err := outputMMR.Push(&core.OutputIdentifier{Features: core.CoinbaseOutput, Commit: commit})
+		assert.NoError(t, err)
+		positions = append(positions, pos0)
+	}
+	header := BlockHeaderPrintable{OutputRoot: outputMMR.Root().String(), OutputMmrSize: outputMMR.Size()}
+
+	proof, err := outputMMR.MerkleProof(positions[1])
+	assert.NoError(t, err)
+	proofHex, err := proof.Hex()
+	assert.NoError(t, err)
+	printable := OutputPrintable{OutputType: CoinbaseOutputType, Commit: commits[1], MerkleProof: &proofHex, MMRIndex: positions[1] + 1}
+	assert.NoError(t, printable.VerifyMerkleProof(&header))
+
+	// The features are part of the leaf
+	printable.OutputType = TransactionOutputType
+	assert.Equal(t, pmmr.ErrRootMismatch, printable.VerifyMerkleProof(&header))
+	printable.OutputType = CoinbaseOutputType
+	printable.MMRIndex = positions[2] + 1
+	assert.Equal(t, pmmr.ErrRootMismatch, printable.VerifyMerkleProof(&header))
+	printable.MMRIndex = 0
+	assert.Error(t, printable.VerifyMerkleProof(&header))
+	printable.MerkleProof = nil
+	assert.Error(t, printable.VerifyMerkleProof(&header))
+}
@@ -24,6 +24,10 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// ErrKernelNotFound is returned by GetKernel when the kernel is not in the
+// chain between the min and max heights
+var ErrKernelNotFound = errors.New("NodeForeignAPI: kernel not found")
+
 // NodeForeignAPI represents the node foreign API (v2)
 type NodeForeignAPI struct {
 	client RPCHTTPClient
@@ -135,6 +139,10 @@ func (foreign *NodeForeignAPI) GetKernel(excess string, minHeight, maxHeight *ui
 		return nil, err
 	}
 	if result.Err != nil {
+		var kind string
+		if err := json.Unmarshal(result.Err, &kind); err == nil && kind == "NotFound" {
+			return nil, ErrKernelNotFound
+		}
 		return nil, errors.New(string(result.Err))
 	}
 	var locatedTxKernel api.LocatedTxKernel
@@ -403,7 +411,7 @@ func (foreign *NodeForeignAPI) GetVersion() (*api.Version, error) {
 
 // PushTransaction pushes a new transaction to our local transaction pool.
 func (foreign *NodeForeignAPI) PushTransaction(tx core.Transaction, fluff *bool) error {
-	arrayParams := [1]interface{}{tx}
+	arrayParams := [2]interface{}{tx, fluff}
 	paramsBytes, err := json.Marshal(arrayParams)
 	if err != nil {
 		return err
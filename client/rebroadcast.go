@@ -0,0 +1,357 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/blockcypher/libgrin/v5/api"
+	"github.com/blockcypher/libgrin/v5/core"
+	"github.com/blockcypher/libgrin/v5/libwallet"
+	"github.com/blockcypher/libgrin/v5/libwallet/slateversions"
+	"github.com/blockcypher/libgrin/v5/pool"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// RebroadcastNode is the subset of the node foreign API used by the
+// RebroadcastManager. It is implemented by NodeForeignAPI.
+type RebroadcastNode interface {
+	GetTip() (*api.Tip, error)
+	// Returns ErrKernelNotFound when the kernel is not in the chain
+	GetKernel(excess string, minHeight, maxHeight *uint64) (*api.LocatedTxKernel, error)
+	GetUnconfirmedTransactions() (*[]pool.PoolEntry, error)
+	PushTransaction(tx core.Transaction, fluff *bool) error
+}
+
+// RebroadcastWallet is the subset of the wallet owner API used by the
+// RebroadcastManager. It is implemented by WalletOwnerAPI.
+type RebroadcastWallet interface {
+	GetStoredTx(id *uint32, slateID *uuid.UUID) (*slateversions.SlateV4, error)
+	PostTx(slate slateversions.SlateV4, fluff bool) error
+	CancelTx(txID *uint32, txSlateID *uuid.UUID) error
+}
+
+var _ RebroadcastNode = (*NodeForeignAPI)(nil)
+var _ RebroadcastWallet = (*WalletOwnerAPI)(nil)
+
+// RebroadcastConfig is the configuration of a RebroadcastManager
+type RebroadcastConfig struct {
+	// Interval between two checks of the pending transactions when running
+	CheckInterval time.Duration
+	// Time a transaction can be missing from both the pool and the chain
+	// before being pushed again (fluffed)
+	RebroadcastDelay time.Duration
+	// Whether to cancel the transaction in the wallet once the TTL cutoff
+	// height has passed. Only applies to transactions with a wallet tx log entry.
+	CancelOnExpiry bool
+}
+
+// DefaultRebroadcastConfig is the default rebroadcast configuration
+var DefaultRebroadcastConfig = RebroadcastConfig{
+	CheckInterval:    time.Minute,
+	RebroadcastDelay: 10 * time.Minute,
+	CancelOnExpiry:   false,
+}
+
+// PendingTx is a finalized transaction tracked by the RebroadcastManager
+// until it is confirmed or expired.
+type PendingTx struct {
+	// Kernel excess used to look up the transaction in the pool and in the chain
+	KernelExcess string
+	// The transaction itself, nil for transactions stored in the wallet
+	Tx *core.Transaction
+	// Wallet tx log entry id, if the transaction is stored in the wallet
+	TxID *uint32
+	// Wallet slate id, if the transaction is stored in the wallet
+	TxSlateID *uuid.UUID
+	// Height from which the kernel is looked up in the chain
+	KernelLookupMinHeight *uint64
+	// TTL, the block height after which the transaction is abandoned
+	TTLCutoffHeight *uint64
+	// Last time the transaction was pushed or seen in the pool
+	LastSeen time.Time
+	// Number of times the transaction was pushed again
+	Rebroadcasts uint
+}
+
+// RebroadcastEventType is the type of a rebroadcast event
+type RebroadcastEventType int
+
+const (
+	// RebroadcastedEvent when a transaction was pushed again
+	RebroadcastedEvent RebroadcastEventType = iota
+	// ConfirmedEvent when the kernel of a transaction was found in the chain
+	ConfirmedEvent
+	// ExpiredEvent when the TTL cutoff height of a transaction has passed
+	ExpiredEvent
+	// CancelledEvent when an expired transaction was cancelled in the wallet
+	CancelledEvent
+)
+
+func (s RebroadcastEventType) String() string {
+	return toStringRebroadcastEventType[s]
+}
+
+var toStringRebroadcastEventType = map[RebroadcastEventType]string{
+	RebroadcastedEvent: "Rebroadcasted",
+	ConfirmedEvent:     "Confirmed",
+	ExpiredEvent:       "Expired",
+	CancelledEvent:     "Cancelled",
+}
+
+// RebroadcastEvent is something that happened to a pending transaction during a check
+type RebroadcastEvent struct {
+	// Event type
+	Type RebroadcastEventType
+	// Kernel excess of the pending transaction
+	KernelExcess string
+	// Error, if the action associated with the event failed
+	Err error
+}
+
+// RebroadcastManager holds finalized transactions and periodically checks that
+// they are either in the node pool or in the chain. Transactions that fell out of
+// the pool are pushed again with fluff until they are confirmed or their TTL
+// cutoff height is reached.
+type RebroadcastManager struct {
+	node   RebroadcastNode
+	wallet RebroadcastWallet
+	config RebroadcastConfig
+	now    func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]*PendingTx
+}
+
+// NewRebroadcastManager creates a new rebroadcast manager. The wallet is only
+// needed for transactions stored in the wallet and can be nil otherwise.
+func NewRebroadcastManager(node RebroadcastNode, wallet RebroadcastWallet, config RebroadcastConfig) *RebroadcastManager {
+	return &RebroadcastManager{
+		node:    node,
+		wallet:  wallet,
+		config:  config,
+		now:     time.Now,
+		pending: make(map[string]*PendingTx),
+	}
+}
+
+// AddTransaction tracks a finalized transaction. The transaction is identified
+// by its first kernel excess.
+func (m *RebroadcastManager) AddTransaction(tx core.Transaction, ttlCutoffHeight *uint64) error {
+	if len(tx.Body.Kernels) == 0 {
+		return errors.New("RebroadcastManager: transaction without kernel")
+	}
+	excess := tx.Body.Kernels[0].Excess
+	m.add(&PendingTx{
+		KernelExcess:    excess,
+		Tx:              &tx,
+		TTLCutoffHeight: ttlCutoffHeight,
+	})
+	return nil
+}
+
+// AddTxLogEntry tracks a transaction stored in the wallet. The stored slate is
+// retrieved with GetStoredTx and posted again through the wallet when needed.
+func (m *RebroadcastManager) AddTxLogEntry(entry libwallet.TxLogEntry) error {
+	if m.wallet == nil {
+		return errors.New("RebroadcastManager: no wallet to retrieve the stored transaction")
+	}
+	if entry.KernelExcess == nil {
+		return errors.New("RebroadcastManager: tx log entry without kernel excess")
+	}
+	id := entry.ID
+	pendingTx := &PendingTx{
+		KernelExcess: *entry.KernelExcess,
+		TxID:         &id,
+		TxSlateID:    entry.TxSlateID,
+	}
+	if entry.KernelLookupMinHeight != nil {
+		minHeight := uint64(*entry.KernelLookupMinHeight)
+		pendingTx.KernelLookupMinHeight = &minHeight
+	}
+	if entry.TTLCutoffHeight != nil {
+		ttl := uint64(*entry.TTLCutoffHeight)
+		pendingTx.TTLCutoffHeight = &ttl
+	}
+	m.add(pendingTx)
+	return nil
+}
+
+func (m *RebroadcastManager) add(pendingTx *PendingTx) {
+	pendingTx.LastSeen = m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending[pendingTx.KernelExcess] = pendingTx
+}
+
+// Remove stops tracking the transaction with the given kernel excess
+func (m *RebroadcastManager) Remove(kernelExcess string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pending, kernelExcess)
+}
+
+// Pending returns a copy of the transactions currently tracked
+func (m *RebroadcastManager) Pending() []PendingTx {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pendingTxs := make([]PendingTx, 0, len(m.pending))
+	for _, pendingTx := range m.pending {
+		pendingTxs = append(pendingTxs, *pendingTx)
+	}
+	return pendingTxs
+}
+
+// Check does a single pass over the pending transactions: confirmed ones are
+// dropped, expired ones are dropped (and optionally cancelled) and those missing
+// from the pool for longer than the rebroadcast delay are pushed again.
+func (m *RebroadcastManager) Check() ([]RebroadcastEvent, error) {
+	tip, err := m.node.GetTip()
+	if err != nil {
+		return nil, err
+	}
+	poolEntries, err := m.node.GetUnconfirmedTransactions()
+	if err != nil {
+		return nil, err
+	}
+	inPool := make(map[string]bool)
+	if poolEntries != nil {
+		for _, entry := range *poolEntries {
+			for _, kernel := range entry.Tx.Body.Kernels {
+				inPool[kernel.Excess] = true
+			}
+		}
+	}
+
+	var events []RebroadcastEvent
+	for _, pendingTx := range m.Pending() {
+		confirmed, err := m.isConfirmed(pendingTx)
+		if err != nil {
+			// Unknown state, the transaction may already be mined so it is
+			// neither expired nor pushed again until the next check
+			log.WithFields(log.Fields{
+				"kernel_excess": pendingTx.KernelExcess,
+				"error":         err,
+			}).Warn("RebroadcastManager: Error during kernel lookup")
+			continue
+		}
+		if confirmed {
+			m.Remove(pendingTx.KernelExcess)
+			events = append(events, RebroadcastEvent{Type: ConfirmedEvent, KernelExcess: pendingTx.KernelExcess})
+			continue
+		}
+		if pendingTx.TTLCutoffHeight != nil && tip.Height >= *pendingTx.TTLCutoffHeight {
+			m.Remove(pendingTx.KernelExcess)
+			events = append(events, RebroadcastEvent{Type: ExpiredEvent, KernelExcess: pendingTx.KernelExcess})
+			if m.config.CancelOnExpiry && m.wallet != nil && (pendingTx.TxID != nil || pendingTx.TxSlateID != nil) {
+				err := m.wallet.CancelTx(pendingTx.TxID, pendingTx.TxSlateID)
+				events = append(events, RebroadcastEvent{Type: CancelledEvent, KernelExcess: pendingTx.KernelExcess, Err: err})
+			}
+			continue
+		}
+		now := m.now()
+		if inPool[pendingTx.KernelExcess] {
+			m.touch(pendingTx.KernelExcess, now, false)
+			continue
+		}
+		if now.Sub(pendingTx.LastSeen) < m.config.RebroadcastDelay {
+			continue
+		}
+		err = m.rebroadcast(pendingTx)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"kernel_excess": pendingTx.KernelExcess,
+				"error":         err,
+			}).Warn("RebroadcastManager: Error during rebroadcast")
+		} else {
+			m.touch(pendingTx.KernelExcess, now, true)
+		}
+		events = append(events, RebroadcastEvent{Type: RebroadcastedEvent, KernelExcess: pendingTx.KernelExcess, Err: err})
+	}
+	return events, nil
+}
+
+// Run checks the pending transactions every CheckInterval until the context is done
+func (m *RebroadcastManager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			events, err := m.Check()
+			if err != nil {
+				log.WithFields(log.Fields{
+					"error": err,
+				}).Error("RebroadcastManager: Error during Check")
+				continue
+			}
+			for _, event := range events {
+				log.WithFields(log.Fields{
+					"event":         event.Type.String(),
+					"kernel_excess": event.KernelExcess,
+					"error":         event.Err,
+				}).Info("RebroadcastManager: pending transaction update")
+			}
+		}
+	}
+}
+
+// Only ErrKernelNotFound means the transaction is not confirmed, any other
+// kernel lookup error is returned
+func (m *RebroadcastManager) isConfirmed(pendingTx PendingTx) (bool, error) {
+	kernel, err := m.node.GetKernel(pendingTx.KernelExcess, pendingTx.KernelLookupMinHeight, nil)
+	if errors.Is(err, ErrKernelNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return kernel != nil, nil
+}
+
+func (m *RebroadcastManager) rebroadcast(pendingTx PendingTx) error {
+	if pendingTx.Tx != nil {
+		fluff := true
+		return m.node.PushTransaction(*pendingTx.Tx, &fluff)
+	}
+	if m.wallet == nil {
+		return errors.New("RebroadcastManager: no wallet to repost the stored transaction")
+	}
+	slate, err := m.wallet.GetStoredTx(pendingTx.TxID, pendingTx.TxSlateID)
+	if err != nil {
+		return err
+	}
+	if slate == nil {
+		return errors.New("RebroadcastManager: stored transaction not found")
+	}
+	return m.wallet.PostTx(*slate, true)
+}
+
+func (m *RebroadcastManager) touch(kernelExcess string, now time.Time, rebroadcasted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if pendingTx, ok := m.pending[kernelExcess]; ok {
+		pendingTx.LastSeen = now
+		if rebroadcasted {
+			pendingTx.Rebroadcasts++
+		}
+	}
+}
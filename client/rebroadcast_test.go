@@ -0,0 +1,171 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blockcypher/libgrin/v5/api"
+	"github.com/blockcypher/libgrin/v5/core"
+	"github.com/blockcypher/libgrin/v5/libwallet"
+	"github.com/blockcypher/libgrin/v5/libwallet/slateversions"
+	"github.com/blockcypher/libgrin/v5/pool"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeNode struct {
+	height    uint64
+	pool      []pool.PoolEntry
+	confirmed map[string]bool
+	lookupErr error
+	pushed    []core.Transaction
+	fluffs    []bool
+}
+
+func (n *fakeNode) GetTip() (*api.Tip, error) {
+	return &api.Tip{Height: n.height}, nil
+}
+
+func (n *fakeNode) GetKernel(excess string, minHeight, maxHeight *uint64) (*api.LocatedTxKernel, error) {
+	if n.lookupErr != nil {
+		return nil, n.lookupErr
+	}
+	if n.confirmed[excess] {
+		return &api.LocatedTxKernel{TxKernel: core.TxKernel{Excess: excess}, Height: n.height}, nil
+	}
+	return nil, ErrKernelNotFound
+}
+
+func (n *fakeNode) GetUnconfirmedTransactions() (*[]pool.PoolEntry, error) {
+	return &n.pool, nil
+}
+
+func (n *fakeNode) PushTransaction(tx core.Transaction, fluff *bool) error {
+	n.pushed = append(n.pushed, tx)
+	n.fluffs = append(n.fluffs, fluff != nil && *fluff)
+	return nil
+}
+
+type fakeWallet struct {
+	posted    []slateversions.SlateV4
+	cancelled []uint32
+}
+
+func (w *fakeWallet) GetStoredTx(id *uint32, slateID *uuid.UUID) (*slateversions.SlateV4, error) {
+	return &slateversions.SlateV4{ID: *slateID}, nil
+}
+
+func (w *fakeWallet) PostTx(slate slateversions.SlateV4, fluff bool) error {
+	w.posted = append(w.posted, slate)
+	return nil
+}
+
+func (w *fakeWallet) CancelTx(txID *uint32, txSlateID *uuid.UUID) error {
+	w.cancelled = append(w.cancelled, *txID)
+	return nil
+}
+
+func testTx(excess string) core.Transaction {
+	return core.Transaction{Body: core.TransactionBody{Kernels: []core.TxKernel{{Excess: excess}}}}
+}
+
+func TestRebroadcastTransaction(t *testing.T) {
+	node := &fakeNode{height: 100, confirmed: make(map[string]bool)}
+	config := RebroadcastConfig{RebroadcastDelay: 10 * time.Minute}
+	manager := NewRebroadcastManager(node, nil, config)
+	clock := time.Unix(1600000000, 0)
+	manager.now = func() time.Time { return clock }
+
+	assert.Error(t, manager.AddTransaction(core.Transaction{}, nil))
+	assert.NoError(t, manager.AddTransaction(testTx("09aa"), nil))
+
+	// Still in the pool, nothing to do
+	node.pool = []pool.PoolEntry{{Tx: testTx("09aa")}}
+	clock = clock.Add(time.Hour)
+	events, err := manager.Check()
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	// Fell out of the pool, but the delay is not elapsed
+	node.pool = nil
+	clock = clock.Add(5 * time.Minute)
+	events, err = manager.Check()
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	// Delay elapsed, the transaction is fluffed
+	clock = clock.Add(5 * time.Minute)
+	events, err = manager.Check()
+	assert.NoError(t, err)
+	assert.Equal(t, []RebroadcastEvent{{Type: RebroadcastedEvent, KernelExcess: "09aa"}}, events)
+	assert.Len(t, node.pushed, 1)
+	assert.Equal(t, []bool{true}, node.fluffs)
+	assert.Equal(t, uint(1), manager.Pending()[0].Rebroadcasts)
+
+	// Mined
+	node.confirmed["09aa"] = true
+	events, err = manager.Check()
+	assert.NoError(t, err)
+	assert.Equal(t, []RebroadcastEvent{{Type: ConfirmedEvent, KernelExcess: "09aa"}}, events)
+	assert.Empty(t, manager.Pending())
+}
+
+func TestRebroadcastExpiry(t *testing.T) {
+	node := &fakeNode{height: 100, confirmed: make(map[string]bool)}
+	wallet := &fakeWallet{}
+	config := RebroadcastConfig{RebroadcastDelay: time.Minute, CancelOnExpiry: true}
+	manager := NewRebroadcastManager(node, wallet, config)
+	clock := time.Unix(1600000000, 0)
+	manager.now = func() time.Time { return clock }
+
+	excess := "08bb"
+	slateID := uuid.New()
+	ttl := core.Uint64(110)
+	entry := libwallet.TxLogEntry{ID: 7, TxSlateID: &slateID, KernelExcess: &excess, TTLCutoffHeight: &ttl}
+	assert.NoError(t, manager.AddTxLogEntry(entry))
+
+	// Stored transactions are posted through the wallet
+	clock = clock.Add(2 * time.Minute)
+	events, err := manager.Check()
+	assert.NoError(t, err)
+	assert.Equal(t, []RebroadcastEvent{{Type: RebroadcastedEvent, KernelExcess: excess}}, events)
+	assert.Len(t, wallet.posted, 1)
+	assert.Equal(t, slateID, wallet.posted[0].ID)
+	assert.Empty(t, node.pushed)
+
+	// TTL reached but the node cannot tell whether the kernel is mined, the
+	// transaction is kept
+	node.height = 110
+	node.lookupErr = errors.New("connection refused")
+	events, err = manager.Check()
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	assert.Empty(t, wallet.cancelled)
+	assert.Len(t, manager.Pending(), 1)
+
+	// TTL reached, the transaction is cancelled
+	node.lookupErr = nil
+	events, err = manager.Check()
+	assert.NoError(t, err)
+	assert.Equal(t, []RebroadcastEvent{
+		{Type: ExpiredEvent, KernelExcess: excess},
+		{Type: CancelledEvent, KernelExcess: excess},
+	}, events)
+	assert.Equal(t, []uint32{7}, wallet.cancelled)
+	assert.Empty(t, manager.Pending())
+}
@@ -0,0 +1,160 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"strings"
+
+	"github.com/blockcypher/libgrin/v5/util/secp"
+)
+
+// CutThrough removes the inputs spending outputs of the body along with these
+// outputs, then sorts the body. Returns ErrCutThrough if an input or an
+// output is still duplicated afterwards.
+func (b *TransactionBody) CutThrough() error {
+	spent := make(map[string]int)
+	for _, output := range b.Outputs {
+		spent[strings.ToLower(output.Commit)]++
+	}
+	// Each input cuts through at most one output of the same commitment
+	cut := make(map[string]int)
+	inputs := make([]Input, 0, len(b.Inputs))
+	for _, input := range b.Inputs {
+		commit := strings.ToLower(input.Commit)
+		if spent[commit] > 0 {
+			spent[commit]--
+			cut[commit]++
+			continue
+		}
+		inputs = append(inputs, input)
+	}
+	outputs := make([]Output, 0, len(b.Outputs))
+	for _, output := range b.Outputs {
+		commit := strings.ToLower(output.Commit)
+		if cut[commit] > 0 {
+			cut[commit]--
+			continue
+		}
+		outputs = append(outputs, output)
+	}
+	b.Inputs = inputs
+	b.Outputs = outputs
+	if err := b.verifyCutThrough(); err != nil {
+		return err
+	}
+	return b.Sort()
+}
+
+// Aggregate merges transactions into a single multi-kernel transaction, as
+// done by the grin pool to build a block template: the bodies are merged and
+// cut through, the kernels are all kept and the offsets are summed.
+func Aggregate(txs []Transaction) (Transaction, error) {
+	if len(txs) == 0 {
+		return Transaction{
+			Offset: secp.ZeroKey.String(),
+			Body:   TransactionBody{Inputs: []Input{}, Outputs: []Output{}, Kernels: []TxKernel{}},
+		}, nil
+	}
+	if len(txs) == 1 {
+		tx := txs[0]
+		tx.Body.Inputs = append([]Input{}, tx.Body.Inputs...)
+		tx.Body.Outputs = append([]Output{}, tx.Body.Outputs...)
+		tx.Body.Kernels = append([]TxKernel{}, tx.Body.Kernels...)
+		return tx, nil
+	}
+
+	var body TransactionBody
+	offsets := make([]secp.SecretKey, len(txs))
+	for i, tx := range txs {
+		var err error
+		if offsets[i], err = secp.SecretKeyFromHex(tx.Offset); err != nil {
+			return Transaction{}, err
+		}
+		body.Inputs = append(body.Inputs, tx.Body.Inputs...)
+		body.Outputs = append(body.Outputs, tx.Body.Outputs...)
+		body.Kernels = append(body.Kernels, tx.Body.Kernels...)
+	}
+	if err := body.CutThrough(); err != nil {
+		return Transaction{}, err
+	}
+	offset, err := secp.BlindSum(offsets, nil)
+	if err != nil {
+		return Transaction{}, err
+	}
+	return Transaction{Offset: offset.String(), Body: body}, nil
+}
+
+// Deaggregate removes the known transactions from a multi-kernel
+// transaction, as done by the grin pool for transactions of
+// pool.DeaggregateTxSource: what is left are the inputs, outputs and kernels
+// of the aggregate not in the aggregation of the known transactions, with
+// the offset of the aggregate minus theirs.
+func Deaggregate(aggregate Transaction, txs []Transaction) (Transaction, error) {
+	known, err := Aggregate(txs)
+	if err != nil {
+		return Transaction{}, err
+	}
+	aggregateOffset, err := secp.SecretKeyFromHex(aggregate.Offset)
+	if err != nil {
+		return Transaction{}, err
+	}
+	knownOffset, err := secp.SecretKeyFromHex(known.Offset)
+	if err != nil {
+		return Transaction{}, err
+	}
+	offset, err := secp.BlindSum([]secp.SecretKey{aggregateOffset}, []secp.SecretKey{knownOffset})
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	// Inputs are compared by commitment only, as grin does
+	skipInputs := make(map[string]bool, len(known.Body.Inputs))
+	for _, input := range known.Body.Inputs {
+		skipInputs[strings.ToLower(input.Commit)] = true
+	}
+	skipOutputs := make(map[Output]bool, len(known.Body.Outputs))
+	for _, output := range known.Body.Outputs {
+		skipOutputs[output] = true
+	}
+	skipKernels := make(map[TxKernel]bool, len(known.Body.Kernels))
+	for _, kernel := range known.Body.Kernels {
+		skipKernels[kernel] = true
+	}
+
+	body := TransactionBody{Inputs: []Input{}, Outputs: []Output{}, Kernels: []TxKernel{}}
+	for _, input := range aggregate.Body.Inputs {
+		commit := strings.ToLower(input.Commit)
+		if !skipInputs[commit] {
+			skipInputs[commit] = true
+			body.Inputs = append(body.Inputs, input)
+		}
+	}
+	for _, output := range aggregate.Body.Outputs {
+		if !skipOutputs[output] {
+			skipOutputs[output] = true
+			body.Outputs = append(body.Outputs, output)
+		}
+	}
+	for _, kernel := range aggregate.Body.Kernels {
+		if !skipKernels[kernel] {
+			skipKernels[kernel] = true
+			body.Kernels = append(body.Kernels, kernel)
+		}
+	}
+	if err := body.Sort(); err != nil {
+		return Transaction{}, err
+	}
+	return Transaction{Offset: offset.String(), Body: body}, nil
+}
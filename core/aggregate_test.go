@@ -0,0 +1,94 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/util/secp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregate(t *testing.T) {
+	a := testAmount{60000000000, testBlind(1)}
+	b := testAmount{40000000000, testBlind(2)}
+	c := testAmount{19000000000, testBlind(3)}
+	d := testAmount{39000000000, testBlind(4)}
+	e := testAmount{30000000000, testBlind(5)}
+	f := testAmount{29000000000, testBlind(6)}
+	// tx2 spends the output b of tx1
+	tx1, _ := buildTestTransaction(t, []testAmount{a}, []testAmount{b, c}, testBlind(10))
+	tx2, _ := buildTestTransaction(t, []testAmount{b}, []testAmount{d}, testBlind(11))
+	tx3, _ := buildTestTransaction(t, []testAmount{e}, []testAmount{f}, testBlind(12))
+
+	empty, err := Aggregate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, secp.ZeroKey.String(), empty.Offset)
+	assert.Empty(t, empty.Body.Kernels)
+	single, err := Aggregate([]Transaction{tx1})
+	assert.NoError(t, err)
+	assert.Equal(t, tx1, single)
+
+	aggregate, err := Aggregate([]Transaction{tx1, tx2, tx3})
+	assert.NoError(t, err)
+	assert.NoError(t, aggregate.Validate(consensus.Mainnet))
+	// b is cut through
+	assert.Len(t, aggregate.Body.Inputs, 2)
+	assert.Len(t, aggregate.Body.Outputs, 3)
+	assert.Len(t, aggregate.Body.Kernels, 3)
+	for _, output := range aggregate.Body.Outputs {
+		assert.NotEqual(t, tx2.Body.Inputs[0].Commit, output.Commit)
+	}
+	assert.Equal(t, tx1.Body.Fee()+tx2.Body.Fee()+tx3.Body.Fee(), aggregate.Body.Fee())
+
+	// Aggregating the same transaction twice duplicates its input
+	_, err = Aggregate([]Transaction{tx3, tx3})
+	assert.Equal(t, ErrCutThrough, err)
+
+	// Removing tx3 from the aggregate
+	remaining, err := Deaggregate(aggregate, []Transaction{tx3})
+	assert.NoError(t, err)
+	assert.NoError(t, remaining.Validate(consensus.Mainnet))
+	tx12, err := Aggregate([]Transaction{tx1, tx2})
+	assert.NoError(t, err)
+	assert.Equal(t, tx12, remaining)
+
+	// Removing tx1 and tx2 gives back tx3
+	remaining, err = Deaggregate(aggregate, []Transaction{tx1, tx2})
+	assert.NoError(t, err)
+	assert.Equal(t, tx3, remaining)
+}
+
+func TestCutThrough(t *testing.T) {
+	body := TransactionBody{
+		Inputs: []Input{
+			{Features: PlainOutput, Commit: "08b7e57c448db5ef25aa119dde2312c64d7ff1b890c416c6dda5ec73cbfed2edea"},
+			{Features: PlainOutput, Commit: "09b7e57c448db5ef25aa119dde2312c64d7ff1b890c416c6dda5ec73cbfed2edea"},
+		},
+		Outputs: []Output{
+			{Features: PlainOutput, Commit: "09B7E57C448DB5EF25AA119DDE2312C64D7FF1B890C416C6DDA5EC73CBFED2EDEA"},
+			{Features: PlainOutput, Commit: "0ab7e57c448db5ef25aa119dde2312c64d7ff1b890c416c6dda5ec73cbfed2edea"},
+		},
+		Kernels: []TxKernel{},
+	}
+	assert.NoError(t, body.CutThrough())
+	assert.Equal(t, []Input{{Features: PlainOutput, Commit: "08b7e57c448db5ef25aa119dde2312c64d7ff1b890c416c6dda5ec73cbfed2edea"}}, body.Inputs)
+	assert.Equal(t, []Output{{Features: PlainOutput, Commit: "0ab7e57c448db5ef25aa119dde2312c64d7ff1b890c416c6dda5ec73cbfed2edea"}}, body.Outputs)
+
+	// Duplicate inputs can't be cut through
+	body.Inputs = append(body.Inputs, body.Inputs[0])
+	assert.Equal(t, ErrCutThrough, body.CutThrough())
+}
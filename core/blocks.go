@@ -14,7 +14,15 @@
 
 package core
 
-import "github.com/blockcypher/libgrin/v5/core/pow"
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/core/pow"
+	"github.com/blockcypher/libgrin/v5/core/ser"
+	"golang.org/x/crypto/blake2b"
+)
 
 // BlockHeader is a block header, fairly standard compared to other blockchains.
 type BlockHeader struct {
@@ -45,3 +53,57 @@ type BlockHeader struct {
 	// Proof of work and related
 	PoW pow.ProofOfWork
 }
+
+// PrePoW returns the pre-PoW serialization of the header: the header fields,
+// the total difficulty, the secondary scaling and the nonce. This is what the
+// cuckoo miner and verifier hash to build the graph.
+func (h *BlockHeader) PrePoW() ([]byte, error) {
+	var buf bytes.Buffer
+	w := ser.NewWriter(&buf, ser.CurrentProtocolVersion, ser.FullMode)
+	if err := h.WritePrePoW(w); err != nil {
+		return nil, err
+	}
+	if err := h.PoW.WritePrePoW(w); err != nil {
+		return nil, err
+	}
+	if err := w.WriteU64(h.PoW.Nonce); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Hash returns the hex encoded blake2b hash of the header. As the proof of
+// work commits to the pre-PoW, the header hash only covers the packed proof
+// nonces.
+func (h *BlockHeader) Hash() (string, error) {
+	b, err := ser.Serialize(h, ser.CurrentProtocolVersion, ser.HashMode)
+	if err != nil {
+		return "", err
+	}
+	hash := blake2b.Sum256(b)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// VerifyPoW builds the pre-PoW of the header and validates its proof of work. The
+// chain type must be registered.
+func (h *BlockHeader) VerifyPoW(chainType consensus.ChainType) error {
+	prePoW, err := h.PrePoW()
+	if err != nil {
+		return err
+	}
+	return VerifySize(chainType, prePoW, h)
+}
+
+// Block of grin: a header and the body of the transactions it contains, with
+// the coinbase reward
+type Block struct {
+	// The header with metadata and commitments to the rest of the data
+	Header BlockHeader
+	// The body: inputs, outputs and kernels
+	Body TransactionBody
+}
+
+// Hash returns the hex encoded hash of the block, the hash of its header
+func (b *Block) Hash() (string, error) {
+	return b.Header.Hash()
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderHash(t *testing.T) {
+	header := mainnetGenesisHeader
+	hash, err := header.Hash()
+	assert.NoError(t, err)
+	assert.Equal(t, "40adad0aec27797b48840aa9e00472015c21baea118ce7a2ff1a82c0f8f5bf82", hash)
+
+	header.Timestamp = "not a timestamp"
+	_, err = header.PrePoW()
+	assert.Error(t, err)
+}
+
+func TestHeaderPrePoW(t *testing.T) {
+	header := mainnetGenesisHeader
+	prePoW, err := header.PrePoW()
+	assert.NoError(t, err)
+	// version, height, timestamp, 5 hashes, offset, 2 mmr sizes, total
+	// difficulty, secondary scaling and nonce
+	assert.Len(t, prePoW, 2+8+8+5*32+32+8+8+8+4+8)
+	assert.Equal(t, []byte{0, 1}, prePoW[:2])
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 41}, prePoW[len(prePoW)-8:])
+
+	assert.NoError(t, header.VerifyPoW(consensus.Mainnet))
+
+	header.PoW.Nonce++
+	assert.Error(t, header.VerifyPoW(consensus.Mainnet))
+}
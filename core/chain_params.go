@@ -0,0 +1,161 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+)
+
+// ErrUnknownChainType is returned for a chain type without registered
+// parameters
+var ErrUnknownChainType = consensus.ErrUnknownChainType
+
+// ChainParams are the parameters of a chain type: its genesis block, how its
+// nodes talk to each other and its consensus parameters
+type ChainParams struct {
+	// The consensus parameters, including the hard fork heights
+	Consensus consensus.Params
+	// The genesis block
+	GenesisBlock Block
+	// Magic bytes starting every P2P message
+	P2PMagic [2]byte
+	// Default P2P port
+	P2PPort uint16
+	// Default node API port
+	APIPort uint16
+	// Default stratum server port
+	StratumPort uint16
+}
+
+// Magic bytes of the P2P messages
+var (
+	otherMagic   = [2]byte{73, 43}
+	testnetMagic = [2]byte{83, 59}
+	mainnetMagic = [2]byte{97, 61}
+)
+
+var (
+	chainParamsMutex sync.RWMutex
+	// Parameters by chain type, the consensus parameters being those
+	// registered in consensus
+	registeredChainParams = map[consensus.ChainType]ChainParams{
+		// Automated testing chains are not run as servers
+		consensus.AutomatedTesting: {
+			GenesisBlock: genesisDev(consensus.AutomatedTesting),
+			P2PMagic:     otherMagic,
+		},
+		consensus.UserTesting: {
+			GenesisBlock: genesisDev(consensus.UserTesting),
+			P2PMagic:     otherMagic,
+			P2PPort:      23414,
+			APIPort:      23413,
+			StratumPort:  23416,
+		},
+		consensus.Testnet: {
+			GenesisBlock: genesisTest(),
+			P2PMagic:     testnetMagic,
+			P2PPort:      13414,
+			APIPort:      13413,
+			StratumPort:  13416,
+		},
+		consensus.Mainnet: {
+			GenesisBlock: genesisMain(),
+			P2PMagic:     mainnetMagic,
+			P2PPort:      3414,
+			APIPort:      3413,
+			StratumPort:  3416,
+		},
+	}
+)
+
+// Copies a block so that changing its proof or body doesn't change the block
+func copyBlock(block Block) Block {
+	block.Header.PoW.Proof.Nonces = append([]uint64{}, block.Header.PoW.Proof.Nonces...)
+	block.Body.Inputs = append([]Input{}, block.Body.Inputs...)
+	block.Body.Outputs = append([]Output{}, block.Body.Outputs...)
+	block.Body.Kernels = append([]TxKernel{}, block.Body.Kernels...)
+	return block
+}
+
+// RegisterChain registers the parameters of a custom chain type, such as a
+// private regtest-style network with its own genesis block. Its consensus
+// parameters are registered with consensus.RegisterChainType, after which
+// the chain type can be used throughout the library. The genesis header has
+// to follow the rules of the chain type at height 0: its version and the edge
+// bits of its proof are checked as ValidateHeader does.
+func RegisterChain(chainType consensus.ChainType, params ChainParams) error {
+	genesis := params.GenesisBlock.Header
+	if genesis.Height != 0 || len(genesis.PoW.Proof.Nonces) != params.Consensus.ProofSize {
+		return errors.New("invalid genesis header")
+	}
+	// An invalid fork schedule is rejected when registering the consensus
+	// parameters
+	forks := params.Consensus.Forks
+	if len(forks) > 0 && genesis.Version != forks[0].Version {
+		return ErrInvalidBlockVersion
+	}
+	edgeBits := genesis.PoW.EdgeBits()
+	if edgeBits != consensus.SecondPoWEdgeBits && edgeBits < params.Consensus.MinEdgeBits {
+		return ErrLowEdgeBits
+	}
+	params.GenesisBlock = copyBlock(params.GenesisBlock)
+
+	chainParamsMutex.Lock()
+	defer chainParamsMutex.Unlock()
+	if _, ok := registeredChainParams[chainType]; ok {
+		return consensus.ErrChainTypeRegistered
+	}
+	if err := consensus.RegisterChainType(chainType, params.Consensus); err != nil {
+		return err
+	}
+	registeredChainParams[chainType] = params
+	return nil
+}
+
+// ChainTypeParams returns the parameters of a registered chain type
+func ChainTypeParams(chainType consensus.ChainType) (ChainParams, error) {
+	chainParamsMutex.RLock()
+	params, ok := registeredChainParams[chainType]
+	chainParamsMutex.RUnlock()
+	if !ok {
+		return ChainParams{}, ErrUnknownChainType
+	}
+	if params.Consensus, ok = consensus.ChainTypeParams(chainType); !ok {
+		return ChainParams{}, ErrUnknownChainType
+	}
+	params.GenesisBlock = copyBlock(params.GenesisBlock)
+	return params, nil
+}
+
+// GenesisBlock returns the genesis block of a registered chain type
+func GenesisBlock(chainType consensus.ChainType) (Block, error) {
+	params, err := ChainTypeParams(chainType)
+	if err != nil {
+		return Block{}, err
+	}
+	return params.GenesisBlock, nil
+}
+
+// GenesisHeader returns the genesis header of a registered chain type
+func GenesisHeader(chainType consensus.ChainType) (BlockHeader, error) {
+	params, err := ChainTypeParams(chainType)
+	if err != nil {
+		return BlockHeader{}, err
+	}
+	return params.GenesisBlock.Header, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"testing"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/core/ser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenesisBlocks(t *testing.T) {
+	// Hashes of grin's genesis.rs: the header hash and the hash of the
+	// block serialized with protocol version 1
+	tests := []struct {
+		chainType consensus.ChainType
+		hash      string
+		fullHash  string
+	}{
+		{consensus.Mainnet, "40adad0aec27797b48840aa9e00472015c21baea118ce7a2ff1a82c0f8f5bf82", "6be6f34b657b785e558e85cc3b8bdb5bcbe8c10e7e58524c8027da7727e189ef"},
+		{consensus.Testnet, "edc758c1370d43e1d733f70f58cf187c3be8242830429b1676b89fd91ccf2dab", "91c638fc019a54e6652bd6bb3d9c5e0c17e889cef34a5c28528e7eb61a884dc4"},
+	}
+	for _, test := range tests {
+		genesis, err := GenesisBlock(test.chainType)
+		assert.NoError(t, err)
+		hash, err := genesis.Hash()
+		assert.NoError(t, err)
+		assert.Equal(t, test.hash, hash)
+		b, err := ser.Serialize(&genesis, ser.ProtocolVersion(1), ser.FullMode)
+		assert.NoError(t, err)
+		assert.Equal(t, test.fullHash, blake2bHex(b))
+
+		assert.NoError(t, genesis.Header.VerifyPoW(test.chainType))
+		assert.NoError(t, genesis.Validate(test.chainType, zeroHashHex))
+		header, err := GenesisHeader(test.chainType)
+		assert.NoError(t, err)
+		assert.Equal(t, genesis.Header, header)
+	}
+
+	// The development genesis blocks are empty, with a zero proof
+	for _, chainType := range []consensus.ChainType{consensus.AutomatedTesting, consensus.UserTesting} {
+		params, err := ChainTypeParams(chainType)
+		assert.NoError(t, err)
+		assert.Equal(t, [2]byte{73, 43}, params.P2PMagic)
+		assert.Equal(t, consensus.ChainTypeProofSize(chainType), len(params.GenesisBlock.Header.PoW.Proof.Nonces))
+		assert.Equal(t, consensus.ChainTypeMinEdgeBits(chainType), params.GenesisBlock.Header.PoW.Proof.EdgeBits)
+		assert.Empty(t, params.GenesisBlock.Body.Kernels)
+		_, err = params.GenesisBlock.Hash()
+		assert.NoError(t, err)
+	}
+
+	params, err := ChainTypeParams(consensus.Mainnet)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(3414), params.P2PPort)
+	assert.Equal(t, [2]byte{97, 61}, params.P2PMagic)
+	assert.False(t, params.Consensus.NRDEnabled)
+
+	// The returned genesis block is a copy
+	params.GenesisBlock.Header.PoW.Proof.Nonces[0] = 0
+	params.GenesisBlock.Body.Kernels[0].Excess = ""
+	genesis, err := GenesisBlock(consensus.Mainnet)
+	assert.NoError(t, err)
+	assert.Equal(t, genesisMain(), genesis)
+
+	_, err = GenesisBlock(consensus.ChainType(200))
+	assert.Equal(t, ErrUnknownChainType, err)
+}
+
+func TestRegisterChain(t *testing.T) {
+	regtest := consensus.ChainType(201)
+	base, err := ChainTypeParams(consensus.AutomatedTesting)
+	assert.NoError(t, err)
+	params := base
+	params.Consensus.ShortName = "reg"
+	params.Consensus.Forks = []consensus.Fork{{Version: 1, Height: 0}, {Version: 2, Height: 1}}
+	params.GenesisBlock.Header.Timestamp = "2020-01-01T00:00:00+00:00"
+	params.P2PMagic = [2]byte{1, 2}
+	params.P2PPort = 33414
+
+	invalid := params
+	invalid.GenesisBlock.Header.PoW.Proof.Nonces = make([]uint64, 42)
+	assert.Error(t, RegisterChain(regtest, invalid))
+	invalid = params
+	invalid.GenesisBlock.Header.Version = 2
+	assert.Equal(t, ErrInvalidBlockVersion, RegisterChain(regtest, invalid))
+	invalid = params
+	invalid.GenesisBlock.Header.PoW.Proof.EdgeBits = params.Consensus.MinEdgeBits - 1
+	assert.Equal(t, ErrLowEdgeBits, RegisterChain(regtest, invalid))
+	invalid = params
+	invalid.Consensus.Forks = nil
+	assert.Equal(t, consensus.ErrInvalidParams, RegisterChain(regtest, invalid))
+	assert.NoError(t, RegisterChain(regtest, params))
+	assert.Equal(t, consensus.ErrChainTypeRegistered, RegisterChain(regtest, params))
+	assert.Equal(t, consensus.ErrChainTypeRegistered, RegisterChain(consensus.Mainnet, params))
+
+	registered, err := ChainTypeParams(regtest)
+	assert.NoError(t, err)
+	assert.Equal(t, params, registered)
+	genesis, err := GenesisHeader(regtest)
+	assert.NoError(t, err)
+	assert.Equal(t, "2020-01-01T00:00:00+00:00", genesis.Timestamp)
+	assert.Equal(t, uint16(2), consensus.HeaderVersion(regtest, 1))
+	assert.Equal(t, consensus.AutomatedTestingProofSize, consensus.ChainTypeProofSize(regtest))
+
+	// A chain type with only consensus parameters has no genesis nor ports
+	consensusOnly := consensus.ChainType(202)
+	assert.NoError(t, consensus.RegisterChainType(consensusOnly, params.Consensus))
+	_, err = ChainTypeParams(consensusOnly)
+	assert.Equal(t, ErrUnknownChainType, err)
+	_, err = GenesisHeader(consensusOnly)
+	assert.Equal(t, ErrUnknownChainType, err)
+}
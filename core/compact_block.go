@@ -0,0 +1,270 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+
+	"github.com/blockcypher/libgrin/v5/core/pow"
+	"golang.org/x/crypto/blake2b"
+)
+
+// ShortIDSize is the size of a short id
+const ShortIDSize = 6
+
+// ShortID identifies a kernel in a compact block: the first 6 bytes of the
+// siphash of the kernel hash, keyed on the block hash and the compact block
+// nonce
+type ShortID [ShortIDSize]byte
+
+// ErrInvalidShortID is returned when parsing a short id of the wrong size
+var ErrInvalidShortID = errors.New("invalid short id")
+
+// ShortIDFromHex parses an hex encoded short id
+func ShortIDFromHex(s string) (ShortID, error) {
+	var id ShortID
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return id, err
+	}
+	if len(b) != ShortIDSize {
+		return id, ErrInvalidShortID
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+// String returns the hex encoding of the short id
+func (id ShortID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Hash of a short id, by which the short ids of a compact block are sorted
+func (id *ShortID) hash() ([32]byte, error) {
+	return hashWriteable(id)
+}
+
+// Computes the short id of a hash: the siphash 2-4 of the hash, keyed on the
+// hash of the block hash and the nonce
+func shortID(hash, blockHash [32]byte, nonce uint64) ShortID {
+	var keyData [32 + 8]byte
+	copy(keyData[:32], blockHash[:])
+	binary.BigEndian.PutUint64(keyData[32:], nonce)
+	key := blake2b.Sum256(keyData[:])
+	k0 := binary.LittleEndian.Uint64(key[0:8])
+	k1 := binary.LittleEndian.Uint64(key[8:16])
+
+	var sum [8]byte
+	binary.LittleEndian.PutUint64(sum[:], pow.SipHash24Keyed(k0, k1, hash[:]))
+	var id ShortID
+	copy(id[:], sum[:ShortIDSize])
+	return id
+}
+
+// Parses an hex encoded block hash
+func blockHashFromHex(s string) ([32]byte, error) {
+	var hash [32]byte
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return hash, err
+	}
+	if len(b) != len(hash) {
+		return hash, errors.New("invalid block hash")
+	}
+	copy(hash[:], b)
+	return hash, nil
+}
+
+// ShortID returns the short id of the kernel in the compact block of the
+// block with the hex encoded hash, with the nonce
+func (k *TxKernel) ShortID(blockHash string, nonce uint64) (ShortID, error) {
+	h, err := blockHashFromHex(blockHash)
+	if err != nil {
+		return ShortID{}, err
+	}
+	hash, err := k.hash()
+	if err != nil {
+		return ShortID{}, err
+	}
+	return shortID(hash, h, nonce), nil
+}
+
+// CompactBlock is how blocks are relayed between peers: the header, the full
+// coinbase outputs and kernels and the short ids of the other kernels, which
+// peers know from their pool
+type CompactBlock struct {
+	// The header of the block
+	Header BlockHeader
+	// Nonce keying the short ids, random for each compact block
+	Nonce uint64
+	// The coinbase outputs
+	OutFull []Output
+	// The coinbase kernels
+	KernFull []TxKernel
+	// The short ids of the other kernels
+	KernIDs []ShortID
+}
+
+// NewCompactBlock builds the compact block of the block with the nonce, which
+// should be random
+func NewCompactBlock(block *Block, nonce uint64) (CompactBlock, error) {
+	cb := CompactBlock{
+		Header:   block.Header,
+		Nonce:    nonce,
+		OutFull:  []Output{},
+		KernFull: []TxKernel{},
+		KernIDs:  []ShortID{},
+	}
+	for _, output := range block.Body.Outputs {
+		if output.Features == CoinbaseOutput {
+			cb.OutFull = append(cb.OutFull, output)
+		}
+	}
+	blockHash, err := block.Hash()
+	if err != nil {
+		return CompactBlock{}, err
+	}
+	for i := range block.Body.Kernels {
+		kernel := block.Body.Kernels[i]
+		if kernel.Features == CoinbaseKernel {
+			cb.KernFull = append(cb.KernFull, kernel)
+			continue
+		}
+		id, err := kernel.ShortID(blockHash, nonce)
+		if err != nil {
+			return CompactBlock{}, err
+		}
+		cb.KernIDs = append(cb.KernIDs, id)
+	}
+	if err := cb.sort(); err != nil {
+		return CompactBlock{}, err
+	}
+	return cb, nil
+}
+
+// Hash returns the hex encoded hash of the compact block, the hash of its
+// header
+func (cb *CompactBlock) Hash() (string, error) {
+	return cb.Header.Hash()
+}
+
+// Sorts the full outputs, full kernels and short ids by hash
+func (cb *CompactBlock) sort() error {
+	body := TransactionBody{Outputs: cb.OutFull, Kernels: cb.KernFull}
+	if err := body.Sort(); err != nil {
+		return err
+	}
+	ids := cb.KernIDs
+	return sortByHash(len(ids), func(i int) ([32]byte, error) {
+		return ids[i].hash()
+	}, func(i, j int) {
+		ids[i], ids[j] = ids[j], ids[i]
+	})
+}
+
+// Verifies the full outputs, full kernels and short ids are sorted by hash
+// without duplicates
+func (cb *CompactBlock) verifySorted() error {
+	if err := verifySortedByHash(len(cb.OutFull), func(i int) ([32]byte, error) {
+		return cb.OutFull[i].hash()
+	}); err != nil {
+		return err
+	}
+	if err := verifySortedByHash(len(cb.KernFull), func(i int) ([32]byte, error) {
+		return cb.KernFull[i].hash()
+	}); err != nil {
+		return err
+	}
+	return verifySortedByHash(len(cb.KernIDs), func(i int) ([32]byte, error) {
+		return cb.KernIDs[i].hash()
+	})
+}
+
+// RetrieveTransactions finds the transactions with a kernel in the compact
+// block among the known transactions, such as those of the pool. Returns the
+// transactions found and the short ids of the kernels still missing, which
+// need to be requested from the peer.
+func (cb *CompactBlock) RetrieveTransactions(txs []Transaction) ([]Transaction, []ShortID, error) {
+	blockHash, err := cb.Hash()
+	if err != nil {
+		return nil, nil, err
+	}
+	wanted := make(map[ShortID]bool, len(cb.KernIDs))
+	for _, id := range cb.KernIDs {
+		wanted[id] = true
+	}
+	found := make(map[ShortID]bool, len(cb.KernIDs))
+	matched := []Transaction{}
+	for _, tx := range txs {
+		if len(found) == len(wanted) {
+			break
+		}
+		match := false
+		for i := range tx.Body.Kernels {
+			id, err := tx.Body.Kernels[i].ShortID(blockHash, cb.Nonce)
+			if err != nil {
+				return nil, nil, err
+			}
+			if wanted[id] {
+				found[id] = true
+				match = true
+			}
+		}
+		if match {
+			matched = append(matched, tx)
+		}
+	}
+	missing := []ShortID{}
+	for _, id := range cb.KernIDs {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	return matched, missing, nil
+}
+
+// Hydrate rebuilds the full block from the known transactions, such as those
+// of the pool. If kernels of the compact block are not among them, their
+// short ids are returned instead of the block. The hydrated block still needs
+// to be validated.
+func (cb *CompactBlock) Hydrate(txs []Transaction) (Block, []ShortID, error) {
+	matched, missing, err := cb.RetrieveTransactions(txs)
+	if err != nil {
+		return Block{}, nil, err
+	}
+	if len(missing) > 0 {
+		return Block{}, missing, nil
+	}
+
+	// The transactions are cut through before adding the coinbase
+	var body TransactionBody
+	for _, tx := range matched {
+		body.Inputs = append(body.Inputs, tx.Body.Inputs...)
+		body.Outputs = append(body.Outputs, tx.Body.Outputs...)
+		body.Kernels = append(body.Kernels, tx.Body.Kernels...)
+	}
+	if err := body.CutThrough(); err != nil {
+		return Block{}, nil, err
+	}
+	body.Inputs = append([]Input{}, body.Inputs...)
+	body.Outputs = append(append([]Output{}, body.Outputs...), cb.OutFull...)
+	body.Kernels = append(append([]TxKernel{}, body.Kernels...), cb.KernFull...)
+	if err := body.Sort(); err != nil {
+		return Block{}, nil, err
+	}
+	return Block{Header: cb.Header, Body: body}, missing, nil
+}
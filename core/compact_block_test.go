@@ -0,0 +1,106 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/core/ser"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestShortID(t *testing.T) {
+	// Vectors of grin: the hash of a u64 with a zero block hash and the u64
+	// as nonce
+	hash := blake2b.Sum256([]byte{0, 0, 0, 0, 0, 0, 0, 0})
+	assert.Equal(t, "4cc808b62476", shortID(hash, [32]byte{}, 0).String())
+	hash = blake2b.Sum256([]byte{0, 0, 0, 0, 0, 0, 0, 5})
+	assert.Equal(t, "02955a094534", shortID(hash, [32]byte{}, 5).String())
+
+	id, err := ShortIDFromHex("02955a094534")
+	assert.NoError(t, err)
+	assert.Equal(t, shortID(hash, [32]byte{}, 5), id)
+	_, err = ShortIDFromHex("02955a0945")
+	assert.Equal(t, ErrInvalidShortID, err)
+	_, err = (&TxKernel{}).ShortID("00", 0)
+	assert.Error(t, err)
+}
+
+func TestCompactBlock(t *testing.T) {
+	a := testAmount{60000000000, testBlind(1)}
+	b := testAmount{40000000000, testBlind(2)}
+	c := testAmount{19000000000, testBlind(3)}
+	d := testAmount{39000000000, testBlind(4)}
+	e := testAmount{30000000000, testBlind(5)}
+	f := testAmount{29000000000, testBlind(6)}
+	// tx2 spends the output b of tx1, tx3 is not in the block
+	tx1, _ := buildTestTransaction(t, []testAmount{a}, []testAmount{b, c}, testBlind(10))
+	tx2, _ := buildTestTransaction(t, []testAmount{b}, []testAmount{d}, testBlind(11))
+	tx3, _ := buildTestTransaction(t, []testAmount{e}, []testAmount{f}, testBlind(12))
+	tx, err := Aggregate([]Transaction{tx1, tx2})
+	assert.NoError(t, err)
+	prevOffset := testBlind(200)
+	block := buildTestBlock(t, tx, consensus.BlockReward(tx.Body.Fee()), prevOffset)
+
+	cb, err := NewCompactBlock(&block, 7)
+	assert.NoError(t, err)
+	assert.Len(t, cb.OutFull, 1)
+	assert.Len(t, cb.KernFull, 1)
+	assert.Len(t, cb.KernIDs, 2)
+	assert.NoError(t, cb.verifySorted())
+	blockHash, err := block.Hash()
+	assert.NoError(t, err)
+	cbHash, err := cb.Hash()
+	assert.NoError(t, err)
+	assert.Equal(t, blockHash, cbHash)
+
+	// Serialization round trip
+	cbBytes, err := ser.Serialize(&cb, ser.ProtocolVersion(2), ser.FullMode)
+	assert.NoError(t, err)
+	var read CompactBlock
+	assert.NoError(t, read.Read(ser.NewReader(bytes.NewReader(cbBytes), ser.ProtocolVersion(2)), consensus.Mainnet))
+	assert.Equal(t, cb, read)
+	unsorted := cb
+	unsorted.KernIDs = []ShortID{cb.KernIDs[1], cb.KernIDs[0]}
+	cbBytes, err = ser.Serialize(&unsorted, ser.ProtocolVersion(2), ser.FullMode)
+	assert.NoError(t, err)
+	assert.Equal(t, ErrSortOrder, read.Read(ser.NewReader(bytes.NewReader(cbBytes), ser.ProtocolVersion(2)), consensus.Mainnet))
+
+	// Missing kernels are reported
+	id2, err := tx2.Body.Kernels[0].ShortID(blockHash, cb.Nonce)
+	assert.NoError(t, err)
+	found, missing, err := cb.RetrieveTransactions([]Transaction{tx3, tx1})
+	assert.NoError(t, err)
+	assert.Equal(t, []Transaction{tx1}, found)
+	assert.Equal(t, []ShortID{id2}, missing)
+	_, missing, err = cb.Hydrate([]Transaction{tx1})
+	assert.NoError(t, err)
+	assert.Equal(t, []ShortID{id2}, missing)
+
+	// Hydrating from all the transactions gives back the block
+	hydrated, missing, err := cb.Hydrate([]Transaction{tx3, tx2, tx1})
+	assert.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, block, hydrated)
+	assert.NoError(t, hydrated.Validate(consensus.Mainnet, prevOffset.String()))
+
+	// Another nonce gives other short ids
+	other, err := NewCompactBlock(&block, 8)
+	assert.NoError(t, err)
+	assert.NotEqual(t, cb.KernIDs, other.KernIDs)
+}
@@ -14,7 +14,7 @@
 
 package consensus
 
-import "math"
+import "errors"
 
 // GrinBase is the grin base. A grin is divisible to 10^9, following the SI prefixes
 const GrinBase uint64 = 1000000000
@@ -37,8 +37,8 @@ const BlockTimeSec uint64 = 60
 // Reward is the block subsidy amount, one grin per second on average
 const Reward uint64 = BlockTimeSec * GrinBase
 
-// Actual block reward for a given total fee amount
-func reward(fee uint64) uint64 {
+// BlockReward is the actual block reward for a given total fee amount
+func BlockReward(fee uint64) uint64 {
 	return saturatingAddUint64(Reward, fee)
 }
 
@@ -67,6 +67,10 @@ func SecondaryPoWRatio(height uint64) uint64 {
 // ProofSize is the Cuckoo-cycle proof size (cycle length)
 const ProofSize int = 42
 
+// MaxProofSize is the maximum proof size of a chain type, bounding the
+// buffers of the verifiers
+const MaxProofSize int = 64
+
 // DefaultMinEdgeBits is the default Cuckatoo Cycle edge_bits, used for mining and validating.
 const DefaultMinEdgeBits uint8 = 31
 
@@ -102,6 +106,10 @@ const BlockOutputWeight int = 21
 // BlockKernelWeight is the weight of a kernel when counted against the max block weight capacity
 const BlockKernelWeight int = 3
 
+// CoinbaseWeight is the weight of the coinbase output and kernel every block
+// has to make room for
+const CoinbaseWeight int = BlockOutputWeight + BlockKernelWeight
+
 // MaxBlockWeight is the total maximum block weight. At current sizes, this means a maximum
 // theoretical size of:
 // * `(674 + 33 + 1) * (40_000 / 21) = 1_348_571` for a block with only outputs
@@ -116,6 +124,38 @@ const BlockKernelWeight int = 3
 //
 const MaxBlockWeight int = 40000
 
+// DefaultAcceptFeeBase is the default minimum fee per unit of weight accepted
+// by the pool and relayed, 1 grin per 2000 weight (GrinBase / 100 / 20 = 500_000)
+const DefaultAcceptFeeBase uint64 = GrinBase / 100 / 20
+
+// TxWeight is the transaction weight used for fees before HF4, the number of
+// outputs times 4 plus the number of kernels minus the number of inputs,
+// clamped to at least 1
+func TxWeight(numInputs, numOutputs, numKernels uint64) uint64 {
+	weight := saturatingSubUint64(saturatingAddUint64(saturatingMulUint64(numOutputs, 4), numKernels), numInputs)
+	if weight < 1 {
+		return 1
+	}
+	return weight
+}
+
+// TxBlockWeight is the weight of a transaction when counted against the max
+// block weight capacity, also used for fees since HF4
+func TxBlockWeight(numInputs, numOutputs, numKernels uint64) uint64 {
+	return saturatingAddUint64(
+		saturatingAddUint64(
+			saturatingMulUint64(numInputs, uint64(BlockInputWeight)),
+			saturatingMulUint64(numOutputs, uint64(BlockOutputWeight))),
+		saturatingMulUint64(numKernels, uint64(BlockKernelWeight)))
+}
+
+// MinRelayFee is the minimum fee for a transaction with the given number of
+// inputs, outputs and kernels to be accepted by the pool and relayed, given
+// the pool accept fee base (e.g. DefaultAcceptFeeBase)
+func MinRelayFee(acceptFeeBase, numInputs, numOutputs, numKernels uint64) uint64 {
+	return saturatingMulUint64(TxBlockWeight(numInputs, numOutputs, numKernels), acceptFeeBase)
+}
+
 // HardForkInterval every 6 months.
 const HardForkInterval uint64 = YearHeight / 2
 
@@ -135,31 +175,11 @@ const TestnetFourthHardFork uint64 = 642240
 // Fork every 3 blocks
 const TestingHardForkInterval uint64 = 9
 
-// HeaderVersion compute possible block version at a given height, implements
-// 6 months interval scheduled hard forks for the first 2 years.
+// HeaderVersion compute possible block version at a given height, the version
+// of the fork active at the height. Mainnet implements 6 months interval
+// scheduled hard forks for the first 2 years.
 func HeaderVersion(chainType ChainType, height uint64) uint16 {
-	hfInterval := uint16(1 + height/HardForkInterval)
-	switch chainType {
-	case Mainnet:
-		return uint16(math.Min(5, float64(hfInterval)))
-	case Testnet:
-		if height < TestnetFirstHardFork {
-			return 1
-		} else if height < TestnetSecondHardFork {
-			return 2
-		} else if height < TestnetThirdHardFork {
-			return 3
-		} else if height < TestnetFourthHardFork {
-			return 4
-		} else {
-			return 5
-		}
-	case AutomatedTesting, UserTesting:
-		testingHFInterval := uint16(1 + height/TestingHardForkInterval)
-		return uint16(math.Min(5, float64(testingHFInterval)))
-	default:
-		return hfInterval
-	}
+	return ForkAt(chainType, height).Version
 }
 
 // ValidHeaderVersion check whether the block version is valid at a given height, implements
@@ -169,8 +189,13 @@ func ValidHeaderVersion(chainType ChainType, height uint64, version uint16) bool
 }
 
 // DifficultyAdjustWindow is the number of blocks used to calculate difficulty adjustments
+// by Damped Moving Average
 const DifficultyAdjustWindow uint64 = HourHeight
 
+// WTEMAHalfLife is the difficulty adjustment half life (actually, 60s * number of 0s-blocks
+// to raise diff by factor e), 4 hours
+const WTEMAHalfLife uint64 = 4 * HourHeight * BlockTimeSec
+
 // BlockTimeWindow is the average time span of the difficulty adjustment window
 const BlockTimeWindow uint64 = DifficultyAdjustWindow * BlockTimeSec
 
@@ -198,6 +223,10 @@ func GraphWeight(chainType ChainType, height uint64, edgeBits uint8) uint64 {
 	return (uint64(2) << uint64(edgeBits-baseEdgeBits(chainType))) * xprEdgeBits
 }
 
+// C32GraphWeight is the minimum solution difficulty after HardFork4 when PoW becomes primary
+// only Cuckatoo32+
+const C32GraphWeight uint64 = (uint64(2) << (32 - BaseEdgeBits)) * 32
+
 // MinDifficulty is the minimum difficulty, enforced in diff retargetting
 // avoids getting stuck when trying to increase difficulty subject to dampening
 const MinDifficulty uint64 = DifficultyDampFactor
@@ -228,6 +257,16 @@ type HeaderInfo struct {
 	isSecondary bool
 }
 
+// NewHeaderInfo is the default constructor
+func NewHeaderInfo(timestamp uint64, difficulty Difficulty, secondaryScaling uint32, isSecondary bool) HeaderInfo {
+	return HeaderInfo{
+		timestamp:        timestamp,
+		difficulty:       difficulty,
+		secondaryScaling: secondaryScaling,
+		isSecondary:      isSecondary,
+	}
+}
+
 // HeaderInfoFromTsDiff is a constructor from a timestamp and difficulty, setting a default secondary
 // PoW factor
 func HeaderInfoFromTsDiff(chainType ChainType, timestamp uint64, difficulty Difficulty) HeaderInfo {
@@ -250,6 +289,52 @@ func HeaderInfoFromDiffScaling(difficulty Difficulty, secondaryScaling uint32) H
 	}
 }
 
+// Timestamp of the header, 1 when not used (returned info)
+func (h *HeaderInfo) Timestamp() uint64 {
+	return h.timestamp
+}
+
+// Difficulty is the network difficulty or next difficulty to use
+func (h *HeaderInfo) Difficulty() Difficulty {
+	return h.difficulty
+}
+
+// SecondaryScaling is the network secondary PoW factor or factor to use
+func (h *HeaderInfo) SecondaryScaling() uint32 {
+	return h.secondaryScaling
+}
+
+// IsSecondary is whether the header is a secondary proof of work
+func (h *HeaderInfo) IsSecondary() bool {
+	return h.isSecondary
+}
+
+// HeaderInfoIterator iterates over past block headers information, from latest
+// (highest height) to oldest (lowest height)
+type HeaderInfoIterator interface {
+	// Next returns the next header information, false when there are no more
+	Next() (HeaderInfo, bool)
+}
+
+type headerInfoSliceIterator struct {
+	headerInfos []HeaderInfo
+	pos         int
+}
+
+func (it *headerInfoSliceIterator) Next() (HeaderInfo, bool) {
+	if it.pos >= len(it.headerInfos) {
+		return HeaderInfo{}, false
+	}
+	it.pos++
+	return it.headerInfos[it.pos-1], true
+}
+
+// NewHeaderInfoSliceIterator creates an iterator over header infos ordered from
+// latest to oldest
+func NewHeaderInfoSliceIterator(headerInfos []HeaderInfo) HeaderInfoIterator {
+	return &headerInfoSliceIterator{headerInfos: headerInfos}
+}
+
 // Move value linearly toward a goal
 func damp(actual, goal, dampFactor uint64) uint64 {
 	return (actual + (dampFactor-1)*goal) / dampFactor
@@ -257,6 +342,140 @@ func damp(actual, goal, dampFactor uint64) uint64 {
 
 // limit value to be within some factor from a goal
 func clamp(actual, goal, clampFactor uint64) uint64 {
-	return uint64(math.Max(float64(goal)/float64(clampFactor),
-		math.Min(float64(actual), float64(goal)*float64(clampFactor))))
+	upper := goal * clampFactor
+	if actual > upper {
+		actual = upper
+	}
+	if lower := goal / clampFactor; actual < lower {
+		return lower
+	}
+	return actual
+}
+
+// NextDifficulty computes the proof-of-work difficulty that the next block should comply with.
+// Takes an iterator over past block headers information, from latest
+// (highest height) to oldest (lowest height).
+// Uses either the old dma DAA or, starting from HF4, the new wtema DAA
+func NextDifficulty(chainType ChainType, height uint64, cursor HeaderInfoIterator) (HeaderInfo, error) {
+	if !ForkAt(chainType, height).WTEMADifficulty {
+		return NextDMADifficulty(chainType, height, cursor)
+	}
+	return NextWTEMADifficulty(chainType, height, cursor)
+}
+
+// NextDMADifficulty is the difficulty calculation based on a Damped Moving Average
+// of difficulty over a window of DifficultyAdjustWindow blocks.
+// The corresponding timespan is calculated
+// by using the difference between the timestamps at the beginning
+// and the end of the window, with a damping toward the target block time.
+func NextDMADifficulty(chainType ChainType, height uint64, cursor HeaderInfoIterator) (HeaderInfo, error) {
+	// Create vector of difficulty data running from earliest
+	// to latest, and pad with simulated pre-genesis data to allow earlier
+	// adjustment if there isn't enough window data length will be
+	// DifficultyAdjustWindow + 1 (for initial block time bound)
+	diffData, err := difficultyDataToVector(chainType, cursor)
+	if err != nil {
+		return HeaderInfo{}, err
+	}
+
+	// First, get the ratio of secondary PoW vs primary, skipping initial header
+	secPoWScaling := SecondaryPoWScaling(height, diffData[1:])
+
+	// Get the timestamp delta across the window
+	tsDelta := diffData[DifficultyAdjustWindow].timestamp - diffData[0].timestamp
+
+	// Get the difficulty sum of the last DifficultyAdjustWindow elements
+	var diffSum uint64
+	for _, dd := range diffData[1:] {
+		diffSum += dd.difficulty.num
+	}
+
+	// adjust time delta toward goal subject to dampening and clamping
+	adjTs := clamp(
+		damp(tsDelta, BlockTimeWindow, DifficultyDampFactor),
+		BlockTimeWindow,
+		ClampFactor,
+	)
+	// minimum difficulty avoids getting stuck due to dampening
+	difficulty := diffSum * BlockTimeSec / adjTs
+	if difficulty < MinDifficulty {
+		difficulty = MinDifficulty
+	}
+
+	return HeaderInfoFromDiffScaling(new(Difficulty).FromNum(difficulty), secPoWScaling), nil
+}
+
+// NextWTEMADifficulty is the difficulty calculation based on a Weighted Target Exponential
+// Moving Average of difficulty, using the ratio of the last block time over the target block time.
+func NextWTEMADifficulty(chainType ChainType, height uint64, cursor HeaderInfoIterator) (HeaderInfo, error) {
+	// last two headers
+	lastHeader, ok := cursor.Next()
+	if !ok {
+		return HeaderInfo{}, errors.New("not enough headers for difficulty adjustment")
+	}
+	prevHeader, ok := cursor.Next()
+	if !ok {
+		return HeaderInfo{}, errors.New("not enough headers for difficulty adjustment")
+	}
+
+	lastBlockTime := lastHeader.timestamp - prevHeader.timestamp
+	lastDiff := lastHeader.difficulty.num
+
+	// wtema difficulty update
+	nextDiff := lastDiff * WTEMAHalfLife / (WTEMAHalfLife - BlockTimeSec + lastBlockTime)
+
+	// mainnet minimum difficulty at graph_weight(32) ensures difficulty increase on 59s block
+	// since 16384 * WTEMAHalfLife / (WTEMAHalfLife - 1) > 16384
+	difficulty := new(Difficulty).FromNum(nextDiff)
+	if minDifficulty := minWTEMAGraphWeight(chainType); difficulty.num < minDifficulty {
+		difficulty.num = minDifficulty
+	}
+
+	// no more secondary PoW
+	return HeaderInfoFromDiffScaling(difficulty, 0), nil
+}
+
+// ARCount counts, in units of 1/100 (a percent), the number of "secondary" (AR) blocks in the
+// provided window of blocks.
+func ARCount(height uint64, diffData []HeaderInfo) uint64 {
+	var count uint64
+	for _, dd := range diffData {
+		if dd.isSecondary {
+			count++
+		}
+	}
+	return 100 * count
+}
+
+// SecondaryPoWScaling is the factor by which the secondary proof of work difficulty will be adjusted.
+// It is calculated along the same lines as in NextDMADifficulty, as an adjustment on the deviation
+// against the ideal value.
+func SecondaryPoWScaling(height uint64, diffData []HeaderInfo) uint32 {
+	// Get the scaling factor sum of the last DifficultyAdjustWindow elements
+	var scaleSum uint64
+	for _, dd := range diffData {
+		scaleSum += uint64(dd.secondaryScaling)
+	}
+
+	// compute ideal 2nd_pow_fraction in pct and across window
+	targetPct := SecondaryPoWRatio(height)
+	targetCount := DifficultyAdjustWindow * targetPct
+
+	// Get the secondary count across the window, adjusting count toward goal
+	// subject to dampening and clamping.
+	adjCount := clamp(
+		damp(ARCount(height, diffData), targetCount, ARScaleDampFactor),
+		targetCount,
+		ClampFactor,
+	)
+	if adjCount < 1 {
+		adjCount = 1
+	}
+	scale := scaleSum * targetPct / adjCount
+
+	// minimum AR scale avoids getting stuck due to dampening
+	if scale < MinArScale {
+		scale = MinArScale
+	}
+	return uint32(scale)
 }
@@ -15,6 +15,7 @@
 package consensus
 
 import (
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -142,3 +143,284 @@ func TestHardForks(t *testing.T) {
 		assert.True(t, ValidHeaderVersion(Testnet, TestnetFourthHardFork+1, 5))
 	}
 }
+
+// Builds len header infos with the given difficulty, interval seconds apart,
+// from latest to oldest
+func repeat(interval uint64, diff HeaderInfo, len, curTime uint64) []HeaderInfo {
+	headerInfos := make([]HeaderInfo, 0, len)
+	for n := len; n > 0; n-- {
+		headerInfos = append(headerInfos,
+			NewHeaderInfo(curTime+(n-1)*interval, diff.difficulty, diff.secondaryScaling, diff.isSecondary))
+	}
+	return headerInfos
+}
+
+func nextDMADifficulty(headerInfos []HeaderInfo) HeaderInfo {
+	next, _ := NextDMADifficulty(AutomatedTesting, 1, NewHeaderInfoSliceIterator(headerInfos))
+	return next
+}
+
+func TestSecondaryPoWScaling(t *testing.T) {
+	window := DifficultyAdjustWindow
+	headerInfos := func(counts ...interface{}) []HeaderInfo {
+		var res []HeaderInfo
+		for i := 0; i < len(counts); i += 2 {
+			for n := uint64(0); n < counts[i].(uint64); n++ {
+				res = append(res, counts[i+1].(HeaderInfo))
+			}
+		}
+		return res
+	}
+	ten := new(Difficulty).FromNum(10)
+
+	// all primary, factor should increase so it becomes easier to find a high difficulty block
+	hi := NewHeaderInfo(1, ten, 100, false)
+	assert.Equal(t, uint32(108), SecondaryPoWScaling(1, headerInfos(window, hi)))
+	// all secondary on 90%, factor should go down a bit
+	hi = HeaderInfoFromDiffScaling(ten, 100)
+	assert.Equal(t, uint32(99), SecondaryPoWScaling(1, headerInfos(window, hi)))
+	// all secondary on 1%, factor should go down to bound (divide by 2)
+	assert.Equal(t, uint32(50), SecondaryPoWScaling(2*YearHeight*83/90, headerInfos(window, hi)))
+	// same as above, testing lowest bound
+	lowHi := HeaderInfoFromDiffScaling(ten, uint32(MinArScale))
+	assert.Equal(t, uint32(MinArScale), SecondaryPoWScaling(2*YearHeight, headerInfos(window, lowHi)))
+	// the right ratio of 90% secondary
+	primaryHi := NewHeaderInfo(1, ten, 50, false)
+	assert.Equal(t, uint32(95), SecondaryPoWScaling(1, headerInfos(window/10, primaryHi, window*9/10, hi)))
+	// 95% secondary, should come down based on 97.5 average
+	assert.Equal(t, uint32(97), SecondaryPoWScaling(1, headerInfos(window/20, primaryHi, window*95/100, hi)))
+	// 40% secondary, should come up based on 70 average
+	assert.Equal(t, uint32(73), SecondaryPoWScaling(1, headerInfos(window*6/10, primaryHi, window*4/10, hi)))
+
+	assert.Equal(t, uint64(100*window*4/10), ARCount(1, headerInfos(window*6/10, primaryHi, window*4/10, hi)))
+}
+
+func TestNextDMADifficulty(t *testing.T) {
+	curTime := uint64(1600000000)
+	justEnough := DifficultyAdjustWindow + 1
+
+	// Check we don't get stuck on difficulty <= MinDifficulty (at 4x faster blocks at least)
+	diffMin := new(Difficulty).FromNum(MinDifficulty)
+	hi := NewHeaderInfo(1, diffMin, uint32(ARScaleDampFactor), false)
+	next := nextDMADifficulty(repeat(15, hi, DifficultyAdjustWindow, curTime))
+	assert.NotEqual(t, MinDifficulty, next.difficulty.ToNum())
+	// Check we don't get stuck on scale MinArScale, when primary frequency is too high
+	assert.NotEqual(t, uint32(MinArScale), next.SecondaryScaling())
+
+	// just enough data, right interval, should stay constant
+	hi.difficulty = new(Difficulty).FromNum(10000)
+	next = nextDMADifficulty(repeat(BlockTimeSec, hi, justEnough, curTime))
+	assert.Equal(t, uint64(10000), next.difficulty.ToNum())
+
+	// check pre difficultyDataToVector effect on retargetting
+	next = nextDMADifficulty([]HeaderInfo{HeaderInfoFromTsDiff(AutomatedTesting, 42, hi.difficulty)})
+	assert.Equal(t, uint64(14913), next.difficulty.ToNum())
+
+	// checking averaging works
+	hi.difficulty = new(Difficulty).FromNum(500)
+	sec := DifficultyAdjustWindow / 2
+	s1 := repeat(BlockTimeSec, hi, sec, curTime)
+	s2 := repeat(BlockTimeSec, HeaderInfoFromTsDiff(AutomatedTesting, 1, new(Difficulty).FromNum(1500)),
+		sec, curTime+sec*BlockTimeSec)
+	next = nextDMADifficulty(append(s2, s1...))
+	assert.Equal(t, uint64(1000), next.difficulty.ToNum())
+
+	hi.difficulty = new(Difficulty).FromNum(1000)
+	tests := []struct {
+		interval uint64
+		expected uint64
+	}{
+		// too slow, diff goes down
+		{90, 857},
+		{120, 750},
+		// too fast, diff goes up
+		{55, 1028},
+		{45, 1090},
+		{30, 1200},
+		// hitting lower time bound, should always get the same result below
+		{0, 1500},
+		// hitting higher time bound, should always get the same result above
+		{300, 500},
+		{400, 500},
+	}
+	for _, test := range tests {
+		next = nextDMADifficulty(repeat(test.interval, hi, justEnough, curTime))
+		assert.Equal(t, test.expected, next.difficulty.ToNum(), "interval %d", test.interval)
+	}
+
+	// We should never drop below minimum
+	hi.difficulty = new(Difficulty).FromNum(0)
+	next = nextDMADifficulty(repeat(90, hi, justEnough, curTime))
+	assert.Equal(t, MinDifficulty, next.difficulty.ToNum())
+
+	_, err := NextDMADifficulty(AutomatedTesting, 1, NewHeaderInfoSliceIterator(nil))
+	assert.Error(t, err)
+}
+
+func TestNextWTEMADifficulty(t *testing.T) {
+	curTime := uint64(1600000000)
+	hf4 := 2 * YearHeight
+	nextDifficulty := func(interval uint64, diff HeaderInfo) HeaderInfo {
+		next, err := NextDifficulty(Mainnet, hf4, NewHeaderInfoSliceIterator(repeat(interval, diff, 2, curTime)))
+		assert.NoError(t, err)
+		return next
+	}
+
+	hi := HeaderInfoFromDiffScaling(new(Difficulty).FromNum(20000), 0)
+	tests := []struct {
+		interval uint64
+		expected uint64
+	}{
+		// right interval, should stay constant
+		{60, 20000},
+		// too slow, diff goes down
+		{61, 19998},
+		{90, 19958},
+		{120, 19917},
+		{300, 19672},
+		{400, 19538},
+		// too fast, diff goes up
+		{59, 20001},
+		{55, 20006},
+		{45, 20020},
+		{30, 20041},
+		{0, 20083},
+	}
+	for _, test := range tests {
+		next := nextDifficulty(test.interval, hi)
+		assert.Equal(t, test.expected, next.difficulty.ToNum(), "interval %d", test.interval)
+		assert.Equal(t, uint32(0), next.SecondaryScaling())
+	}
+
+	// We should never drop below minimum
+	hi.difficulty = new(Difficulty).FromNum(0)
+	next := nextDifficulty(60, hi)
+	assert.Equal(t, C32GraphWeight, next.difficulty.ToNum())
+
+	// Check we don't get stuck on minimum difficulty
+	hi.difficulty = new(Difficulty).FromNum(C32GraphWeight)
+	next = nextDifficulty(59, hi)
+	assert.NotEqual(t, C32GraphWeight, next.difficulty.ToNum())
+
+	_, err := NextWTEMADifficulty(Mainnet, hf4, NewHeaderInfoSliceIterator(repeat(60, hi, 1, curTime)))
+	assert.Error(t, err)
+}
+
+func TestTxWeight(t *testing.T) {
+	assert.Equal(t, uint64(9), TxWeight(0, 2, 1))
+	assert.Equal(t, uint64(7), TxWeight(2, 2, 1))
+	// clamped to at least 1
+	assert.Equal(t, uint64(1), TxWeight(10, 1, 1))
+	assert.Equal(t, uint64(1), TxWeight(0, 0, 0))
+
+	assert.Equal(t, uint64(47), TxBlockWeight(2, 2, 1))
+	assert.Equal(t, uint64(24), TxBlockWeight(0, 1, 1))
+	assert.Equal(t, uint64(math.MaxUint64), TxBlockWeight(math.MaxUint64, 1, 1))
+
+	assert.Equal(t, uint64(500000), DefaultAcceptFeeBase)
+	assert.Equal(t, uint64(47*500000), MinRelayFee(DefaultAcceptFeeBase, 2, 2, 1))
+	assert.Equal(t, uint64(47*1000000), MinRelayFee(1000000, 2, 2, 1))
+}
+
+func TestChainTypeTxRules(t *testing.T) {
+	assert.Equal(t, uint64(40000-24), ChainTypeMaxTxWeight(Mainnet))
+	assert.Equal(t, uint64(150-24), ChainTypeMaxTxWeight(AutomatedTesting))
+	assert.False(t, ChainTypeNRDEnabled(Mainnet))
+	assert.True(t, ChainTypeNRDEnabled(Testnet))
+	assert.True(t, ChainTypeNRDEnabled(AutomatedTesting))
+}
+
+func TestRegisterChainType(t *testing.T) {
+	// Unregistered chain types have no parameters nor forks, none of the
+	// mainnet rules apply to them
+	regtest := ChainType(100)
+	params, ok := ChainTypeParams(regtest)
+	assert.False(t, ok)
+	assert.Equal(t, Params{}, params)
+	assert.False(t, ChainTypeRegistered(regtest))
+	assert.Equal(t, "", regtest.shortname())
+	assert.Equal(t, uint16(0), HeaderVersion(regtest, 4*HardForkInterval))
+	assert.False(t, ValidHeaderVersion(regtest, 0, 1))
+	assert.Equal(t, 0, ChainTypeProofSize(regtest))
+	assert.Equal(t, uint8(0), ChainTypeMinEdgeBits(regtest))
+	assert.Equal(t, uint64(0), ChainTypeMaxTxWeight(regtest))
+
+	params, ok = ChainTypeParams(AutomatedTesting)
+	assert.True(t, ok)
+	assert.Equal(t, "auto", AutomatedTesting.shortname())
+	assert.Equal(t, uint64(18), minWTEMAGraphWeight(AutomatedTesting))
+	assert.Equal(t, uint32(1856), initialGraphWeight(Mainnet))
+	assert.Equal(t, GraphWeight(Testnet, 0, SecondPoWEdgeBits), minWTEMAGraphWeight(Testnet))
+
+	// A regtest chain based on automated testing, forking at 2 and 4
+	params.ShortName = "reg"
+	params.MaxBlockWeight = 1000
+	params.Forks = []Fork{
+		{Version: 1, Height: 0},
+		{Version: 2, Height: 2},
+		{Version: 3, Height: 4, NRDKernels: true},
+	}
+	assert.NoError(t, RegisterChainType(regtest, params))
+	assert.Equal(t, ErrChainTypeRegistered, RegisterChainType(regtest, params))
+	assert.Equal(t, ErrChainTypeRegistered, RegisterChainType(Mainnet, params))
+	assert.True(t, ChainTypeRegistered(regtest))
+	assert.Equal(t, "reg", regtest.shortname())
+	assert.Equal(t, AutomatedTestingProofSize, ChainTypeProofSize(regtest))
+	assert.Equal(t, 1000, ChainTypeMaxBlockWeight(regtest))
+	assert.Equal(t, uint16(1), HeaderVersion(regtest, 1))
+	assert.Equal(t, uint16(2), HeaderVersion(regtest, 2))
+	assert.Equal(t, uint16(3), HeaderVersion(regtest, 100))
+
+	// Changing the returned parameters doesn't change the registered ones
+	registered, ok := ChainTypeParams(regtest)
+	assert.True(t, ok)
+	registered.Forks[1].Height = 3
+	assert.Equal(t, uint16(2), HeaderVersion(regtest, 2))
+
+	params.Forks = []Fork{{Version: 1, Height: 0}, {Version: 2, Height: 4}, {Version: 3, Height: 2}}
+	assert.Equal(t, ErrInvalidParams, RegisterChainType(ChainType(101), params))
+	params.Forks = []Fork{{Version: 1, Height: 0}, {Version: 3, Height: 2}}
+	assert.Equal(t, ErrInvalidParams, RegisterChainType(ChainType(101), params))
+	params.Forks = []Fork{{Version: 1, Height: 1}}
+	assert.Equal(t, ErrInvalidParams, RegisterChainType(ChainType(101), params))
+	params.Forks = nil
+	assert.Equal(t, ErrInvalidParams, RegisterChainType(ChainType(101), params))
+	params.Forks = []Fork{{Version: 1, Height: 0}}
+	params.ProofSize = 0
+	assert.Equal(t, ErrInvalidParams, RegisterChainType(ChainType(101), params))
+	params.ProofSize = MaxProofSize + 2
+	assert.Equal(t, ErrInvalidParams, RegisterChainType(ChainType(101), params))
+}
+
+func TestForkSchedule(t *testing.T) {
+	forks := ChainTypeForks(Mainnet)
+	assert.Len(t, forks, 5)
+	for i, fork := range forks {
+		assert.Equal(t, uint16(i+1), fork.Version)
+		assert.Equal(t, uint64(i)*HardForkInterval, fork.Height)
+	}
+	assert.Equal(t, "Cuckarooz", forks[3].SecondaryPoW.String())
+
+	// The rules of each fork apply from its height
+	assert.Equal(t, CuckarooPoW, ForkAt(Mainnet, 0).SecondaryPoW)
+	assert.Equal(t, CuckaroodPoW, ForkAt(Testnet, TestnetFirstHardFork).SecondaryPoW)
+	assert.Equal(t, CuckaroomPoW, ForkAt(Testnet, TestnetThirdHardFork-1).SecondaryPoW)
+	assert.False(t, ForkAt(Mainnet, 3*HardForkInterval-1).NRDKernels)
+	assert.True(t, ForkAt(Mainnet, 3*HardForkInterval).NRDKernels)
+	assert.Equal(t, NoSecondaryPoW, ForkAt(Mainnet, 4*HardForkInterval).SecondaryPoW)
+	assert.True(t, ForkAt(Mainnet, 10*HardForkInterval).WTEMADifficulty)
+	assert.Equal(t, CuckatooPoW, ForkAt(AutomatedTesting, 0).SecondaryPoW)
+	assert.Equal(t, uint16(5), ForkAt(AutomatedTesting, 4*TestingHardForkInterval).Version)
+	assert.Equal(t, CuckatooPoW, ForkAt(UserTesting, 100).SecondaryPoW)
+
+	// Forks are also found by header version
+	fork, ok := ForkOfVersion(Testnet, 3)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(TestnetSecondHardFork), fork.Height)
+	_, ok = ForkOfVersion(Mainnet, 6)
+	assert.False(t, ok)
+
+	// Fees pay for the block weight from the fourth hard fork
+	assert.Equal(t, uint64(8), FeeWeight(Mainnet, 4*HardForkInterval-1, 1, 2, 1))
+	assert.Equal(t, uint64(46), FeeWeight(Mainnet, 4*HardForkInterval, 1, 2, 1))
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consensus
+
+// PoWVariant is the proof of work of the graphs of SecondPoWEdgeBits or less,
+// the secondary (AR) proof of work of mainnet
+type PoWVariant int
+
+const (
+	// CuckatooPoW is the primary proof of work, for chains without a
+	// secondary one
+	CuckatooPoW PoWVariant = iota
+	// CuckarooPoW is the secondary proof of work at launch
+	CuckarooPoW
+	// CuckaroodPoW is the secondary proof of work from the first hard fork
+	CuckaroodPoW
+	// CuckaroomPoW is the secondary proof of work from the second hard fork
+	CuckaroomPoW
+	// CuckaroozPoW is the secondary proof of work from the third hard fork
+	CuckaroozPoW
+	// NoSecondaryPoW is the retirement of the secondary proof of work, from
+	// the fourth hard fork
+	NoSecondaryPoW
+)
+
+func (v PoWVariant) String() string {
+	return toStringPoWVariant[v]
+}
+
+var toStringPoWVariant = map[PoWVariant]string{
+	CuckatooPoW:    "Cuckatoo",
+	CuckarooPoW:    "Cuckaroo",
+	CuckaroodPoW:   "Cuckarood",
+	CuckaroomPoW:   "Cuckaroom",
+	CuckaroozPoW:   "Cuckarooz",
+	NoSecondaryPoW: "None",
+}
+
+// Fork is an entry of the hard fork schedule of a chain type: from its
+// height, headers have its version and blocks follow its rules
+type Fork struct {
+	// Header version from the fork
+	Version uint16
+	// Activation height
+	Height uint64
+	// Proof of work of the graphs of SecondPoWEdgeBits or less
+	SecondaryPoW PoWVariant
+	// Whether no recent duplicate kernels are allowed in blocks, if enabled
+	// for the chain type
+	NRDKernels bool
+	// Whether difficulty is adjusted with the WTEMA DAA, the secondary
+	// scaling being no longer adjusted, instead of the DMA DAA
+	WTEMADifficulty bool
+	// Whether fees are paid for the block weight of transactions rather than
+	// their TxWeight
+	BlockWeightFees bool
+}
+
+// The grin hard forks from genesis, from version 1 to 5, at the heights
+func grinForks(heights [4]uint64) []Fork {
+	return []Fork{
+		{Version: 1, Height: 0, SecondaryPoW: CuckarooPoW},
+		{Version: 2, Height: heights[0], SecondaryPoW: CuckaroodPoW},
+		{Version: 3, Height: heights[1], SecondaryPoW: CuckaroomPoW},
+		{Version: 4, Height: heights[2], SecondaryPoW: CuckaroozPoW, NRDKernels: true},
+		{Version: 5, Height: heights[3], SecondaryPoW: NoSecondaryPoW, NRDKernels: true, WTEMADifficulty: true, BlockWeightFees: true},
+	}
+}
+
+// The grin hard forks at the heights on a chain without secondary PoW
+func cuckatooForks(heights [4]uint64) []Fork {
+	forks := grinForks(heights)
+	for i := range forks {
+		forks[i].SecondaryPoW = CuckatooPoW
+	}
+	return forks
+}
+
+// Verifies a fork schedule starts with version 1 at genesis, each fork
+// incrementing the version at a greater height
+func validForks(forks []Fork) bool {
+	if len(forks) == 0 || forks[0].Version != 1 || forks[0].Height != 0 {
+		return false
+	}
+	for i := 1; i < len(forks); i++ {
+		if forks[i].Version != forks[i-1].Version+1 || forks[i].Height <= forks[i-1].Height {
+			return false
+		}
+	}
+	return true
+}
+
+// ChainTypeForks returns the hard fork schedule of a chain type
+func ChainTypeForks(chainType ChainType) []Fork {
+	return append([]Fork(nil), chainParams(chainType).Forks...)
+}
+
+// ForkAt returns the fork of a chain type active at a height, whose rules
+// apply to the block at that height. An unregistered chain type has no fork,
+// the zero Fork is returned.
+func ForkAt(chainType ChainType, height uint64) Fork {
+	forks := chainParams(chainType).Forks
+	if len(forks) == 0 {
+		return Fork{}
+	}
+	fork := forks[0]
+	for _, f := range forks[1:] {
+		if height < f.Height {
+			break
+		}
+		fork = f
+	}
+	return fork
+}
+
+// ForkOfVersion returns the fork of a chain type whose headers have the
+// version, and whether there is one
+func ForkOfVersion(chainType ChainType, version uint16) (Fork, bool) {
+	for _, fork := range chainParams(chainType).Forks {
+		if fork.Version == version {
+			return fork, true
+		}
+	}
+	return Fork{}, false
+}
+
+// FeeWeight is the weight of a transaction its fee pays for at a height:
+// its TxWeight before the fork with BlockWeightFees, its TxBlockWeight after
+func FeeWeight(chainType ChainType, height, numInputs, numOutputs, numKernels uint64) uint64 {
+	if ForkAt(chainType, height).BlockWeightFees {
+		return TxBlockWeight(numInputs, numOutputs, numKernels)
+	}
+	return TxWeight(numInputs, numOutputs, numKernels)
+}
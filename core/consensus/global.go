@@ -14,6 +14,8 @@
 
 package consensus
 
+import "errors"
+
 // Define these here, as they should be developer-set, not really tweakable /
 //by users
 
@@ -66,140 +68,76 @@ const (
 )
 
 func (c ChainType) shortname() string {
-	var shortName string
-	switch c {
-	case AutomatedTesting:
-		shortName = "auto"
-	case UserTesting:
-		shortName = "user"
-	case Testnet:
-		shortName = "test"
-	case Mainnet:
-		shortName = "main"
-	}
-	return shortName
+	return chainParams(c).ShortName
 }
 
-// The minimum acceptable edge_bits
-func minEdgeBits(chainType ChainType) uint8 {
-	switch chainType {
-	case AutomatedTesting:
-		return AutomatedTestingMinEdgeBits
-	case UserTesting:
-		return UserTestingMinEdgeBits
-	default:
-		return DefaultMinEdgeBits
-	}
+// ChainTypeMinEdgeBits returns the minimum acceptable edge_bits
+func ChainTypeMinEdgeBits(chainType ChainType) uint8 {
+	return chainParams(chainType).MinEdgeBits
 }
 
 // Reference edge_bits used to compute factor on higher Cuck(at)oo graph sizes,
 // while the min_edge_bits can be changed on a soft fork, changing /
 //base_edge_bits is a hard fork.
 func baseEdgeBits(chainType ChainType) uint8 {
-	switch chainType {
-	case AutomatedTesting:
-		return AutomatedTestingMinEdgeBits
-	case UserTesting:
-		return UserTestingMinEdgeBits
-	default:
-		return BaseEdgeBits
-	}
+	return chainParams(chainType).BaseEdgeBits
 }
 
 // ChainTypeProofSize return the proof size based on the proofsize
 func ChainTypeProofSize(chainType ChainType) int {
-	switch chainType {
-	case AutomatedTesting:
-		return AutomatedTestingProofSize
-	case UserTesting:
-		return UserTestingProofSize
-	default:
-		return ProofSize
-	}
+	return chainParams(chainType).ProofSize
 }
 
 // Coinbase maturity for coinbases to be spent
 func coinbaseMaturity(chainType ChainType) uint64 {
-	switch chainType {
-	case AutomatedTesting:
-		return AutomatedTestingCoinbaseMaturity
-	case UserTesting:
-		return UserTestingCoinbaseMaturity
-	default:
-		return CoinbaseMaturity
-	}
+	return chainParams(chainType).CoinbaseMaturity
 }
 
 // Initial mining difficulty
 func initialBlockDifficulty(chainType ChainType) uint64 {
-	switch chainType {
-	case AutomatedTesting:
-		return TestingInitialDifficulty
-	case UserTesting:
-		return TestingInitialDifficulty
-	case Testnet:
-		return InitialDifficulty
-	case Mainnet:
-		return InitialDifficulty
-	default:
-		return InitialDifficulty
-	}
+	return chainParams(chainType).InitialBlockDifficulty
 }
 
 // Initial mining secondary scale
 func initialGraphWeight(chainType ChainType) uint32 {
-	switch chainType {
-	case AutomatedTesting:
-		return TestingInitialGraphWeight
-	case UserTesting:
-		return TestingInitialGraphWeight
-	case Testnet:
-		return uint32(GraphWeight(chainType, 0, SecondPoWEdgeBits))
-	case Mainnet:
-		return uint32(GraphWeight(chainType, 0, SecondPoWEdgeBits))
-	default:
-		return uint32(GraphWeight(chainType, 0, SecondPoWEdgeBits))
-	}
+	return chainParams(chainType).InitialGraphWeight
 }
 
-// Maximum allowed block weight.
-func maxBlockWeight(chainType ChainType) int {
-	switch chainType {
-	case AutomatedTesting:
-		return TestingMaxBlockWeight
-	case UserTesting:
-		return TestingMaxBlockWeight
-	case Testnet:
-		return MaxBlockWeight
-	case Mainnet:
-		return MaxBlockWeight
-	default:
-		return MaxBlockWeight
+// Minimum valid graph weight post HF4
+func minWTEMAGraphWeight(chainType ChainType) uint64 {
+	return chainParams(chainType).MinWTEMAGraphWeight
+}
+
+// ChainTypeMaxBlockWeight returns the maximum allowed block weight for a chain type
+func ChainTypeMaxBlockWeight(chainType ChainType) int {
+	return chainParams(chainType).MaxBlockWeight
+}
+
+// ChainTypeMaxTxWeight returns the maximum allowed transaction weight for a
+// chain type: the max block weight minus the weight of the coinbase reward
+// (one output and one kernel)
+func ChainTypeMaxTxWeight(chainType ChainType) uint64 {
+	maxBlockWeight := ChainTypeMaxBlockWeight(chainType)
+	if maxBlockWeight < CoinbaseWeight {
+		return 0
 	}
+	return uint64(maxBlockWeight - CoinbaseWeight)
+}
+
+// ChainTypeNRDEnabled returns whether no recent duplicate kernels are enabled
+// for a chain type, as set by the node
+func ChainTypeNRDEnabled(chainType ChainType) bool {
+	return chainParams(chainType).NRDEnabled
 }
 
 // Horizon at which we can cut-through and do full local pruning
 func cutThroughHorizon(chainType ChainType) uint32 {
-	switch chainType {
-	case AutomatedTesting:
-		return TestingCutThroughHorizon
-	case UserTesting:
-		return TestingCutThroughHorizon
-	default:
-		return CutThroughHorizon
-	}
+	return chainParams(chainType).CutThroughHorizon
 }
 
 // Threshold at which we can request a txhashset (and full blocks from)
 func stateSyncThreshold(chainType ChainType) uint32 {
-	switch chainType {
-	case AutomatedTesting:
-		return TestingStateSyncThreshold
-	case UserTesting:
-		return TestingStateSyncThreshold
-	default:
-		return StateSyncThreshold
-	}
+	return chainParams(chainType).StateSyncThreshold
 }
 
 // Are we in automated testing mode?
@@ -235,25 +173,52 @@ func isMainnet(chainType ChainType) bool {
 //block POW solution turns out to be the same for every new / block chain at the
 //moment
 func getGenesisNonce(chainType ChainType) uint64 {
-	switch chainType {
-	case AutomatedTesting:
-		// won't make a difference
-		return 0
-	case UserTesting:
-		// Magic nonce for current genesis block at cuckatoo15
-		return 27944
-	case Testnet:
-		// Placeholder, obviously not the right value
-		return 0
-	case Mainnet:
-		// Placeholder, obviously not the right value
-		return 0
-	default:
-		return 0
-	}
+	return chainParams(chainType).GenesisNonce
 }
 
 // Short name representing the current chain type ("floo", "main", etc.)
 func chainShortname(chainType ChainType) string {
 	return chainType.shortname()
 }
+
+// Converts an iterator of block difficulty data to more a more manageable
+// vector and pads if needed (which will) only be needed for the first few
+// blocks after genesis
+func difficultyDataToVector(chainType ChainType, cursor HeaderInfoIterator) ([]HeaderInfo, error) {
+	// Convert iterator to vector, so we can append to it if necessary
+	neededBlockCount := int(DifficultyAdjustWindow) + 1
+	lastN := make([]HeaderInfo, 0, neededBlockCount)
+	for len(lastN) < neededBlockCount {
+		headerInfo, ok := cursor.Next()
+		if !ok {
+			break
+		}
+		lastN = append(lastN, headerInfo)
+	}
+	n := len(lastN)
+	if n == 0 {
+		return nil, errors.New("not enough headers for difficulty adjustment")
+	}
+
+	// Only needed just after blockchain launch... basically ensures there's
+	// always enough data by simulating perfectly timed pre-genesis
+	// blocks at the genesis difficulty as needed.
+	if neededBlockCount > n {
+		lastTsDelta := BlockTimeSec
+		if n > 1 {
+			lastTsDelta = lastN[0].timestamp - lastN[1].timestamp
+		}
+		lastDiff := lastN[0].difficulty
+
+		// fill in simulated blocks with values from the previous real block
+		lastTs := lastN[n-1].timestamp
+		for i := n; i < neededBlockCount; i++ {
+			lastTs = saturatingSubUint64(lastTs, lastTsDelta)
+			lastN = append(lastN, HeaderInfoFromTsDiff(chainType, lastTs, lastDiff))
+		}
+	}
+	for i, j := 0, len(lastN)-1; i < j; i, j = i+1, j-1 {
+		lastN[i], lastN[j] = lastN[j], lastN[i]
+	}
+	return lastN, nil
+}
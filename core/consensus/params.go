@@ -0,0 +1,209 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consensus
+
+import (
+	"errors"
+	"sync"
+)
+
+// Params are the consensus parameters of a chain type
+type Params struct {
+	// Short name of the chain type ("main", "test", etc.)
+	ShortName string
+	// Minimum acceptable edge bits of the primary PoW
+	MinEdgeBits uint8
+	// Reference edge bits used to compute the graph weight
+	BaseEdgeBits uint8
+	// Number of nonces of a proof of work
+	ProofSize int
+	// Number of blocks before a coinbase output can be spent
+	CoinbaseMaturity uint64
+	// Horizon at which we can cut-through and do full local pruning
+	CutThroughHorizon uint32
+	// Threshold at which we can request a txhashset
+	StateSyncThreshold uint32
+	// Initial mining difficulty
+	InitialBlockDifficulty uint64
+	// Initial mining secondary scale
+	InitialGraphWeight uint32
+	// Minimum valid graph weight post HF4
+	MinWTEMAGraphWeight uint64
+	// Maximum block weight
+	MaxBlockWeight int
+	// Whether no recent duplicate kernels are enabled
+	NRDEnabled bool
+	// Nonce known to create a valid PoW on the genesis block
+	GenesisNonce uint64
+	// Hard fork schedule, from version 1 at genesis, each fork incrementing
+	// the header version
+	Forks []Fork
+}
+
+var (
+	// ErrChainTypeRegistered is returned when registering a chain type which
+	// already has parameters
+	ErrChainTypeRegistered = errors.New("chain type already registered")
+	// ErrInvalidParams is returned when registering invalid parameters
+	ErrInvalidParams = errors.New("invalid consensus parameters")
+	// ErrUnknownChainType is returned for a chain type without registered
+	// parameters
+	ErrUnknownChainType = errors.New("unknown chain type")
+)
+
+var (
+	paramsMutex sync.RWMutex
+	// Parameters by chain type
+	registeredParams = map[ChainType]Params{
+		AutomatedTesting: {
+			ShortName:              "auto",
+			MinEdgeBits:            AutomatedTestingMinEdgeBits,
+			BaseEdgeBits:           AutomatedTestingMinEdgeBits,
+			ProofSize:              AutomatedTestingProofSize,
+			CoinbaseMaturity:       AutomatedTestingCoinbaseMaturity,
+			CutThroughHorizon:      TestingCutThroughHorizon,
+			StateSyncThreshold:     TestingStateSyncThreshold,
+			InitialBlockDifficulty: TestingInitialDifficulty,
+			InitialGraphWeight:     TestingInitialGraphWeight,
+			MinWTEMAGraphWeight:    2 * uint64(AutomatedTestingMinEdgeBits),
+			MaxBlockWeight:         TestingMaxBlockWeight,
+			NRDEnabled:             true,
+			GenesisNonce:           0,
+			Forks:                  testingForks(),
+		},
+		UserTesting: {
+			ShortName:              "user",
+			MinEdgeBits:            UserTestingMinEdgeBits,
+			BaseEdgeBits:           UserTestingMinEdgeBits,
+			ProofSize:              UserTestingProofSize,
+			CoinbaseMaturity:       UserTestingCoinbaseMaturity,
+			CutThroughHorizon:      TestingCutThroughHorizon,
+			StateSyncThreshold:     TestingStateSyncThreshold,
+			InitialBlockDifficulty: TestingInitialDifficulty,
+			InitialGraphWeight:     TestingInitialGraphWeight,
+			MinWTEMAGraphWeight:    2 * uint64(UserTestingMinEdgeBits),
+			MaxBlockWeight:         TestingMaxBlockWeight,
+			NRDEnabled:             true,
+			// Magic nonce for current genesis block at cuckatoo15
+			GenesisNonce: 27944,
+			Forks:        testingForks(),
+		},
+		Testnet: {
+			ShortName:              "test",
+			MinEdgeBits:            DefaultMinEdgeBits,
+			BaseEdgeBits:           BaseEdgeBits,
+			ProofSize:              ProofSize,
+			CoinbaseMaturity:       CoinbaseMaturity,
+			CutThroughHorizon:      CutThroughHorizon,
+			StateSyncThreshold:     StateSyncThreshold,
+			InitialBlockDifficulty: InitialDifficulty,
+			InitialGraphWeight:     uint32(UnitDifficulty),
+			MinWTEMAGraphWeight:    UnitDifficulty,
+			MaxBlockWeight:         MaxBlockWeight,
+			NRDEnabled:             true,
+			GenesisNonce:           0,
+			Forks: grinForks([4]uint64{
+				TestnetFirstHardFork,
+				TestnetSecondHardFork,
+				TestnetThirdHardFork,
+				TestnetFourthHardFork,
+			}),
+		},
+		Mainnet: {
+			ShortName:              "main",
+			MinEdgeBits:            DefaultMinEdgeBits,
+			BaseEdgeBits:           BaseEdgeBits,
+			ProofSize:              ProofSize,
+			CoinbaseMaturity:       CoinbaseMaturity,
+			CutThroughHorizon:      CutThroughHorizon,
+			StateSyncThreshold:     StateSyncThreshold,
+			InitialBlockDifficulty: InitialDifficulty,
+			InitialGraphWeight:     uint32(UnitDifficulty),
+			MinWTEMAGraphWeight:    C32GraphWeight,
+			MaxBlockWeight:         MaxBlockWeight,
+			NRDEnabled:             false,
+			GenesisNonce:           0,
+			// 6 months interval scheduled hard forks for the first 2 years
+			Forks: grinForks([4]uint64{
+				HardForkInterval,
+				2 * HardForkInterval,
+				3 * HardForkInterval,
+				4 * HardForkInterval,
+			}),
+		},
+	}
+)
+
+// The testing chain types fork every TestingHardForkInterval blocks, without
+// secondary PoW
+func testingForks() []Fork {
+	return cuckatooForks([4]uint64{
+		TestingHardForkInterval,
+		2 * TestingHardForkInterval,
+		3 * TestingHardForkInterval,
+		4 * TestingHardForkInterval,
+	})
+}
+
+// RegisterChainType registers the consensus parameters of a custom chain
+// type, such as a private regtest-style network. The parameters of a
+// registered chain type can't be changed.
+func RegisterChainType(chainType ChainType, params Params) error {
+	if params.ProofSize <= 0 || params.ProofSize > MaxProofSize || params.MinEdgeBits < params.BaseEdgeBits || params.MaxBlockWeight <= 0 {
+		return ErrInvalidParams
+	}
+	if !validForks(params.Forks) {
+		return ErrInvalidParams
+	}
+	params.Forks = append([]Fork(nil), params.Forks...)
+
+	paramsMutex.Lock()
+	defer paramsMutex.Unlock()
+	if _, ok := registeredParams[chainType]; ok {
+		return ErrChainTypeRegistered
+	}
+	registeredParams[chainType] = params
+	return nil
+}
+
+// ChainTypeParams returns the consensus parameters of a chain type and
+// whether it is registered. No parameters are returned for an unregistered
+// chain type.
+func ChainTypeParams(chainType ChainType) (Params, bool) {
+	paramsMutex.RLock()
+	params, ok := registeredParams[chainType]
+	paramsMutex.RUnlock()
+	if !ok {
+		return Params{}, false
+	}
+	params.Forks = append([]Fork(nil), params.Forks...)
+	return params, true
+}
+
+// ChainTypeRegistered returns whether a chain type has registered parameters
+func ChainTypeRegistered(chainType ChainType) bool {
+	paramsMutex.RLock()
+	defer paramsMutex.RUnlock()
+	_, ok := registeredParams[chainType]
+	return ok
+}
+
+// The parameters of a chain type, without copying the fork schedule. The
+// parameters of an unregistered chain type are all zero, it has no forks.
+func chainParams(chainType ChainType) Params {
+	paramsMutex.RLock()
+	defer paramsMutex.RUnlock()
+	return registeredParams[chainType]
+}
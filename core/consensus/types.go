@@ -41,6 +41,11 @@ func (p *Difficulty) FromNum(num uint64) Difficulty {
 	return Difficulty{num: uint64(math.Max(float64(num), 1))}
 }
 
+// ToNum converts the difficulty into a `uint64`
+func (p *Difficulty) ToNum() uint64 {
+	return p.num
+}
+
 // saturatingSubUint8 is a saturating uint8 subtraction. Computes a - b, saturating at the numeric bounds instead of overflowing.
 func saturatingSubUint8(a, b uint8) uint8 {
 	if a < b {
@@ -108,3 +113,11 @@ func saturatingAddUint64(a, b uint64) uint64 {
 	}
 	return a + b
 }
+
+// saturatingMulUint64 is a saturating uint64 multiplication. Computes a * b, saturating at the numeric bounds instead of overflowing.
+func saturatingMulUint64(a, b uint64) uint64 {
+	if a != 0 && b > math.MaxUint64/a {
+		return math.MaxUint64
+	}
+	return a * b
+}
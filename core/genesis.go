@@ -0,0 +1,149 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/core/pow"
+)
+
+// Hex encoded zero hash, the previous hash and the roots of an empty genesis
+const zeroHashHex = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// Genesis block definition for development networks. The proof of work size
+// is small enough to mine it on the fly, so it does not contain its own proof
+// of work solution.
+func genesisDev(chainType consensus.ChainType) Block {
+	params, _ := consensus.ChainTypeParams(chainType)
+	return Block{
+		Header: BlockHeader{
+			Version:           1,
+			Height:            0,
+			PrevHash:          zeroHashHex,
+			PrevRoot:          zeroHashHex,
+			Timestamp:         "1997-08-04T00:00:00+00:00",
+			OutputRoot:        zeroHashHex,
+			RangeProofRoot:    zeroHashHex,
+			KernelRoot:        zeroHashHex,
+			TotalKernelOffset: zeroHashHex,
+			PoW: pow.ProofOfWork{
+				TotalDifficulty:  consensus.MinDifficulty,
+				SecondaryScaling: 1,
+				Nonce:            params.GenesisNonce,
+				Proof: pow.Proof{
+					EdgeBits: params.MinEdgeBits,
+					Nonces:   make([]uint64, params.ProofSize),
+				},
+			},
+		},
+		Body: TransactionBody{Inputs: []Input{}, Outputs: []Output{}, Kernels: []TxKernel{}},
+	}
+}
+
+// Testnet genesis block, the excess signature is in its compact form
+func genesisTest() Block {
+	return Block{
+		Header: BlockHeader{
+			Version:           1,
+			Height:            0,
+			PrevHash:          zeroHashHex,
+			PrevRoot:          "00000000000000000017ff4903ef366c8f62e3151ba74e41b8332a126542f538",
+			Timestamp:         "2018-12-28T20:48:04+00:00",
+			OutputRoot:        "73b5e0a05ea9e1e4e33b8f1c723bc5c10d17f07042c2af7644f4dbb61f4bc556",
+			RangeProofRoot:    "667a3ba22f237a875f67c9933037c8564097fa57a3e75be507916de28fc0da26",
+			KernelRoot:        "cfdddfe2d938d0026f8b1304442655bbdddde175ff45ddf44cb03bcb0071a72d",
+			TotalKernelOffset: zeroHashHex,
+			OutputMmrSize:     1,
+			KernelMmrSize:     1,
+			PoW: pow.ProofOfWork{
+				TotalDifficulty:  100000,
+				SecondaryScaling: 1856,
+				Nonce:            23,
+				Proof: pow.Proof{
+					EdgeBits: 29,
+					Nonces: []uint64{
+						16994232, 22975978, 32664019, 44016212, 50238216, 57272481, 85779161,
+						124272202, 125203242, 133907662, 140522149, 145870823, 147481297, 164952795,
+						177186722, 183382201, 197418356, 211393794, 239282197, 239323031, 250757611,
+						281414565, 305112109, 308151499, 357235186, 374041407, 389924708, 390768911,
+						401322239, 401886855, 406986280, 416797005, 418935317, 429007407, 439527429,
+						484809502, 486257104, 495589543, 495892390, 525019296, 529899691, 531685572,
+					},
+				},
+			},
+		},
+		Body: TransactionBody{
+			Inputs: []Input{},
+			Outputs: []Output{{
+				Features: CoinbaseOutput,
+				Commit:   "08c12007af16d1ee55fffe92cef808c77e318dae70c3bc70cb6361f49d517f1b68",
+				Proof:    "9f9ccab380a90ee3b04f76b43ea402ea7b1e4d7ee87c2abaefd015d9e4f6944a6419f7fb52642510927aa40502a5d4c0dda7c708e7959ed8c2c83e0f35c8bccf004fd358c2d33601ce3548769bb8e9a6f5e010fed1eb9955359121bada769023bdf13fe534ede727b0ca5df7558310c1f7b4218aff66bed581aeb6a7037eb8dd6372eedb9d7de6b3a059cae6105bc7399ee18e7d0cd3a44e09049b6a9d29e9bcedcdb83500be18d72a2cb8783ac4c6be723262f00fd54da31803d47d5dafa9f9181bbf71593ba92857fa909f76abe85cd905b398f9f747ef1ab452b1e284b90321a27862576d3964caa239e62c1f3fd51edef14ea2767846c48048df6e05119761d62b399d013b5760119fae90d99f572471299bbafca22e1650850371f80b76909bbc4da628776b0fe92f2f654da78deb9422daa4a84714ef47180c6d92e8f3411f48ba83be2be39d29317e88332932d525badf57f8222b8422008f4b4f2b4ab71a02a835cbd09f456b7c214471ce7fd89e0f34ce01656dc70d837a1d83857ddb4645908544e943cb84a08f65546e0faf6f7c18b9de9aee4df16908e0e62bb2315f8921e376cfef381533dc1630a21676e5d7f870c67eb41ba1ed3802dc817e0b680885bea2cc033ff9add298fc8f9d4fe4e8e648a483b797e6dbba15229adbd7e7b32fd92c73cb9d23c371ebc26660cd18ddd59378b2dd99922cac834d153d0f0506cda4cb4ce41d7e8858e6d23ea4677d37e781593dde3247474be6465055c188b7de922eeb00de76204655275ce9d3a99fcf910dce7d032d3340a7b3855339be33efd34a744b47f8f9b80d1f816b68b34cc2ba040d7aa7fe7e99320801c8cbd5e6d9616932d07eb4715198ee7b9de813a49fa4594b21468ccc9eec0ae2660e5886528324c37f9e51fcdfa50b3469f5f5e4eba8af34af4c9d78d06387d25172e6b5",
+			}},
+			Kernels: []TxKernel{{
+				Features:  CoinbaseKernel,
+				Excess:    "08df2f1d996cee37715d9ac0a0f3b13aae508d1101945acb8044954aee30960be9",
+				ExcessSig: "b2e9c1ff9700ba6a6e2d89f6d97bc46e829d100d65496ff7dc0c01acf634b019005883fc2d854c27f1c484ed4110df0536528fe3d41ca109bc9259c8d7d21a67",
+			}},
+		},
+	}
+}
+
+// Mainnet genesis block, the excess signature is in its compact form
+func genesisMain() Block {
+	return Block{
+		Header: BlockHeader{
+			Version:           1,
+			Height:            0,
+			PrevHash:          zeroHashHex,
+			PrevRoot:          "0000000000000000002a8bc32f43277fe9c063b9c99ea252b483941dcd06e217",
+			Timestamp:         "2019-01-15T16:01:26+00:00",
+			OutputRoot:        "fa7566d275006c6c467876758f2bc87e4cebd2020ae9cf9f294c6217828d6872",
+			RangeProofRoot:    "1b7fff259aee3edfb5867c4775e4e1717826b843cda6685e5140442ece7bfc2e",
+			KernelRoot:        "e8bb096a73cbe6e099968965f5342fc1702ee2802802902286dcf0f279e326bf",
+			TotalKernelOffset: zeroHashHex,
+			OutputMmrSize:     1,
+			KernelMmrSize:     1,
+			PoW: pow.ProofOfWork{
+				TotalDifficulty:  1 << 34,
+				SecondaryScaling: 1856,
+				Nonce:            41,
+				Proof: pow.Proof{
+					EdgeBits: 29,
+					Nonces: []uint64{
+						4391451, 36730677, 38198400, 38797304, 60700446, 72910191, 73050441,
+						110099816, 140885802, 145512513, 149311222, 149994636, 157557529, 160778700,
+						162870981, 179649435, 194194460, 227378628, 230933064, 252046196, 272053956,
+						277878683, 288331253, 290266880, 293973036, 305315023, 321927758, 353841539,
+						356489212, 373843111, 381697287, 389274717, 403108317, 409994705, 411629694,
+						431823422, 441976653, 521469643, 521868369, 523044572, 524964447, 530250249,
+					},
+				},
+			},
+		},
+		Body: TransactionBody{
+			Inputs: []Input{},
+			Outputs: []Output{{
+				Features: CoinbaseOutput,
+				Commit:   "08b7e57c448db5ef25aa119dde2312c64d7ff1b890c416c6dda5ec73cbfed2edea",
+				Proof:    "9330ad8cde205f317c6537eca96b866293a0489615a9a277b4d3a597c873544c82474932b641e06ac8719604ee52e895e8cd4621b6bfb85780cd9becce14d0700b83a664db2f52a26c425fd777ad88944cdfff38043a2793ed4d9aa67e36cbfd5585579fc69dda930418af5eaf603654f6f751258d2dfc8c2113c171e130f31ec1e6cce2a718e435298fce5d64ffe1bd3464fd7c87cfa92093855be034bfe4439e928bd92ad77fd0a0e00355ee1d1a9ceb1ed0c408dcfdba8c583e7598dc700aaa9f91432097259a405f5b7315a2f7658861e3349bb0dc8bf883726a215f0149ded6613e5ac0670c0c5202247d7c27c8a7d03bdb03c9cf5455463f9b42cf87403e31f8383cc4f49a34c62ae459f5801a9eed4f0ee3dfd5f55b7011c0cae393c474abd6f8c7965b9b5fff3104dd4e39542077c0c8dd2f8ffceb6bb598512d90506d0a7184f20f1498cf458787f23284b54888c9be416d103f760406357a16b6d841a303d5c95b6b474d2d7f0fea0a2a76c897dd2110e9303f54684169421147684c6f1819c33cef3f38ec995a508450c02cd1872f8065fdee723109c18b1dd2ddde75825546ecf0df0793c353b20c946cd64122cea8c116f432336899a16ad24a2aafcb8f900e09a1147135fcf2a54cbf81db308a47a08a49c77c130e5dc5e661cd55a5cc69e607055a5b08111bf61a62ea5778f85119043633f1cab8c756d756c5a34851024ac311a596b1cd919bbca43226f0ba057f6b57de2f6955b0823c3826de7f6096c1c1b6b9b8e4063e1645c0bff32f80561aaa959d97120fbc2ecd9d2be28bd0c17811dc59a88049f6d8952ee9a0a0207693c89ca3ad1197e9bfdfc03be9d845aea8d663969217e3b494cee9e652bc9f8713e2fd5cb1843848f46c3a6ab024d0e3d57ca45454cdbda414adaa835fa147deb4ffb7129cf3a8d86726a0144794",
+			}},
+			Kernels: []TxKernel{{
+				Features:  CoinbaseKernel,
+				Excess:    "096385d86c5cfda718aa0b7295be0adf7e5ac051edfe130593a2a257f09f78a3b1",
+				ExcessSig: "142a6a482a0c64ba71ba216ba5361612696fc76fe8d5c03c79fae01cab29d050ed7567e9a6d4fbbc1b6c0bf4434b8450dafa3f29b31612fda815f6e4b2bcfd43",
+			}},
+		},
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/blockcypher/libgrin/v5/core/pmmr"
+)
+
+var (
+	// ErrInvalidPrevHash is returned when a header doesn't build on the last
+	// header of the header MMR
+	ErrInvalidPrevHash = errors.New("invalid previous hash")
+	// ErrInvalidPrevRoot is returned when the previous root of a header is not
+	// the root of the header MMR
+	ErrInvalidPrevRoot = errors.New("invalid previous root")
+	// ErrUnknownHeader is returned for a height beyond the header MMR
+	ErrUnknownHeader = errors.New("unknown header")
+)
+
+// The leaf of a header in the header MMR: its hash
+func (h *BlockHeader) leaf() (*pmmr.Hash, error) {
+	hash, err := h.Hash()
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := pmmr.HashFromHex(hash)
+	if err != nil {
+		return nil, err
+	}
+	return &leaf, nil
+}
+
+// HeaderMMR accumulates the hashes of a chain of headers from the genesis
+// header, the MMR whose root the PrevRoot of the next header commits to. The
+// zero value is empty, ready for the genesis header.
+type HeaderMMR struct {
+	mmr pmmr.VecPMMR
+	// Header hashes by height
+	hashes []pmmr.Hash
+}
+
+// Len is the number of headers in the MMR, the height of the next header
+func (m *HeaderMMR) Len() uint64 {
+	return uint64(len(m.hashes))
+}
+
+// HeaderHash returns the hex encoded hash of the header at the height
+func (m *HeaderMMR) HeaderHash(height uint64) (string, error) {
+	if height >= m.Len() {
+		return "", ErrUnknownHeader
+	}
+	return m.hashes[height].String(), nil
+}
+
+// Root is the root of the MMR, the PrevRoot of the next header
+func (m *HeaderMMR) Root() pmmr.Hash {
+	return m.mmr.Root()
+}
+
+// Apply appends the next header to the MMR after checking it builds on the
+// last header: its height, previous hash and previous root. The previous root
+// of the genesis header is not checked. The header itself should have been
+// validated with ValidateHeader.
+func (m *HeaderMMR) Apply(header *BlockHeader) error {
+	if header.Height != m.Len() {
+		return ErrInvalidBlockHeight
+	}
+	if header.Height > 0 {
+		if !strings.EqualFold(header.PrevHash, m.hashes[header.Height-1].String()) {
+			return ErrInvalidPrevHash
+		}
+		prevRoot, err := pmmr.HashFromHex(header.PrevRoot)
+		if err != nil {
+			return err
+		}
+		if prevRoot != m.Root() {
+			return ErrInvalidPrevRoot
+		}
+	}
+	leaf, err := header.leaf()
+	if err != nil {
+		return err
+	}
+	if _, err := m.mmr.Push(leaf); err != nil {
+		return err
+	}
+	m.hashes = append(m.hashes, *leaf)
+	return nil
+}
+
+// Rewind removes the headers from the height onward, to apply the headers of
+// a fork from that height
+func (m *HeaderMMR) Rewind(height uint64) error {
+	if height > m.Len() {
+		return ErrUnknownHeader
+	}
+	if err := m.mmr.Truncate(pmmr.InsertionToPMMRIndex(height)); err != nil {
+		return err
+	}
+	m.hashes = m.hashes[:height]
+	return nil
+}
+
+// MerkleProof builds the Merkle proof of the header at the height against the
+// root of the MMR, which is the PrevRoot of the next header
+func (m *HeaderMMR) MerkleProof(height uint64) (pmmr.MerkleProof, error) {
+	if height >= m.Len() {
+		return pmmr.MerkleProof{}, ErrUnknownHeader
+	}
+	return m.mmr.MerkleProof(pmmr.InsertionToPMMRIndex(height))
+}
+
+// VerifyMerkleProof verifies that the header is in a header MMR with the hex
+// encoded root, such as the PrevRoot of a later header, returning
+// pmmr.ErrRootMismatch otherwise
+func (h *BlockHeader) VerifyMerkleProof(root string, proof *pmmr.MerkleProof) error {
+	rootHash, err := pmmr.HashFromHex(root)
+	if err != nil {
+		return err
+	}
+	leaf, err := h.leaf()
+	if err != nil {
+		return err
+	}
+	return proof.Verify(rootHash, leaf, pmmr.InsertionToPMMRIndex(h.Height))
+}
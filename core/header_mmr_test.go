@@ -0,0 +1,90 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/blockcypher/libgrin/v5/core/pmmr"
+	"github.com/stretchr/testify/assert"
+)
+
+// Builds the next header of the header MMR. The header hash only covers the
+// proof, so each header gets its own proof, with a fork number to build forks.
+func nextTestHeader(t *testing.T, m *HeaderMMR, fork uint64) BlockHeader {
+	header := mainnetGenesisHeader
+	header.Height = m.Len()
+	prevHash, err := m.HeaderHash(header.Height - 1)
+	assert.NoError(t, err)
+	header.PrevHash = prevHash
+	header.PrevRoot = m.Root().String()
+	header.PoW.Proof.Nonces = []uint64{header.Height, fork}
+	return header
+}
+
+func TestHeaderMMR(t *testing.T) {
+	var m HeaderMMR
+	assert.Equal(t, pmmr.ZeroHash, m.Root())
+	assert.NoError(t, m.Apply(&mainnetGenesisHeader))
+	assert.Equal(t, uint64(1), m.Len())
+	// The root of the genesis header alone is the hash of its position and
+	// hash
+	genesisHash, err := hex.DecodeString("0000000000000000" + "40adad0aec27797b48840aa9e00472015c21baea118ce7a2ff1a82c0f8f5bf82")
+	assert.NoError(t, err)
+	assert.Equal(t, blake2bHex(genesisHash), m.Root().String())
+
+	var headers []BlockHeader
+	headers = append(headers, mainnetGenesisHeader)
+	for i := 0; i < 10; i++ {
+		header := nextTestHeader(t, &m, 0)
+		assert.NoError(t, m.Apply(&header))
+		headers = append(headers, header)
+	}
+	assert.Equal(t, uint64(11), m.Len())
+
+	next := nextTestHeader(t, &m, 0)
+	tampered := next
+	tampered.Height++
+	assert.Equal(t, ErrInvalidBlockHeight, m.Apply(&tampered))
+	tampered = next
+	tampered.PrevHash = headers[9].PrevHash
+	assert.Equal(t, ErrInvalidPrevHash, m.Apply(&tampered))
+	tampered = next
+	tampered.PrevRoot = headers[10].PrevRoot
+	assert.Equal(t, ErrInvalidPrevRoot, m.Apply(&tampered))
+
+	// Every header is proven against the previous root of the next header
+	for height := range headers {
+		proof, err := m.MerkleProof(uint64(height))
+		assert.NoError(t, err)
+		assert.NoError(t, headers[height].VerifyMerkleProof(next.PrevRoot, &proof))
+		assert.Equal(t, pmmr.ErrRootMismatch, headers[height].VerifyMerkleProof(headers[10].PrevRoot, &proof))
+	}
+	_, err = m.MerkleProof(11)
+	assert.Equal(t, ErrUnknownHeader, err)
+
+	// A fork from height 6
+	assert.NoError(t, m.Rewind(6))
+	assert.Equal(t, uint64(6), m.Len())
+	assert.Equal(t, headers[6].PrevRoot, m.Root().String())
+	fork := nextTestHeader(t, &m, 1)
+	assert.NoError(t, m.Apply(&fork))
+	proof, err := m.MerkleProof(6)
+	assert.NoError(t, err)
+	assert.NoError(t, fork.VerifyMerkleProof(m.Root().String(), &proof))
+	assert.Equal(t, pmmr.ErrRootMismatch, headers[6].VerifyMerkleProof(m.Root().String(), &proof))
+	assert.Equal(t, ErrUnknownHeader, m.Rewind(8))
+}
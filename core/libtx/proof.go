@@ -0,0 +1,135 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package libtx implements the building blocks of the wallet side of
+// transactions, such as the range proofs of the outputs of a wallet, the same
+// way grin-wallet does.
+package libtx
+
+import (
+	"errors"
+
+	"github.com/blockcypher/libgrin/v5/keychain"
+	"github.com/blockcypher/libgrin/v5/util/secp"
+	"golang.org/x/crypto/blake2b"
+)
+
+// ErrUnknownProofMessage is returned when a rewound range proof message
+// wasn't built by the proof builder
+var ErrUnknownProofMessage = errors.New("unknown range proof message")
+
+// ProofBuilder derives the nonces and messages of the range proofs of a
+// wallet from its root key, as grin-wallet does. The rewind nonce only
+// depends on the root public key, so that the wallet outputs can be found
+// and rewound from the UTXO set when restoring from seed.
+type ProofBuilder struct {
+	rewindHash  [32]byte
+	privateHash [32]byte
+}
+
+// NewProofBuilder creates the proof builder of the wallet whose root key is
+// the secret key of the root extended key
+func NewProofBuilder(rootKey secp.SecretKey) (*ProofBuilder, error) {
+	pubKey, err := rootKey.PubKey()
+	if err != nil {
+		return nil, err
+	}
+	return &ProofBuilder{
+		rewindHash:  blake2b.Sum256(pubKey.SerializeCompressed()),
+		privateHash: blake2b.Sum256(rootKey[:]),
+	}, nil
+}
+
+// Hashes the hash of the root key keyed with the commitment to get the nonce
+func (b *ProofBuilder) nonce(commit secp.Commitment, hash *[32]byte) secp.SecretKey {
+	// The commitment is shorter than the maximum key size
+	h, _ := blake2b.New256(commit[:])
+	h.Write(hash[:])
+	var nonce secp.SecretKey
+	h.Sum(nonce[:0])
+	return nonce
+}
+
+// RewindNonce is the nonce with which the range proof of the commitment can
+// be rewound
+func (b *ProofBuilder) RewindNonce(commit secp.Commitment) secp.SecretKey {
+	return b.nonce(commit, &b.rewindHash)
+}
+
+// PrivateNonce is the secret nonce of the range proof of the commitment
+func (b *ProofBuilder) PrivateNonce(commit secp.Commitment) secp.SecretKey {
+	return b.nonce(commit, &b.privateHash)
+}
+
+// ProofMessage embeds the key identifier and switch commitment type of an
+// output in its range proof message: two zero bytes, the switch commitment
+// type and the identifier
+func (b *ProofBuilder) ProofMessage(keyID keychain.Identifier, switchType keychain.SwitchCommitmentType) secp.ProofMessage {
+	var msg secp.ProofMessage
+	msg[2] = uint8(switchType)
+	copy(msg[3:], keyID[:])
+	return msg
+}
+
+// CheckOutput recovers the key identifier and switch commitment type from a
+// range proof message built by the proof builder
+func (b *ProofBuilder) CheckOutput(msg secp.ProofMessage) (keychain.Identifier, keychain.SwitchCommitmentType, error) {
+	if msg[0] != 0 || msg[1] != 0 {
+		return keychain.Identifier{}, 0, ErrUnknownProofMessage
+	}
+	switchType, err := keychain.SwitchCommitmentTypeFromByte(msg[2])
+	if err != nil {
+		return keychain.Identifier{}, 0, ErrUnknownProofMessage
+	}
+	depth := msg[3]
+	if depth > 4 {
+		depth = 4
+	}
+	return keychain.IdentifierFromSerializedPath(depth, msg[4:]), switchType, nil
+}
+
+// RewoundProof is what a wallet recovers from the range proof of one of its
+// outputs
+type RewoundProof struct {
+	Amount     uint64
+	KeyID      keychain.Identifier
+	SwitchType keychain.SwitchCommitmentType
+}
+
+// CreateProof creates the range proof of an output of the wallet, of the
+// amount with the blinding factor derived from the key identifier with the
+// switch commitment type
+func CreateProof(b *ProofBuilder, amount uint64, blind secp.SecretKey, keyID keychain.Identifier, switchType keychain.SwitchCommitmentType, extraData []byte) ([]byte, error) {
+	commit, err := secp.Commit(amount, blind)
+	if err != nil {
+		return nil, err
+	}
+	msg := b.ProofMessage(keyID, switchType)
+	return secp.CreateBulletproof(amount, blind, b.RewindNonce(commit), b.PrivateNonce(commit), extraData, &msg)
+}
+
+// RewindProof rewinds the range proof of an output, returning
+// secp.ErrProofRewind or ErrUnknownProofMessage if the output doesn't belong
+// to the wallet
+func RewindProof(b *ProofBuilder, commit secp.Commitment, extraData []byte, proof []byte) (RewoundProof, error) {
+	info, err := secp.RewindBulletproof(commit, proof, b.RewindNonce(commit), extraData)
+	if err != nil {
+		return RewoundProof{}, err
+	}
+	keyID, switchType, err := b.CheckOutput(info.Message)
+	if err != nil {
+		return RewoundProof{}, err
+	}
+	return RewoundProof{Amount: info.Value, KeyID: keyID, SwitchType: switchType}, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libtx
+
+import (
+	"testing"
+
+	"github.com/blockcypher/libgrin/v5/keychain"
+	"github.com/blockcypher/libgrin/v5/util/secp"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProofBuilder(t *testing.T, rootKey string) *ProofBuilder {
+	key, err := secp.SecretKeyFromHex(rootKey)
+	assert.NoError(t, err)
+	builder, err := NewProofBuilder(key)
+	assert.NoError(t, err)
+	return builder
+}
+
+func TestProofMessage(t *testing.T) {
+	builder := newTestProofBuilder(t, "0101010101010101010101010101010101010101010101010101010101010101")
+	keyID := keychain.Identifier{3, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 7, 0, 0, 0, 0}
+	msg := builder.ProofMessage(keyID, keychain.SwitchCommitmentTypeRegular)
+	assert.Equal(t, secp.ProofMessage{0, 0, 1, 3, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 7, 0, 0, 0, 0}, msg)
+
+	id, switchType, err := builder.CheckOutput(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, keyID, id)
+	assert.Equal(t, keychain.SwitchCommitmentTypeRegular, switchType)
+
+	// The depth is at most 4
+	msg[3] = 9
+	id, _, err = builder.CheckOutput(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(4), id[0])
+
+	msg[1] = 1
+	_, _, err = builder.CheckOutput(msg)
+	assert.Equal(t, ErrUnknownProofMessage, err)
+	msg[1] = 0
+	msg[2] = 2
+	_, _, err = builder.CheckOutput(msg)
+	assert.Equal(t, ErrUnknownProofMessage, err)
+}
+
+func TestCreateRewindProof(t *testing.T) {
+	builder := newTestProofBuilder(t, "0101010101010101010101010101010101010101010101010101010101010101")
+	blind, err := secp.SecretKeyFromHex("2a4e35c8d6e2c7aeb2b1e6f15d0b8b6c7c0cbd2a1e5bb4f0a25b5d7e1f9b7c3d")
+	assert.NoError(t, err)
+	keyID := keychain.Identifier{3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 5, 0, 0, 0, 0}
+	amount := uint64(60000000000)
+
+	proof, err := CreateProof(builder, amount, blind, keyID, keychain.SwitchCommitmentTypeRegular, nil)
+	assert.NoError(t, err)
+	commit, err := secp.Commit(amount, blind)
+	assert.NoError(t, err)
+	assert.True(t, secp.VerifyBulletproof(commit, proof, nil))
+
+	rewound, err := RewindProof(builder, commit, nil, proof)
+	assert.NoError(t, err)
+	assert.Equal(t, RewoundProof{Amount: amount, KeyID: keyID, SwitchType: keychain.SwitchCommitmentTypeRegular}, rewound)
+
+	// Another wallet can't rewind the proof
+	other := newTestProofBuilder(t, "0202020202020202020202020202020202020202020202020202020202020202")
+	_, err = RewindProof(other, commit, nil, proof)
+	assert.Equal(t, secp.ErrProofRewind, err)
+
+	// Nor can it be rewound with other extra data
+	_, err = RewindProof(builder, commit, []byte{1}, proof)
+	assert.Equal(t, secp.ErrProofRewind, err)
+
+	proof, err = CreateProof(builder, amount, blind, keyID, keychain.SwitchCommitmentTypeNone, []byte{1})
+	assert.NoError(t, err)
+	assert.True(t, secp.VerifyBulletproof(commit, proof, []byte{1}))
+	rewound, err = RewindProof(builder, commit, []byte{1}, proof)
+	assert.NoError(t, err)
+	assert.Equal(t, keychain.SwitchCommitmentTypeNone, rewound.SwitchType)
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmmr
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+
+	"github.com/blockcypher/libgrin/v5/core/ser"
+	"golang.org/x/crypto/blake2b"
+)
+
+// HashSize is the size of a MMR node hash
+const HashSize = 32
+
+// Hash is the blake2b hash of a MMR node
+type Hash [HashSize]byte
+
+// ZeroHash is the root of an empty MMR
+var ZeroHash Hash
+
+// ErrInvalidHash is returned when parsing a hash of the wrong size
+var ErrInvalidHash = errors.New("invalid hash")
+
+// HashFromHex parses an hex encoded hash, such as the roots of a header
+func HashFromHex(s string) (Hash, error) {
+	var h Hash
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return h, err
+	}
+	if len(b) != HashSize {
+		return h, ErrInvalidHash
+	}
+	copy(h[:], b)
+	return h, nil
+}
+
+// String returns the hex encoding of the hash
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// Write serializes the hash
+func (h *Hash) Write(w *ser.Writer) error {
+	return w.WriteFixedBytes(h[:])
+}
+
+// Read deserializes a hash
+func (h *Hash) Read(r *ser.Reader) error {
+	b, err := r.ReadFixedBytes(HashSize)
+	if err != nil {
+		return err
+	}
+	copy(h[:], b)
+	return nil
+}
+
+// HashWithIndex hashes an element with its position, how leaves are hashed:
+// the blake2b hash of the position followed by the hash mode serialization
+// of the element
+func HashWithIndex(elem ser.Writeable, pos0 uint64) (Hash, error) {
+	b, err := ser.Serialize(elem, ser.CurrentProtocolVersion, ser.HashMode)
+	if err != nil {
+		return Hash{}, err
+	}
+	h, _ := blake2b.New256(nil)
+	var index [8]byte
+	binary.BigEndian.PutUint64(index[:], pos0)
+	h.Write(index[:])
+	h.Write(b)
+	var hash Hash
+	h.Sum(hash[:0])
+	return hash, nil
+}
+
+// Hashes the two children of a node with the position of the node, how
+// parents and bagged peaks are hashed
+func hashChildren(left, right Hash, pos0 uint64) Hash {
+	var b [8 + 2*HashSize]byte
+	binary.BigEndian.PutUint64(b[:8], pos0)
+	copy(b[8:], left[:])
+	copy(b[8+HashSize:], right[:])
+	return blake2b.Sum256(b[:])
+}
+
+// BagPeaks bags the hashes of the peaks of a MMR of the given size, from the
+// right, to get its root. The root of an empty MMR is the zero hash.
+func BagPeaks(peaks []Hash, size uint64) Hash {
+	if len(peaks) == 0 {
+		return ZeroHash
+	}
+	root := peaks[len(peaks)-1]
+	for i := len(peaks) - 2; i >= 0; i-- {
+		root = hashChildren(peaks[i], root, size)
+	}
+	return root
+}
@@ -0,0 +1,154 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmmr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"sort"
+
+	"github.com/blockcypher/libgrin/v5/core/ser"
+)
+
+// ErrRootMismatch is returned when a Merkle proof doesn't hash to the root
+var ErrRootMismatch = errors.New("merkle proof root mismatch")
+
+// Maximum length of the path of a Merkle proof: a branch to a peak and the
+// other peaks, at most 64 each
+const maxPathLength = 128
+
+// MerkleProof proves that an element is in a MMR
+type MerkleProof struct {
+	// The size of the MMR at the time the proof was created
+	MMRSize uint64
+	// The sibling path from the leaf up to the final sibling hashing to the
+	// root
+	Path []Hash
+}
+
+// Write serializes the Merkle proof: the MMR size and the length prefixed
+// path
+func (p *MerkleProof) Write(w *ser.Writer) error {
+	if err := w.WriteU64(p.MMRSize); err != nil {
+		return err
+	}
+	if err := w.WriteU64(uint64(len(p.Path))); err != nil {
+		return err
+	}
+	for i := range p.Path {
+		if err := p.Path[i].Write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read deserializes a Merkle proof
+func (p *MerkleProof) Read(r *ser.Reader) error {
+	mmrSize, err := r.ReadU64()
+	if err != nil {
+		return err
+	}
+	pathLength, err := r.ReadU64()
+	if err != nil {
+		return err
+	}
+	if pathLength > maxPathLength {
+		return ser.ErrTooLargeRead
+	}
+	path := make([]Hash, pathLength)
+	for i := range path {
+		if err := path[i].Read(r); err != nil {
+			return err
+		}
+	}
+	*p = MerkleProof{MMRSize: mmrSize, Path: path}
+	return nil
+}
+
+// MerkleProofFromHex parses the hex encoded Merkle proof of the node API
+func MerkleProofFromHex(s string) (MerkleProof, error) {
+	var p MerkleProof
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return p, err
+	}
+	if err := p.Read(ser.NewReader(bytes.NewReader(b), ser.CurrentProtocolVersion)); err != nil {
+		return MerkleProof{}, err
+	}
+	return p, nil
+}
+
+// Hex returns the hex encoding of the Merkle proof, as in the node API
+func (p *MerkleProof) Hex() (string, error) {
+	b, err := ser.Serialize(p, ser.CurrentProtocolVersion, ser.FullMode)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Verify verifies that the element is the leaf at the position of the MMR
+// with the root, returning ErrRootMismatch otherwise
+func (p *MerkleProof) Verify(root Hash, elem ser.Writeable, pos0 uint64) error {
+	index := pos0
+	if index >= p.MMRSize {
+		index = p.MMRSize
+	}
+	nodeHash, err := HashWithIndex(elem, index)
+	if err != nil {
+		return err
+	}
+	return p.VerifyHash(root, nodeHash, pos0)
+}
+
+// VerifyHash verifies that the node hash is the hash of the node at the
+// position of the MMR with the root, returning ErrRootMismatch otherwise
+func (p *MerkleProof) VerifyHash(root, nodeHash Hash, pos0 uint64) error {
+	// The peaks only depend on the size of the MMR
+	peaks := Peaks(p.MMRSize)
+	for _, sibling := range p.Path {
+		parentPos, siblingPos := Family(pos0)
+		var left, right Hash
+		peak := sort.Search(len(peaks), func(i int) bool { return peaks[i] >= pos0 })
+		switch {
+		case peak < len(peaks) && peaks[peak] == pos0:
+			// The sibling of a peak is the bagged peaks on its right, except
+			// for the last peak whose sibling is the peak on its left
+			if peak == len(peaks)-1 {
+				left, right = sibling, nodeHash
+			} else {
+				left, right = nodeHash, sibling
+			}
+		case parentPos >= p.MMRSize:
+			left, right = sibling, nodeHash
+		case IsLeftSibling(siblingPos):
+			left, right = sibling, nodeHash
+		default:
+			left, right = nodeHash, sibling
+		}
+		pos0 = parentPos
+		index := pos0
+		if index >= p.MMRSize {
+			index = p.MMRSize
+		}
+		nodeHash = hashChildren(left, right, index)
+	}
+	if nodeHash != root {
+		return ErrRootMismatch
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmmr
+
+import (
+	"testing"
+
+	"github.com/blockcypher/libgrin/v5/core/ser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerkleProofHex(t *testing.T) {
+	var m VecPMMR
+	for i := uint32(0); i < 15; i++ {
+		_, err := m.Push(&testElem{0, 0, 0, i})
+		assert.NoError(t, err)
+	}
+	proof, err := m.MerkleProof(8)
+	assert.NoError(t, err)
+	s, err := proof.Hex()
+	assert.NoError(t, err)
+	// Size, path length and path
+	assert.Len(t, s, 2*(8+8+HashSize*len(proof.Path)))
+	read, err := MerkleProofFromHex(s)
+	assert.NoError(t, err)
+	assert.Equal(t, proof, read)
+
+	_, err = MerkleProofFromHex(s[:len(s)-2])
+	assert.Error(t, err)
+	_, err = MerkleProofFromHex("0000000000000001ffffffffffffffff")
+	assert.Equal(t, ser.ErrTooLargeRead, err)
+
+	empty, err := MerkleProofFromHex("00000000000000000000000000000000")
+	assert.NoError(t, err)
+	assert.Equal(t, MerkleProof{Path: []Hash{}}, empty)
+}
+
+func TestMerkleProofVerify(t *testing.T) {
+	var m VecPMMR
+	var elems []testElem
+	var positions []uint64
+	for n := uint32(1); n <= 33; n++ {
+		elems = append(elems, testElem{0, 0, 0, n})
+		pos0, err := m.Push(&elems[len(elems)-1])
+		assert.NoError(t, err)
+		positions = append(positions, pos0)
+
+		// Every leaf can be proven at every size
+		root := m.Root()
+		for i, pos0 := range positions {
+			proof, err := m.MerkleProof(pos0)
+			assert.NoError(t, err)
+			assert.NoError(t, proof.Verify(root, &elems[i], pos0), "leaf %d of %d", i, n)
+			if n > 1 {
+				other := (i + 1) % len(elems)
+				assert.Equal(t, ErrRootMismatch, proof.Verify(root, &elems[other], pos0))
+				assert.Equal(t, ErrRootMismatch, proof.Verify(root, &elems[i], positions[other]))
+			}
+		}
+	}
+
+	proof, err := m.MerkleProof(positions[5])
+	assert.NoError(t, err)
+	assert.Equal(t, ErrRootMismatch, proof.Verify(ZeroHash, &elems[5], positions[5]))
+	proof.Path[0][0] ^= 1
+	assert.Equal(t, ErrRootMismatch, proof.Verify(m.Root(), &elems[5], positions[5]))
+}
@@ -0,0 +1,195 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pmmr implements grin's Merkle Mountain Ranges: the position math,
+// the hashing of nodes with their position, the bagging of the peaks to the
+// root committed to by the headers and the Merkle proofs of inclusion.
+//
+// As in grin, all positions are 0-based: a MMR of size n has nodes in
+// positions 0 through n-1. The MMR index of the node API is 1-based.
+package pmmr
+
+import "math/bits"
+
+// 64 bits all ones: 0b11111111...1
+const allOnes = ^uint64(0)
+
+// PeakMapHeight returns the peak bitmap and height of the next node in a MMR
+// of the given size. On size 4 it returns (0b11, 0) as the MMR of size 4 is
+//
+//	  2
+//	 / \
+//	0   1   3
+//
+// with 0b11 indicating the presence of peaks of height 0 and 1, and 0 the
+// height of the next node 4, which is a leaf.
+func PeakMapHeight(size uint64) (uint64, uint64) {
+	if size == 0 {
+		return 0, 0
+	}
+	peakSize := allOnes >> bits.LeadingZeros64(size)
+	var peakMap uint64
+	for peakSize != 0 {
+		peakMap <<= 1
+		if size >= peakSize {
+			size -= peakSize
+			peakMap |= 1
+		}
+		peakSize >>= 1
+	}
+	return peakMap, size
+}
+
+// PeakSizesHeight returns the sizes of the peaks and the height of the next
+// node in a MMR of the given size
+func PeakSizesHeight(size uint64) ([]uint64, uint64) {
+	if size == 0 {
+		return []uint64{}, 0
+	}
+	peakSize := allOnes >> bits.LeadingZeros64(size)
+	peakSizes := []uint64{}
+	for peakSize != 0 {
+		if size >= peakSize {
+			peakSizes = append(peakSizes, peakSize)
+			size -= peakSize
+		}
+		peakSize >>= 1
+	}
+	return peakSizes, size
+}
+
+// Peaks returns the positions of the peaks of a MMR of the given size, from
+// the highest on the left to the lowest on the right. A size whose next node
+// is not a leaf is not a valid MMR size and has no peaks.
+func Peaks(size uint64) []uint64 {
+	peakSizes, height := PeakSizesHeight(size)
+	if height != 0 {
+		return []uint64{}
+	}
+	peaks := make([]uint64, len(peakSizes))
+	var acc uint64
+	for i, peakSize := range peakSizes {
+		acc += peakSize
+		peaks[i] = acc - 1
+	}
+	return peaks
+}
+
+// NLeaves returns the number of leaves in a MMR of the given size
+func NLeaves(size uint64) uint64 {
+	peakMap, height := PeakMapHeight(size)
+	if height == 0 {
+		return peakMap
+	}
+	return peakMap + 1
+}
+
+// RoundUpToLeafPos returns the lowest leaf position at or after the position
+func RoundUpToLeafPos(pos0 uint64) uint64 {
+	insertIndex, height := PeakMapHeight(pos0)
+	if height != 0 {
+		insertIndex++
+	}
+	return InsertionToPMMRIndex(insertIndex)
+}
+
+// InsertionToPMMRIndex returns the position of the leaf of the 0-based
+// insertion index
+func InsertionToPMMRIndex(leafIndex uint64) uint64 {
+	return 2*leafIndex - uint64(bits.OnesCount64(leafIndex))
+}
+
+// PMMRLeafToInsertionIndex returns the insertion index of the leaf at the
+// position, or false if the position is not a leaf
+func PMMRLeafToInsertionIndex(pos0 uint64) (uint64, bool) {
+	insertIndex, height := PeakMapHeight(pos0)
+	return insertIndex, height == 0
+}
+
+// BintreePostorderHeight returns the height of the node at the position, the
+// leaves being at height 0
+func BintreePostorderHeight(pos0 uint64) uint64 {
+	_, height := PeakMapHeight(pos0)
+	return height
+}
+
+// IsLeaf returns whether the node at the position is a leaf
+func IsLeaf(pos0 uint64) bool {
+	return BintreePostorderHeight(pos0) == 0
+}
+
+// Family returns the positions of the parent and sibling of the node at the
+// position
+func Family(pos0 uint64) (uint64, uint64) {
+	peakMap, height := PeakMapHeight(pos0)
+	peak := uint64(1) << height
+	if peakMap&peak != 0 {
+		return pos0 + 1, pos0 + 1 - 2*peak
+	}
+	return pos0 + 2*peak, pos0 + 2*peak - 1
+}
+
+// IsLeftSibling returns whether the node at the position is the left child
+// of its parent
+func IsLeftSibling(pos0 uint64) bool {
+	peakMap, height := PeakMapHeight(pos0)
+	peak := uint64(1) << height
+	return peakMap&peak == 0
+}
+
+// Branch is the position of a parent and of the sibling of its child on the
+// path from a node to its peak
+type Branch struct {
+	Parent  uint64
+	Sibling uint64
+}
+
+// FamilyBranch returns the parents and siblings on the path from the node at
+// the position to its peak in a MMR of the given size. The siblings are the
+// path of a Merkle proof.
+func FamilyBranch(pos0, size uint64) []Branch {
+	peakMap, height := PeakMapHeight(pos0)
+	peak := uint64(1) << height
+	branch := []Branch{}
+	current := pos0
+	for current+1 < size {
+		var sibling uint64
+		if peakMap&peak != 0 {
+			current++
+			sibling = current - 2*peak
+		} else {
+			current += 2 * peak
+			sibling = current - 1
+		}
+		if current >= size {
+			break
+		}
+		branch = append(branch, Branch{Parent: current, Sibling: sibling})
+		peak <<= 1
+	}
+	return branch
+}
+
+// BintreeRightmost returns the position of the rightmost leaf beneath the
+// subtree root at the position
+func BintreeRightmost(pos0 uint64) uint64 {
+	return pos0 - BintreePostorderHeight(pos0)
+}
+
+// BintreeLeftmost returns the position of the leftmost leaf beneath the
+// subtree root at the position
+func BintreeLeftmost(pos0 uint64) uint64 {
+	height := BintreePostorderHeight(pos0)
+	return pos0 + 2 - (2 << height)
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmmr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeakMapHeight(t *testing.T) {
+	for size, expected := range [][2]uint64{
+		{0b0, 0}, {0b1, 0}, {0b1, 1}, {0b10, 0}, {0b11, 0}, {0b11, 1}, {0b11, 2}, {0b100, 0},
+	} {
+		peakMap, height := PeakMapHeight(uint64(size))
+		assert.Equal(t, expected, [2]uint64{peakMap, height})
+	}
+	peakMap, height := PeakMapHeight(allOnes)
+	assert.Equal(t, [2]uint64{allOnes>>1 + 1, 0}, [2]uint64{peakMap, height})
+	peakMap, height = PeakMapHeight(allOnes - 1)
+	assert.Equal(t, [2]uint64{allOnes >> 1, 63}, [2]uint64{peakMap, height})
+}
+
+func TestPeakSizesHeight(t *testing.T) {
+	sizes, height := PeakSizesHeight(0)
+	assert.Empty(t, sizes)
+	assert.Equal(t, uint64(0), height)
+	sizes, height = PeakSizesHeight(4)
+	assert.Equal(t, []uint64{3, 1}, sizes)
+	assert.Equal(t, uint64(0), height)
+	sizes, height = PeakSizesHeight(6)
+	assert.Equal(t, []uint64{3, 1}, sizes)
+	assert.Equal(t, uint64(2), height)
+	sizes, height = PeakSizesHeight(allOnes)
+	assert.Equal(t, []uint64{allOnes}, sizes)
+	assert.Equal(t, uint64(0), height)
+}
+
+func TestPeaks(t *testing.T) {
+	assert.Empty(t, Peaks(0))
+	assert.Equal(t, []uint64{0}, Peaks(1))
+	assert.Empty(t, Peaks(2))
+	assert.Equal(t, []uint64{2}, Peaks(3))
+	assert.Equal(t, []uint64{2, 3}, Peaks(4))
+	assert.Empty(t, Peaks(5))
+	assert.Empty(t, Peaks(6))
+	assert.Equal(t, []uint64{6}, Peaks(7))
+	assert.Equal(t, []uint64{6, 9, 10}, Peaks(11))
+}
+
+func TestPostorderHeights(t *testing.T) {
+	first100 := []uint64{
+		0, 0, 1, 0, 0, 1, 2, 0, 0, 1, 0, 0, 1, 2, 3, 0, 0, 1, 0, 0, 1, 2, 0, 0, 1, 0, 0, 1, 2, 3, 4,
+		0, 0, 1, 0, 0, 1, 2, 0, 0, 1, 0, 0, 1, 2, 3, 0, 0, 1, 0, 0, 1, 2, 0, 0, 1, 0, 0, 1, 2, 3, 4, 5,
+		0, 0, 1, 0, 0, 1, 2, 0, 0, 1, 0, 0, 1, 2, 3, 0, 0, 1, 0, 0, 1, 2, 0, 0, 1, 0, 0, 1, 2, 3, 4, 0, 0, 1, 0, 0,
+	}
+	for pos0, height := range first100 {
+		assert.Equal(t, height, BintreePostorderHeight(uint64(pos0)))
+		assert.Equal(t, height == 0, IsLeaf(uint64(pos0)))
+	}
+}
+
+func TestBintree(t *testing.T) {
+	rightmost := []uint64{0, 1, 1, 3, 4, 4, 4}
+	leftmost := []uint64{0, 1, 0, 3, 4, 3, 0}
+	for pos0 := range rightmost {
+		assert.Equal(t, rightmost[pos0], BintreeRightmost(uint64(pos0)))
+		assert.Equal(t, leftmost[pos0], BintreeLeftmost(uint64(pos0)))
+	}
+}
+
+func TestLeafIndex(t *testing.T) {
+	leaves := []uint64{0, 1, 3, 4, 7, 8, 10, 11, 15, 16, 18, 19, 22, 23, 25, 26, 31}
+	for leafIndex, pos0 := range leaves {
+		index, ok := PMMRLeafToInsertionIndex(pos0)
+		assert.True(t, ok)
+		assert.Equal(t, uint64(leafIndex), index)
+		assert.Equal(t, pos0, InsertionToPMMRIndex(uint64(leafIndex)))
+	}
+	_, ok := PMMRLeafToInsertionIndex(30)
+	assert.False(t, ok)
+
+	for size, n := range []uint64{0, 1, 2, 2, 3, 4, 4, 4, 5, 6, 6} {
+		assert.Equal(t, n, NLeaves(uint64(size)))
+	}
+	for pos0, leaf := range []uint64{0, 1, 3, 3, 4, 7, 7, 7, 8, 10, 10} {
+		assert.Equal(t, leaf, RoundUpToLeafPos(uint64(pos0)))
+	}
+}
+
+func TestFamily(t *testing.T) {
+	for pos0, expected := range [][2]uint64{{2, 1}, {2, 0}, {6, 5}, {5, 4}, {5, 3}, {6, 2}, {14, 13}} {
+		parent, sibling := Family(uint64(pos0))
+		assert.Equal(t, expected, [2]uint64{parent, sibling})
+	}
+	parent, sibling := Family(999)
+	assert.Equal(t, [2]uint64{1000, 996}, [2]uint64{parent, sibling})
+
+	assert.True(t, IsLeftSibling(0))
+	assert.False(t, IsLeftSibling(1))
+	assert.True(t, IsLeftSibling(2))
+}
+
+func TestFamilyBranch(t *testing.T) {
+	assert.Equal(t, []Branch{{2, 1}}, FamilyBranch(0, 3))
+	assert.Equal(t, []Branch{{2, 0}}, FamilyBranch(1, 3))
+	assert.Empty(t, FamilyBranch(2, 3))
+	assert.Equal(t, []Branch{{2, 1}, {6, 5}}, FamilyBranch(0, 7))
+	assert.Equal(t, []Branch{{2, 1}}, FamilyBranch(0, 4))
+	assert.Empty(t, FamilyBranch(3, 4))
+	assert.Empty(t, FamilyBranch(3, 5))
+	assert.Equal(t, []Branch{{5, 4}}, FamilyBranch(3, 6))
+	assert.Equal(t, []Branch{{5, 4}, {6, 2}}, FamilyBranch(3, 7))
+
+	branch := FamilyBranch(0, 1049000)
+	assert.Len(t, branch, 19)
+	for i, b := range branch {
+		assert.Equal(t, Branch{uint64(4)<<i - 2, uint64(4)<<i - 3}, b)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmmr
+
+import (
+	"errors"
+
+	"github.com/blockcypher/libgrin/v5/core/ser"
+)
+
+var (
+	// ErrNotLeaf is returned when a Merkle proof is requested for a node
+	// which is not a leaf
+	ErrNotLeaf = errors.New("not a leaf")
+	// ErrNoElement is returned when a position is beyond the size of the MMR
+	ErrNoElement = errors.New("no element at position")
+)
+
+// VecPMMR is an in-memory MMR keeping the hashes of all its nodes, enough to
+// compute its root and the Merkle proofs of its leaves. The zero value is an
+// empty MMR.
+type VecPMMR struct {
+	hashes []Hash
+}
+
+// Size is the number of nodes of the MMR
+func (m *VecPMMR) Size() uint64 {
+	return uint64(len(m.hashes))
+}
+
+// Push appends an element to the MMR, returning the position of its leaf
+func (m *VecPMMR) Push(elem ser.Writeable) (uint64, error) {
+	pos0 := m.Size()
+	leafHash, err := HashWithIndex(elem, pos0)
+	if err != nil {
+		return 0, err
+	}
+	m.hashes = append(m.hashes, leafHash)
+
+	// Hash the new leaf up with the peaks on its left of the same height
+	peakMap, _ := PeakMapHeight(pos0)
+	current := leafHash
+	pos := pos0
+	for peak := uint64(1); peakMap&peak != 0; peak <<= 1 {
+		leftSibling := m.hashes[pos+1-2*peak]
+		pos++
+		current = hashChildren(leftSibling, current, pos)
+		m.hashes = append(m.hashes, current)
+	}
+	return pos0, nil
+}
+
+// Hash returns the hash of the node at the position
+func (m *VecPMMR) Hash(pos0 uint64) (Hash, error) {
+	if pos0 >= m.Size() {
+		return Hash{}, ErrNoElement
+	}
+	return m.hashes[pos0], nil
+}
+
+// Truncate rewinds the MMR to a previous size, which must be a valid MMR size
+func (m *VecPMMR) Truncate(size uint64) error {
+	if size > m.Size() {
+		return ErrNoElement
+	}
+	if _, height := PeakMapHeight(size); height != 0 {
+		return ErrNotLeaf
+	}
+	m.hashes = m.hashes[:size]
+	return nil
+}
+
+// Peaks returns the hashes of the peaks of the MMR, from left to right
+func (m *VecPMMR) Peaks() []Hash {
+	positions := Peaks(m.Size())
+	peaks := make([]Hash, len(positions))
+	for i, pos0 := range positions {
+		peaks[i] = m.hashes[pos0]
+	}
+	return peaks
+}
+
+// Root returns the root of the MMR, its bagged peaks
+func (m *VecPMMR) Root() Hash {
+	return BagPeaks(m.Peaks(), m.Size())
+}
+
+// MerkleProof builds the Merkle proof of the leaf at the position: the
+// siblings up to its peak, then the bagged peaks on its right and the peaks
+// on its left
+func (m *VecPMMR) MerkleProof(pos0 uint64) (MerkleProof, error) {
+	size := m.Size()
+	if pos0 >= size {
+		return MerkleProof{}, ErrNoElement
+	}
+	if !IsLeaf(pos0) {
+		return MerkleProof{}, ErrNotLeaf
+	}
+	branch := FamilyBranch(pos0, size)
+	path := make([]Hash, 0, len(branch))
+	for _, b := range branch {
+		path = append(path, m.hashes[b.Sibling])
+	}
+	peakPos := pos0
+	if len(branch) > 0 {
+		peakPos = branch[len(branch)-1].Parent
+	}
+
+	// The peaks on the right are bagged together, the peaks on the left come
+	// after them from right to left
+	var left, right []Hash
+	for _, p := range Peaks(size) {
+		if p < peakPos {
+			left = append(left, m.hashes[p])
+		} else if p > peakPos {
+			right = append(right, m.hashes[p])
+		}
+	}
+	if len(right) > 0 {
+		path = append(path, BagPeaks(right, size))
+	}
+	for i := len(left) - 1; i >= 0; i-- {
+		path = append(path, left[i])
+	}
+	return MerkleProof{MMRSize: size, Path: path}, nil
+}
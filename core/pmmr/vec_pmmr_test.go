@@ -0,0 +1,109 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmmr
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/blockcypher/libgrin/v5/core/ser"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test MMR element of four integers
+type testElem [4]uint32
+
+func (e *testElem) Write(w *ser.Writer) error {
+	for _, n := range e {
+		if err := w.WriteU32(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes raw bytes, such as an output identifier
+type testBytes []byte
+
+func (b testBytes) Write(w *ser.Writer) error {
+	return w.WriteFixedBytes(b)
+}
+
+func leafHash(t *testing.T, elem ser.Writeable, pos0 uint64) Hash {
+	h, err := HashWithIndex(elem, pos0)
+	assert.NoError(t, err)
+	return h
+}
+
+func TestGenesisOutputRoot(t *testing.T) {
+	// The mainnet genesis coinbase output identifier: its features and
+	// commitment
+	id, err := hex.DecodeString("0108b7e57c448db5ef25aa119dde2312c64d7ff1b890c416c6dda5ec73cbfed2edea")
+	assert.NoError(t, err)
+	var m VecPMMR
+	pos0, err := m.Push(testBytes(id))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), pos0)
+	assert.Equal(t, "fa7566d275006c6c467876758f2bc87e4cebd2020ae9cf9f294c6217828d6872", m.Root().String())
+}
+
+func TestVecPMMR(t *testing.T) {
+	var m VecPMMR
+	assert.Equal(t, ZeroHash, m.Root())
+
+	elems := make([]testElem, 7)
+	for i := range elems {
+		elems[i] = testElem{0, 0, 0, uint32(i + 1)}
+		_, err := m.Push(&elems[i])
+		assert.NoError(t, err)
+	}
+	// 7 leaves, 3 peaks, 11 nodes
+	assert.Equal(t, uint64(11), m.Size())
+	pos := []Hash{
+		leafHash(t, &elems[0], 0), leafHash(t, &elems[1], 1), {},
+		leafHash(t, &elems[2], 3), leafHash(t, &elems[3], 4), {}, {},
+		leafHash(t, &elems[4], 7), leafHash(t, &elems[5], 8), {},
+		leafHash(t, &elems[6], 10),
+	}
+	pos[2] = hashChildren(pos[0], pos[1], 2)
+	pos[5] = hashChildren(pos[3], pos[4], 5)
+	pos[6] = hashChildren(pos[2], pos[5], 6)
+	pos[9] = hashChildren(pos[7], pos[8], 9)
+	for i := range pos {
+		h, err := m.Hash(uint64(i))
+		assert.NoError(t, err)
+		assert.Equal(t, pos[i], h)
+	}
+	_, err := m.Hash(11)
+	assert.Equal(t, ErrNoElement, err)
+
+	assert.Equal(t, []Hash{pos[6], pos[9], pos[10]}, m.Peaks())
+	assert.Equal(t, hashChildren(pos[6], hashChildren(pos[9], pos[10], 11), 11), m.Root())
+
+	proof, err := m.MerkleProof(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []Hash{pos[1], pos[5], hashChildren(pos[9], pos[10], 11)}, proof.Path)
+	proof, err = m.MerkleProof(10)
+	assert.NoError(t, err)
+	assert.Equal(t, []Hash{pos[9], pos[6]}, proof.Path)
+	_, err = m.MerkleProof(2)
+	assert.Equal(t, ErrNotLeaf, err)
+
+	// Rewinding to the first three leaves
+	assert.Equal(t, ErrNotLeaf, m.Truncate(5))
+	assert.NoError(t, m.Truncate(4))
+	assert.Equal(t, hashChildren(pos[2], pos[3], 4), m.Root())
+	assert.Equal(t, ErrNoElement, m.Truncate(5))
+}
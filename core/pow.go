@@ -15,39 +15,29 @@
 package core
 
 import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
 	"github.com/blockcypher/libgrin/v5/core/consensus"
 	"github.com/blockcypher/libgrin/v5/core/pow"
 )
 
-const maxSols uint32 = 10
-
-func createPoWContext(chainType consensus.ChainType, height uint64, edgeBits uint8, proofSize int, nonces []uint64, maxSols uint32) (pow.PowContext, error) {
-	if chainType == consensus.Mainnet || chainType == consensus.Testnet {
-		// Mainnet and Testnet have Cuckatoo31+ for AF and Cuckaroo{,d,m,z}29 for AR
-		if edgeBits > 29 {
-			return pow.NewCuckatooCtx(chainType, edgeBits, proofSize, maxSols)
-		}
-		switch consensus.HeaderVersion(chainType, height) {
-		case 1:
-			return pow.NewCuckarooCtx(chainType, edgeBits, proofSize)
-		case 2:
-			return pow.NewCuckaroodCtx(chainType, edgeBits, proofSize)
-		case 3:
-			return pow.NewCuckaroomCtx(chainType, edgeBits, proofSize)
-		case 4:
-			return pow.NewCuckaroozCtx(chainType, edgeBits, proofSize)
-		default:
-			return pow.NoCuckarooCtx()
-		}
-	}
-	// Everything else is Cuckatoo only
-	return pow.NewCuckatooCtx(chainType, edgeBits, proofSize, maxSols)
+// Creates the PoW context of a proof at a height: Cuckatoo for the graphs
+// above SecondPoWEdgeBits, the secondary PoW of the fork active at the height
+// otherwise. Mainnet and Testnet have Cuckatoo31+ for AF and
+// Cuckaroo{,d,m,z}29 for AR, everything else is Cuckatoo only.
+func createPoWContext(chainType consensus.ChainType, height uint64, edgeBits uint8, proofSize int) (pow.PowContext, error) {
+	return pow.NewContext(chainType, consensus.ForkAt(chainType, height).SecondaryPoW, edgeBits, proofSize)
 }
 
 // VerifySize validates the proof of work of a given header, and that the proof of work
-// satisfies the requirements of the header.
+// satisfies the requirements of the header. The chain type must be registered.
 func VerifySize(chainType consensus.ChainType, prePoW []uint8, bh *BlockHeader) error {
-	ctx, err := createPoWContext(chainType, bh.Height, bh.PoW.EdgeBits(), len(bh.PoW.Proof.Nonces), bh.PoW.Proof.Nonces, maxSols)
+	if !consensus.ChainTypeRegistered(chainType) {
+		return ErrUnknownChainType
+	}
+	ctx, err := createPoWContext(chainType, bh.Height, bh.PoW.EdgeBits(), len(bh.PoW.Proof.Nonces))
 	if err != nil {
 		return err
 	}
@@ -57,3 +47,31 @@ func VerifySize(chainType consensus.ChainType, prePoW []uint8, bh *BlockHeader)
 	}
 	return nil
 }
+
+// VerifyPoWBatch validates the proofs of work of the headers concurrently,
+// spreading them across GOMAXPROCS workers. Returns the error of each header,
+// nil when its proof of work is valid.
+func VerifyPoWBatch(chainType consensus.ChainType, headers []*BlockHeader) []error {
+	errs := make([]error, len(headers))
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(headers) {
+		workers = len(headers)
+	}
+	var next int64 = -1
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := atomic.AddInt64(&next, 1)
+				if i >= int64(len(headers)) {
+					return
+				}
+				errs[i] = headers[i].VerifyPoW(chainType)
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
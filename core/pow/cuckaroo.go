@@ -15,8 +15,6 @@
 package pow
 
 import (
-	"errors"
-
 	"github.com/blockcypher/libgrin/v5/core/consensus"
 )
 
@@ -29,10 +27,10 @@ func NewCuckarooCtx(chainType consensus.ChainType, edgeBits uint8, proofSize int
 
 // NoCuckarooCtx error returned for cuckaroo request beyond HardFork4
 func NoCuckarooCtx() (PowContext, error) {
-	return nil, errors.New("no cuckaroo past HardFork4")
+	return nil, ErrNoSecondaryPoW
 }
 
-// CuckarooContext is a Cuckatoo cycle context. Only includes the verifier for now.
+// CuckarooContext is a Cuckaroo cycle context, verifier and solver.
 type CuckarooContext struct {
 	chainType consensus.ChainType
 	params    CuckooParams
@@ -43,21 +41,42 @@ func (c *CuckarooContext) SetHeaderNonce(header []uint8, nonce *uint32) {
 	c.params.resetHeaderNonce(header, nonce)
 }
 
+// Solve finds the cycles of the bipartite Cuckaroo graph
+func (c *CuckarooContext) Solve() ([]Proof, error) {
+	// Check before hashing every edge of the graph
+	if c.params.edgeBits > MaxSolverEdgeBits {
+		return nil, ErrGraphTooBig
+	}
+	edges := sipHashBlockEdges(c.params.siphashKeys, c.params.numEdges, 21, false)
+	g := cycleGraph{
+		proofSize: c.params.proofSize,
+		ports: func(nonce uint64) [2]uint64 {
+			edge := edges[nonce]
+			return [2]uint64{(edge & c.params.nodeMask) << 1, ((edge>>32)&c.params.nodeMask)<<1 | 1}
+		},
+		link: func(key uint64) uint64 {
+			return key
+		},
+	}
+	return g.findCycles(c.params.edgeBits, c.Verify)
+}
+
 // Verify verifies the Cuckatoo context.
 func (c *CuckarooContext) Verify(proof Proof) error {
-	if proof.proofSize() != consensus.ChainTypeProofSize(c.chainType) {
-		return errors.New("wrong cycle length")
+	if proof.ProofSize() != consensus.ChainTypeProofSize(c.chainType) {
+		return ErrWrongCycleLength
 	}
 	nonces := proof.Nonces
-	uvs := make([]uint64, 2*proof.proofSize())
+	var buf [2 * consensus.MaxProofSize]uint64
+	uvs := buf[:2*proof.ProofSize()]
 	var xor0, xor1 uint64
 
-	for n := 0; n < proof.proofSize(); n++ {
+	for n := 0; n < proof.ProofSize(); n++ {
 		if nonces[n] > c.params.edgeMask {
-			return errors.New("edge too big")
+			return ErrEdgeTooBig
 		}
 		if n > 0 && nonces[n] <= nonces[n-1] {
-			return errors.New("edges not ascending")
+			return ErrEdgesNotAscending
 		}
 		// 21 is standard siphash rotation constant
 		edge := SipHashBlock(c.params.siphashKeys, nonces[n], 21, false)
@@ -68,7 +87,7 @@ func (c *CuckarooContext) Verify(proof Proof) error {
 	}
 
 	if xor0|xor1 != 0 {
-		return errors.New("endpoints don't match up")
+		return ErrEndpointsMismatch
 	}
 
 	var i, j, n int
@@ -77,20 +96,22 @@ func (c *CuckarooContext) Verify(proof Proof) error {
 		j = i
 		k := j
 		for {
-			k = (k + 2) % (2 * c.params.proofSize)
+			if k += 2; k >= 2*c.params.proofSize {
+				k -= 2 * c.params.proofSize
+			}
 			if k == i {
 				break
 			}
 			if uvs[k] == uvs[i] {
 				// find other edge endpoint matching one at i
 				if j != i {
-					return errors.New("branch in cycle")
+					return ErrBranchInCycle
 				}
 				j = k
 			}
 		}
 		if j == i {
-			return errors.New("cycle dead ends")
+			return ErrCycleDeadEnds
 		}
 		i = j ^ 1
 		n++
@@ -101,5 +122,5 @@ func (c *CuckarooContext) Verify(proof Proof) error {
 	if n == c.params.proofSize {
 		return nil
 	}
-	return errors.New("cycle too short")
+	return ErrCycleTooShort
 }
@@ -53,13 +53,12 @@ var v2_19Sol = []uint64{
 }
 
 func TestCuckaroo19Vectors(t *testing.T) {
-	proof := new(Proof)
 	ctx := newCuckarooImpl(consensus.Mainnet, 19, 42)
 	ctx.params.siphashKeys = v1_19Hash
-	assert.Nil(t, ctx.Verify(proof.new(v1_19Sol)))
+	assert.Nil(t, ctx.Verify(NewProof(19, v1_19Sol)))
 	ctx.params.siphashKeys = v2_19Hash
-	assert.Nil(t, ctx.Verify(proof.new(v2_19Sol)))
-	assert.NotNil(t, ctx.Verify(proof.new(v1_19Sol)))
+	assert.Nil(t, ctx.Verify(NewProof(19, v2_19Sol)))
+	assert.NotNil(t, ctx.Verify(NewProof(19, v1_19Sol)))
 }
 
 func newCuckarooImpl(chainType consensus.ChainType, edgeBits uint8, proofSize int) *CuckarooContext {
@@ -67,3 +66,15 @@ func newCuckarooImpl(chainType consensus.ChainType, edgeBits uint8, proofSize in
 	params := cp.new(edgeBits, edgeBits, proofSize)
 	return &CuckarooContext{chainType, params}
 }
+
+func BenchmarkCuckaroo19Verify(b *testing.B) {
+	ctx := newCuckarooImpl(consensus.Mainnet, 19, 42)
+	ctx.params.siphashKeys = v1_19Hash
+	proof := Proof{EdgeBits: 19, Nonces: v1_19Sol}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := ctx.Verify(proof); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
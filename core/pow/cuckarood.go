@@ -15,8 +15,6 @@
 package pow
 
 import (
-	"errors"
-
 	"github.com/blockcypher/libgrin/v5/core/consensus"
 )
 
@@ -29,7 +27,7 @@ func NewCuckaroodCtx(chainType consensus.ChainType, edgeBits uint8, proofSize in
 	return &CuckaroodContext{chainType, params}, nil
 }
 
-// CuckaroodContext is a Cuckatoo cycle context. Only includes the verifier for now.
+// CuckaroodContext is a Cuckarood cycle context, verifier and solver.
 type CuckaroodContext struct {
 	chainType consensus.ChainType
 	params    CuckooParams
@@ -40,26 +38,49 @@ func (c *CuckaroodContext) SetHeaderNonce(header []uint8, nonce *uint32) {
 	c.params.resetHeaderNonce(header, nonce)
 }
 
+// Solve finds the cycles of the Cuckarood graph, whose edges alternate
+// directions, the direction of an edge being the parity of its nonce
+func (c *CuckaroodContext) Solve() ([]Proof, error) {
+	// Check before hashing every edge of the graph
+	if c.params.edgeBits > MaxSolverEdgeBits {
+		return nil, ErrGraphTooBig
+	}
+	edges := sipHashBlockEdges(c.params.siphashKeys, c.params.numEdges, 25, false)
+	g := cycleGraph{
+		proofSize: c.params.proofSize,
+		ports: func(nonce uint64) [2]uint64 {
+			edge := edges[nonce]
+			dir := nonce & 1
+			return [2]uint64{(edge&c.params.nodeMask)<<2 | dir, ((edge>>32)&c.params.nodeMask)<<2 | 2 | dir}
+		},
+		link: func(key uint64) uint64 {
+			return key ^ 1
+		},
+	}
+	return g.findCycles(c.params.edgeBits, c.Verify)
+}
+
 // Verify verifies the Cuckatoo context.
 func (c *CuckaroodContext) Verify(proof Proof) error {
-	if proof.proofSize() != consensus.ChainTypeProofSize(c.chainType) {
-		return errors.New("wrong cycle length")
+	if proof.ProofSize() != consensus.ChainTypeProofSize(c.chainType) {
+		return ErrWrongCycleLength
 	}
 	nonces := proof.Nonces
-	uvs := make([]uint64, 2*proof.proofSize())
-	ndir := make([]uint64, 2)
+	var buf [2 * consensus.MaxProofSize]uint64
+	uvs := buf[:2*proof.ProofSize()]
+	var ndir [2]uint64
 	var xor0, xor1 uint64
 
-	for n := 0; n < proof.proofSize(); n++ {
+	for n := 0; n < proof.ProofSize(); n++ {
 		dir := uint(nonces[n] & 1)
-		if ndir[dir] >= uint64(proof.proofSize())/2 {
-			return errors.New("edges not balanced")
+		if ndir[dir] >= uint64(proof.ProofSize())/2 {
+			return ErrEdgesNotBalanced
 		}
 		if nonces[n] > c.params.edgeMask {
-			return errors.New("edge too big")
+			return ErrEdgeTooBig
 		}
 		if n > 0 && nonces[n] <= nonces[n-1] {
-			return errors.New("edges not ascending")
+			return ErrEdgesNotAscending
 		}
 		// cuckarood uses a non-standard siphash rotation constant 25 as anti-ASIC tweak
 		edge := SipHashBlock(c.params.siphashKeys, nonces[n], 25, false)
@@ -72,7 +93,7 @@ func (c *CuckaroodContext) Verify(proof Proof) error {
 	}
 
 	if xor0|xor1 != 0 {
-		return errors.New("endpoints don't match up")
+		return ErrEndpointsMismatch
 	}
 	var i, j, n int
 
@@ -83,13 +104,13 @@ func (c *CuckaroodContext) Verify(proof Proof) error {
 			if uvs[k] == uvs[i] {
 				// find reverse edge endpoint identical to one at i
 				if j != i {
-					return errors.New("branch in cycle")
+					return ErrBranchInCycle
 				}
 				j = k
 			}
 		}
 		if j == i {
-			return errors.New("cycle dead ends")
+			return ErrCycleDeadEnds
 		}
 		i = j ^ 1
 		n++
@@ -100,5 +121,5 @@ func (c *CuckaroodContext) Verify(proof Proof) error {
 	if n == c.params.proofSize {
 		return nil
 	}
-	return errors.New("cycle too short")
+	return ErrCycleTooShort
 }
@@ -55,19 +55,17 @@ var v2_29SolCuckarood = []uint64{
 var zero [42]uint64
 
 func TestCuckarood19Vectors(t *testing.T) {
-	proof := new(Proof)
 	ctx := newCuckaroodImpl(consensus.Mainnet, 19, 42)
 	ctx.params.siphashKeys = v1_19HashCuckarood
-	assert.Nil(t, ctx.Verify(proof.new(v1_19SolCuckarood)))
-	assert.NotNil(t, ctx.Verify(proof.zero(42)))
+	assert.Nil(t, ctx.Verify(NewProof(19, v1_19SolCuckarood)))
+	assert.Equal(t, ErrEdgesNotAscending, ctx.Verify(ZeroProof(19, 42)))
 }
 
 func TestCuckarood29Vectors(t *testing.T) {
-	proof := new(Proof)
 	ctx := newCuckaroodImpl(consensus.Mainnet, 29, 42)
 	ctx.params.siphashKeys = v2_29HashCuckarood
-	assert.Nil(t, ctx.Verify(proof.new(v2_29SolCuckarood)))
-	assert.NotNil(t, ctx.Verify(proof.zero(42)))
+	assert.Nil(t, ctx.Verify(NewProof(29, v2_29SolCuckarood)))
+	assert.Equal(t, ErrEdgesNotAscending, ctx.Verify(ZeroProof(29, 42)))
 }
 
 func newCuckaroodImpl(chainType consensus.ChainType, edgeBits uint8, proofSize int) *CuckaroodContext {
@@ -75,3 +73,15 @@ func newCuckaroodImpl(chainType consensus.ChainType, edgeBits uint8, proofSize i
 	params := cp.new(edgeBits, edgeBits-1, proofSize)
 	return &CuckaroodContext{chainType, params}
 }
+
+func BenchmarkCuckarood29Verify(b *testing.B) {
+	ctx := newCuckaroodImpl(consensus.Mainnet, 29, 42)
+	ctx.params.siphashKeys = v2_29HashCuckarood
+	proof := Proof{EdgeBits: 29, Nonces: v2_29SolCuckarood}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := ctx.Verify(proof); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -15,8 +15,6 @@
 package pow
 
 import (
-	"errors"
-
 	"github.com/blockcypher/libgrin/v5/core/consensus"
 )
 
@@ -29,7 +27,7 @@ func NewCuckaroomCtx(chainType consensus.ChainType, edgeBits uint8, proofSize in
 	return &CuckaroomContext{chainType, params}, nil
 }
 
-// CuckaroomContext is a Cuckaroom cycle context. Only includes the verifier for now.
+// CuckaroomContext is a Cuckaroom cycle context, verifier and solver.
 type CuckaroomContext struct {
 	chainType consensus.ChainType
 	params    CuckooParams
@@ -40,23 +38,45 @@ func (c *CuckaroomContext) SetHeaderNonce(header []uint8, nonce *uint32) {
 	c.params.resetHeaderNonce(header, nonce)
 }
 
+// Solve finds the cycles of the directed Cuckaroom graph, where an edge
+// leaving at a node is followed by an edge coming from that node
+func (c *CuckaroomContext) Solve() ([]Proof, error) {
+	// Check before hashing every edge of the graph
+	if c.params.edgeBits > MaxSolverEdgeBits {
+		return nil, ErrGraphTooBig
+	}
+	edges := sipHashBlockEdges(c.params.siphashKeys, c.params.numEdges, 21, true)
+	g := cycleGraph{
+		proofSize: c.params.proofSize,
+		ports: func(nonce uint64) [2]uint64 {
+			edge := edges[nonce]
+			return [2]uint64{(edge&c.params.nodeMask)<<1 | 1, ((edge >> 32) & c.params.nodeMask) << 1}
+		},
+		link: func(key uint64) uint64 {
+			return key ^ 1
+		},
+	}
+	return g.findCycles(c.params.edgeBits, c.Verify)
+}
+
 // Verify verifies the Cuckaroom context.
 func (c *CuckaroomContext) Verify(proof Proof) error {
-	if proof.proofSize() != consensus.ChainTypeProofSize(c.chainType) {
-		return errors.New("wrong cycle length")
+	if proof.ProofSize() != consensus.ChainTypeProofSize(c.chainType) {
+		return ErrWrongCycleLength
 	}
 	nonces := proof.Nonces
-	from := make([]uint64, proof.proofSize())
-	to := make([]uint64, proof.proofSize())
+	var fromBuf, toBuf [consensus.MaxProofSize]uint64
+	from := fromBuf[:proof.ProofSize()]
+	to := toBuf[:proof.ProofSize()]
 	var xorFrom uint64 = 0
 	var xorTo uint64 = 0
 
-	for n := 0; n < proof.proofSize(); n++ {
+	for n := 0; n < proof.ProofSize(); n++ {
 		if nonces[n] > c.params.edgeMask {
-			return errors.New("edge too big")
+			return ErrEdgeTooBig
 		}
 		if n > 0 && nonces[n] <= nonces[n-1] {
-			return errors.New("edges not ascending")
+			return ErrEdgesNotAscending
 		}
 		// 21 is standard siphash rotation constant
 		edge := SipHashBlock(c.params.siphashKeys, nonces[n], 21, true)
@@ -66,22 +86,23 @@ func (c *CuckaroomContext) Verify(proof Proof) error {
 		xorTo ^= to[n]
 	}
 	if xorFrom != xorTo {
-		return errors.New("endpoints don't match up")
+		return ErrEndpointsMismatch
 	}
-	visited := make([]bool, proof.proofSize())
+	var visitedBuf [consensus.MaxProofSize]bool
+	visited := visitedBuf[:proof.ProofSize()]
 	n := 0
 	i := 0
 	for {
 		// follow cycle
 		if visited[i] {
-			return errors.New("branch in cycle")
+			return ErrBranchInCycle
 		}
 		visited[i] = true
 		nexti := 0
 		for from[nexti] != to[i] {
 			nexti++
-			if nexti == proof.proofSize() {
-				return errors.New("cycle dead ends")
+			if nexti == proof.ProofSize() {
+				return ErrCycleDeadEnds
 			}
 		}
 		i = nexti
@@ -94,5 +115,5 @@ func (c *CuckaroomContext) Verify(proof Proof) error {
 	if n == c.params.proofSize {
 		return nil
 	}
-	return errors.New("cycle too short")
+	return ErrCycleTooShort
 }
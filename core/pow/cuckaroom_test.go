@@ -53,19 +53,17 @@ var v2_29SolCuckaroom = []uint64{
 }
 
 func TestCuckaroom19Vectors(t *testing.T) {
-	proof := new(Proof)
 	ctx := newCuckaroomImpl(consensus.Mainnet, 19, 42)
 	ctx.params.siphashKeys = v1_19HashCuckaroom
-	assert.Nil(t, ctx.Verify(proof.new(v1_19SolCuckaroom)))
-	assert.NotNil(t, ctx.Verify(proof.zero(42)))
+	assert.Nil(t, ctx.Verify(NewProof(19, v1_19SolCuckaroom)))
+	assert.Equal(t, ErrEdgesNotAscending, ctx.Verify(ZeroProof(19, 42)))
 }
 
 func TestCuckaroom29Vectors(t *testing.T) {
-	proof := new(Proof)
 	ctx := newCuckaroomImpl(consensus.Mainnet, 29, 42)
 	ctx.params.siphashKeys = v2_29HashCuckaroom
-	assert.Nil(t, ctx.Verify(proof.new(v2_29SolCuckaroom)))
-	assert.NotNil(t, ctx.Verify(proof.zero(42)))
+	assert.Nil(t, ctx.Verify(NewProof(29, v2_29SolCuckaroom)))
+	assert.Equal(t, ErrEdgesNotAscending, ctx.Verify(ZeroProof(29, 42)))
 }
 
 func newCuckaroomImpl(chainType consensus.ChainType, edgeBits uint8, proofSize int) *CuckaroomContext {
@@ -73,3 +71,15 @@ func newCuckaroomImpl(chainType consensus.ChainType, edgeBits uint8, proofSize i
 	params := cp.new(edgeBits, edgeBits, proofSize)
 	return &CuckaroomContext{chainType, params}
 }
+
+func BenchmarkCuckaroom29Verify(b *testing.B) {
+	ctx := newCuckaroomImpl(consensus.Mainnet, 29, 42)
+	ctx.params.siphashKeys = v2_29HashCuckaroom
+	proof := Proof{EdgeBits: 29, Nonces: v2_29SolCuckaroom}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := ctx.Verify(proof); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
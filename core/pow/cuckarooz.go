@@ -15,8 +15,6 @@
 package pow
 
 import (
-	"errors"
-
 	"github.com/blockcypher/libgrin/v5/core/consensus"
 )
 
@@ -29,7 +27,7 @@ func NewCuckaroozCtx(chainType consensus.ChainType, edgeBits uint8, proofSize in
 	return &CuckaroozContext{chainType, params}, nil
 }
 
-// CuckaroozContext is a Cuckarooz cycle context. Only includes the verifier for now.
+// CuckaroozContext is a Cuckarooz cycle context, verifier and solver.
 type CuckaroozContext struct {
 	chainType consensus.ChainType
 	params    CuckooParams
@@ -40,21 +38,42 @@ func (c *CuckaroozContext) SetHeaderNonce(header []uint8, nonce *uint32) {
 	c.params.resetHeaderNonce(header, nonce)
 }
 
+// Solve finds the cycles of the Cuckarooz graph, which is not bipartite
+func (c *CuckaroozContext) Solve() ([]Proof, error) {
+	// Check before hashing every edge of the graph
+	if c.params.edgeBits > MaxSolverEdgeBits {
+		return nil, ErrGraphTooBig
+	}
+	edges := sipHashBlockEdges(c.params.siphashKeys, c.params.numEdges, 21, true)
+	g := cycleGraph{
+		proofSize: c.params.proofSize,
+		ports: func(nonce uint64) [2]uint64 {
+			edge := edges[nonce]
+			return [2]uint64{edge & c.params.nodeMask, (edge >> 32) & c.params.nodeMask}
+		},
+		link: func(key uint64) uint64 {
+			return key
+		},
+	}
+	return g.findCycles(c.params.edgeBits, c.Verify)
+}
+
 // Verify verifies the Cuckaroom context.
 func (c *CuckaroozContext) Verify(proof Proof) error {
-	if proof.proofSize() != consensus.ChainTypeProofSize(c.chainType) {
-		return errors.New("wrong cycle length")
+	if proof.ProofSize() != consensus.ChainTypeProofSize(c.chainType) {
+		return ErrWrongCycleLength
 	}
 	nonces := proof.Nonces
-	uvs := make([]uint64, 2*proof.proofSize())
+	var buf [2 * consensus.MaxProofSize]uint64
+	uvs := buf[:2*proof.ProofSize()]
 	var xoruv uint64 = 0
 
-	for n := 0; n < proof.proofSize(); n++ {
+	for n := 0; n < proof.ProofSize(); n++ {
 		if nonces[n] > c.params.edgeMask {
-			return errors.New("edge too big")
+			return ErrEdgeTooBig
 		}
 		if n > 0 && nonces[n] <= nonces[n-1] {
-			return errors.New("edges not ascending")
+			return ErrEdgesNotAscending
 		}
 		// 21 is standard siphash rotation constant
 		edge := SipHashBlock(c.params.siphashKeys, nonces[n], 21, true)
@@ -63,7 +82,7 @@ func (c *CuckaroozContext) Verify(proof Proof) error {
 		xoruv ^= uvs[2*n] ^ uvs[2*n+1]
 	}
 	if xoruv != 0 {
-		return errors.New("endpoints don't match up")
+		return ErrEndpointsMismatch
 	}
 
 	n := 0
@@ -74,20 +93,22 @@ func (c *CuckaroozContext) Verify(proof Proof) error {
 		j = i
 		k := j
 		for {
-			k = (k + 1) % (2 * c.params.proofSize)
+			if k++; k == 2*c.params.proofSize {
+				k = 0
+			}
 			if k == i {
 				break
 			}
 			if uvs[k] == uvs[i] {
 				// find other edge endpoint matching one at i
 				if j != i {
-					return errors.New("branch in cycle")
+					return ErrBranchInCycle
 				}
 				j = k
 			}
 		}
 		if j == i {
-			return errors.New("cycle dead ends")
+			return ErrCycleDeadEnds
 		}
 		i = j ^ 1
 		n++
@@ -98,6 +119,6 @@ func (c *CuckaroozContext) Verify(proof Proof) error {
 	if n == c.params.proofSize {
 		return nil
 	}
-	return errors.New("cycle too short")
+	return ErrCycleTooShort
 
 }
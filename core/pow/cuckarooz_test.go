@@ -53,23 +53,33 @@ var v2_29SolCuckarooz = []uint64{
 }
 
 func TestCuckarooz19Vectors(t *testing.T) {
-	proof := new(Proof)
-	ctx := newCuckaroomImpl(consensus.Mainnet, 19, 42)
-	ctx.params.siphashKeys = v1_19HashCuckaroom
-	assert.Nil(t, ctx.Verify(proof.new(v1_19SolCuckaroom)))
-	assert.NotNil(t, ctx.Verify(proof.zero(42)))
+	ctx := newCuckaroozImpl(consensus.Mainnet, 19, 42)
+	ctx.params.siphashKeys = v1_19HashCuckarooz
+	assert.Nil(t, ctx.Verify(NewProof(19, v1_19SolCuckarooz)))
+	assert.Equal(t, ErrEdgesNotAscending, ctx.Verify(ZeroProof(19, 42)))
 }
 
 func TestCuckarooz29Vectors(t *testing.T) {
-	proof := new(Proof)
-	ctx := newCuckaroomImpl(consensus.Mainnet, 29, 42)
-	ctx.params.siphashKeys = v2_29HashCuckaroom
-	assert.Nil(t, ctx.Verify(proof.new(v2_29SolCuckaroom)))
-	assert.NotNil(t, ctx.Verify(proof.zero(42)))
+	ctx := newCuckaroozImpl(consensus.Mainnet, 29, 42)
+	ctx.params.siphashKeys = v2_29HashCuckarooz
+	assert.Nil(t, ctx.Verify(NewProof(29, v2_29SolCuckarooz)))
+	assert.Equal(t, ErrEdgesNotAscending, ctx.Verify(ZeroProof(29, 42)))
 }
 
-func newCuckaroozImpl(chainType consensus.ChainType, edgeBits uint8, proofSize int) *CuckaroomContext {
+func newCuckaroozImpl(chainType consensus.ChainType, edgeBits uint8, proofSize int) *CuckaroozContext {
 	cp := new(CuckooParams)
 	params := cp.new(edgeBits, edgeBits+1, proofSize)
-	return &CuckaroomContext{chainType, params}
+	return &CuckaroozContext{chainType, params}
+}
+
+func BenchmarkCuckarooz29Verify(b *testing.B) {
+	ctx := newCuckaroozImpl(consensus.Mainnet, 29, 42)
+	ctx.params.siphashKeys = v2_29HashCuckarooz
+	proof := Proof{EdgeBits: 29, Nonces: v2_29SolCuckarooz}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := ctx.Verify(proof); err != nil {
+			b.Fatal(err)
+		}
+	}
 }
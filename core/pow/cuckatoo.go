@@ -15,13 +15,11 @@
 package pow
 
 import (
-	"errors"
-
 	"github.com/blockcypher/libgrin/v5/core/consensus"
 )
 
 // NewCuckatooCtx instantiates a new CuckatooContext as a PowContext
-func NewCuckatooCtx(chainType consensus.ChainType, edgeBits uint8, proofSize int, maxSols uint32) (*CuckatooContext, error) {
+func NewCuckatooCtx(chainType consensus.ChainType, edgeBits uint8, proofSize int) (*CuckatooContext, error) {
 	cp := new(CuckooParams)
 	params := cp.new(edgeBits, edgeBits, proofSize)
 	return &CuckatooContext{chainType, params}, nil
@@ -38,22 +36,39 @@ func (c *CuckatooContext) SetHeaderNonce(header []uint8, nonce *uint32) {
 	c.params.resetHeaderNonce(header, nonce)
 }
 
+// Solve finds the cycles of the Cuckatoo graph. Nodes are paired by their
+// lowest bit: a cycle leaving an edge at a node enters the next edge at the
+// other node of the pair.
+func (c *CuckatooContext) Solve() ([]Proof, error) {
+	g := cycleGraph{
+		proofSize: c.params.proofSize,
+		ports: func(nonce uint64) [2]uint64 {
+			return [2]uint64{c.params.sipnode(nonce, 0) << 1, c.params.sipnode(nonce, 1)<<1 | 1}
+		},
+		link: func(key uint64) uint64 {
+			return key ^ 2
+		},
+	}
+	return g.findCycles(c.params.edgeBits, c.Verify)
+}
+
 // Verify verifies the Cuckatoo context.
 func (c *CuckatooContext) Verify(proof Proof) error {
-	if proof.proofSize() != consensus.ChainTypeProofSize(c.chainType) {
-		return errors.New("wrong cycle length")
+	if proof.ProofSize() != consensus.ChainTypeProofSize(c.chainType) {
+		return ErrWrongCycleLength
 	}
 	nonces := proof.Nonces
-	uvs := make([]uint64, 2*proof.proofSize())
+	var buf [2 * consensus.MaxProofSize]uint64
+	uvs := buf[:2*proof.ProofSize()]
 	xor0 := (uint64(c.params.proofSize) / 2) & 1
 	xor1 := xor0
 
-	for n := 0; n < proof.proofSize(); n++ {
+	for n := 0; n < proof.ProofSize(); n++ {
 		if nonces[n] > c.params.edgeMask {
-			return errors.New("edge too big")
+			return ErrEdgeTooBig
 		}
 		if n > 0 && nonces[n] <= nonces[n-1] {
-			return errors.New("edges not ascending")
+			return ErrEdgesNotAscending
 		}
 		uvs[2*n] = c.params.sipnode(nonces[n], 0)
 		uvs[2*n+1] = c.params.sipnode(nonces[n], 1)
@@ -62,7 +77,7 @@ func (c *CuckatooContext) Verify(proof Proof) error {
 	}
 
 	if xor0|xor1 != 0 {
-		return errors.New("endpoints don't match up")
+		return ErrEndpointsMismatch
 	}
 
 	var i, j, n int
@@ -71,20 +86,22 @@ func (c *CuckatooContext) Verify(proof Proof) error {
 		j = i
 		k := j
 		for {
-			k = (k + 2) % (2 * c.params.proofSize)
+			if k += 2; k >= 2*c.params.proofSize {
+				k -= 2 * c.params.proofSize
+			}
 			if k == i {
 				break
 			}
 			if uvs[k]>>1 == uvs[i]>>1 {
 				// find other edge endpoint matching one at i
 				if j != i {
-					return errors.New("branch in cycle")
+					return ErrBranchInCycle
 				}
 				j = k
 			}
 		}
 		if j == i || uvs[j] == uvs[i] {
-			return errors.New("cycle dead ends")
+			return ErrCycleDeadEnds
 		}
 		i = j ^ 1
 		n++
@@ -95,6 +112,6 @@ func (c *CuckatooContext) Verify(proof Proof) error {
 	if n == c.params.proofSize {
 		return nil
 	}
-	return errors.New("cycle too short")
+	return ErrCycleTooShort
 
 }
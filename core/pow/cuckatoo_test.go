@@ -42,39 +42,49 @@ var v1_31 = []uint64{
 }
 
 func TestValidate29Vectors(t *testing.T) {
-	ctx := newCuckatooImpl(consensus.Mainnet, 29, 42, 10)
+	ctx := newCuckatooImpl(consensus.Mainnet, 29, 42)
 	nonce := uint32(20)
 	ctx.SetHeaderNonce(make([]uint8, 80), &nonce)
-	proof := new(Proof)
-	assert.Nil(t, ctx.Verify(proof.new(v1_29)))
+	assert.Nil(t, ctx.Verify(NewProof(29, v1_29)))
 }
 
 func TestValidate31Vectors(t *testing.T) {
-	ctx := newCuckatooImpl(consensus.Mainnet, 31, 42, 10)
+	ctx := newCuckatooImpl(consensus.Mainnet, 31, 42)
 	nonce := uint32(99)
 	ctx.SetHeaderNonce(make([]uint8, 80), &nonce)
-	proof := new(Proof)
-	assert.Nil(t, ctx.Verify(proof.new(v1_31)))
+	assert.Nil(t, ctx.Verify(NewProof(31, v1_31)))
 }
 
 func TestValidateFail(t *testing.T) {
-	proof := new(Proof)
-	ctx := newCuckatooImpl(consensus.Mainnet, 29, 42, 10)
+	ctx := newCuckatooImpl(consensus.Mainnet, 29, 42)
 	header := make([]uint8, 80)
 	header[0] = uint8(1)
 	nonce := uint32(20)
 	ctx.SetHeaderNonce(header, &nonce)
-	assert.NotNil(t, ctx.Verify(proof.new(v1_29)))
+	assert.NotNil(t, ctx.Verify(NewProof(29, v1_29)))
 	header[0] = uint8(0)
 	ctx.SetHeaderNonce(header, &nonce)
-	assert.Nil(t, ctx.Verify(proof.new(v1_29)))
+	assert.Nil(t, ctx.Verify(NewProof(29, v1_29)))
 	badProof := v1_29
 	badProof[0] = 0x48a9e1
-	assert.NotNil(t, ctx.Verify(proof.new(badProof)))
+	assert.NotNil(t, ctx.Verify(NewProof(29, badProof)))
 }
 
-func newCuckatooImpl(chainType consensus.ChainType, edgeBits uint8, proofSize int, maxSols uint32) CuckatooContext {
+func newCuckatooImpl(chainType consensus.ChainType, edgeBits uint8, proofSize int) CuckatooContext {
 	cp := new(CuckooParams)
 	params := cp.new(edgeBits, edgeBits, proofSize)
 	return CuckatooContext{chainType, params}
 }
+
+func BenchmarkCuckatoo29Verify(b *testing.B) {
+	ctx := newCuckatooImpl(consensus.Mainnet, 29, 42)
+	nonce := uint32(20)
+	ctx.SetHeaderNonce(make([]uint8, 80), &nonce)
+	proof := Proof{EdgeBits: 29, Nonces: v1_29}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := ctx.Verify(proof); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
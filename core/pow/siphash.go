@@ -14,6 +14,11 @@
 
 package pow
 
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
 // Parameters to the siphash block algorithm. Used by Cuckaroo but can be seen
 // as a generic way to derive a hash within a block of them.
 const sipHashBlockBits uint64 = 6
@@ -27,13 +32,11 @@ func SipHashBlock(v [4]uint64, nonce uint64, rotE uint8, xorAll bool) uint64 {
 	// beginning of the block of hashes
 	nonce0 := nonce & ^sipHashBlockMask
 	nonceI := nonce & sipHashBlockMask
-	nonceHash := make([]uint64, sipHashBlockSize)
+	var nonceHash [sipHashBlockSize]uint64
 	// repeated hashing over the whole block
-	s := new(sipHash24)
-	siphash := s.new(v)
-	var i uint64
-	for i = 0; i < sipHashBlockSize; i++ {
-		siphash.hash(nonce0+i, rotE)
+	siphash := sipHash24{v[0], v[1], v[2], v[3]}
+	for i := range nonceHash {
+		siphash.hash(nonce0+uint64(i), rotE)
 		nonceHash[i] = siphash.digest()
 	}
 	// xor the hash at nonce_i < SIPHASH_BLOCK_MASK with some or all later hashes to force hashing the whole block
@@ -51,34 +54,92 @@ func SipHashBlock(v [4]uint64, nonce uint64, rotE uint8, xorAll bool) uint64 {
 	return xor
 }
 
+// Computes the SipHashBlock of every nonce below numEdges, hashing each block
+// once
+func sipHashBlockEdges(v [4]uint64, numEdges uint64, rotE uint8, xorAll bool) []uint64 {
+	edges := make([]uint64, (numEdges+sipHashBlockMask)&^sipHashBlockMask)
+	var block [sipHashBlockSize]uint64
+	for nonce0 := uint64(0); nonce0 < numEdges; nonce0 += sipHashBlockSize {
+		siphash := sipHash24{v[0], v[1], v[2], v[3]}
+		for i := range block {
+			siphash.hash(nonce0+uint64(i), rotE)
+			block[i] = siphash.digest()
+		}
+		last := block[sipHashBlockMask]
+		edges[nonce0+sipHashBlockMask] = last
+		xor := last
+		for i := int(sipHashBlockMask) - 1; i >= 0; i-- {
+			if xorAll {
+				xor ^= block[i]
+				edges[nonce0+uint64(i)] = xor
+			} else {
+				edges[nonce0+uint64(i)] = block[i] ^ last
+			}
+		}
+	}
+	return edges[:numEdges]
+}
+
 // SipHash24 is an utility function to compute a single siphash 2-4 based on a seed and a nonce.
 func SipHash24(v [4]uint64, nonce uint64, rotE uint8) uint64 {
-	s := new(sipHash24)
-	siphash := s.new(v)
+	siphash := sipHash24{v[0], v[1], v[2], v[3]}
 	siphash.hash(nonce, rotE)
 	return siphash.digest()
 }
 
-type sipHash24 struct {
-	v0, v1, v2, v3 uint64
+// SipHash24Keyed computes the standard keyed siphash 2-4 of a message, as
+// used for the short ids of compact blocks. Unlike SipHash24 it hashes any
+// message rather than a single nonce, from the 128-bit key k0, k1.
+func SipHash24Keyed(k0, k1 uint64, msg []byte) uint64 {
+	s := sipHash24{
+		k0 ^ 0x736f6d6570736575,
+		k1 ^ 0x646f72616e646f6d,
+		k0 ^ 0x6c7967656e657261,
+		k1 ^ 0x7465646279746573,
+	}
+	// Compress the message 8 bytes at a time, the last word holding the
+	// remaining bytes and the message length
+	length := len(msg)
+	for ; len(msg) >= 8; msg = msg[8:] {
+		s.compress(binary.LittleEndian.Uint64(msg))
+	}
+	last := uint64(length) << 56
+	for i, b := range msg {
+		last |= uint64(b) << (8 * uint(i))
+	}
+	s.compress(last)
+	s.v2 ^= 0xff
+	for i := 0; i < 4; i++ {
+		s.round(21)
+	}
+	return s.digest()
 }
 
-func (s *sipHash24) new(v [4]uint64) sipHash24 {
-	return sipHash24{v[0], v[1], v[2], v[3]}
+type sipHash24 struct {
+	v0, v1, v2, v3 uint64
 }
 
 // One siphash24 hashing, consisting of 2 and then 4 rounds
 func (s *sipHash24) hash(nonce uint64, rotE uint8) {
-	s.v3 ^= nonce
-	s.round(rotE)
-	s.round(rotE)
+	v0, v1, v2, v3 := s.v0, s.v1, s.v2, s.v3^nonce
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3, rotE)
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3, rotE)
 
-	s.v0 ^= nonce
-	s.v2 ^= 0xff
+	v0 ^= nonce
+	v2 ^= 0xff
 
 	for i := 0; i < 4; i++ {
-		s.round(rotE)
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3, rotE)
 	}
+	s.v0, s.v1, s.v2, s.v3 = v0, v1, v2, v3
+}
+
+// Compresses a message word with 2 rounds
+func (s *sipHash24) compress(m uint64) {
+	s.v3 ^= m
+	s.round(21)
+	s.round(21)
+	s.v0 ^= m
 }
 
 // Resulting hash digest
@@ -87,23 +148,24 @@ func (s *sipHash24) digest() uint64 {
 }
 
 func (s *sipHash24) round(rotE uint8) {
-	s.v0 = s.v0 + s.v1
-	s.v2 = s.v2 + s.v3
-	s.v1 = rotl(s.v1, 13)
-	s.v3 = rotl(s.v3, 16)
-	s.v1 ^= s.v0
-	s.v3 ^= s.v2
-	s.v0 = rotl(s.v0, 32)
-	s.v2 = s.v2 + s.v1
-	s.v0 = s.v0 + s.v3
-	s.v1 = rotl(s.v1, 17)
-	s.v3 = rotl(s.v3, rotE)
-	s.v1 ^= s.v2
-	s.v3 ^= s.v0
-	s.v2 = rotl(s.v2, 32)
+	s.v0, s.v1, s.v2, s.v3 = sipRound(s.v0, s.v1, s.v2, s.v3, rotE)
 }
 
-func rotl(val uint64, shift uint8) uint64 {
-	num := (val << shift) | (val >> (64 - shift))
-	return num
+// One siphash round on the state held in registers
+func sipRound(v0, v1, v2, v3 uint64, rotE uint8) (uint64, uint64, uint64, uint64) {
+	v0 += v1
+	v2 += v3
+	v1 = bits.RotateLeft64(v1, 13)
+	v3 = bits.RotateLeft64(v3, 16)
+	v1 ^= v0
+	v3 ^= v2
+	v0 = bits.RotateLeft64(v0, 32)
+	v2 += v1
+	v0 += v3
+	v1 = bits.RotateLeft64(v1, 17)
+	v3 = bits.RotateLeft64(v3, int(rotE))
+	v1 ^= v2
+	v3 ^= v0
+	v2 = bits.RotateLeft64(v2, 32)
+	return v0, v1, v2, v3
 }
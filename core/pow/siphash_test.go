@@ -32,3 +32,33 @@ func TestSiphashBlock(t *testing.T) {
 	assert.Equal(t, SipHashBlock([4]uint64{1, 2, 3, 4}, 123, 21, false), uint64(11303676240481718781))
 	assert.Equal(t, SipHashBlock([4]uint64{9, 7, 6, 7}, 12, 21, false), uint64(4886136884237259030))
 }
+
+func TestSipHash24Keyed(t *testing.T) {
+	// Reference vectors of the siphash paper: key 00..0f, messages 00..(n-1)
+	msg := make([]byte, 15)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+	k0, k1 := uint64(0x0706050403020100), uint64(0x0f0e0d0c0b0a0908)
+	assert.Equal(t, uint64(0x726fdb47dd0e0e31), SipHash24Keyed(k0, k1, nil))
+	assert.Equal(t, uint64(0x93f5f5799a932462), SipHash24Keyed(k0, k1, msg[:8]))
+	assert.Equal(t, uint64(0xa129ca6149be45e5), SipHash24Keyed(k0, k1, msg))
+}
+
+func TestSipHashBlockEdges(t *testing.T) {
+	keys := [4]uint64{1, 2, 3, 4}
+	for _, xorAll := range []bool{false, true} {
+		edges := sipHashBlockEdges(keys, 200, 21, xorAll)
+		assert.Len(t, edges, 200)
+		for nonce, edge := range edges {
+			assert.Equal(t, SipHashBlock(keys, uint64(nonce), 21, xorAll), edge)
+		}
+	}
+}
+
+func BenchmarkSipHashBlock(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		SipHashBlock([4]uint64{1, 2, 3, 4}, uint64(i), 21, false)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pow
+
+import (
+	"errors"
+	"sort"
+)
+
+// MaxSolverEdgeBits is the maximum edge bits of the graphs the solvers can
+// search, the solvers being meant for the testing chain types
+const MaxSolverEdgeBits uint8 = 20
+
+var (
+	// ErrNoSolution is returned when a solver finds no cycle in the graph
+	ErrNoSolution = errors.New("no solution found")
+	// ErrGraphTooBig is returned when solving a graph of more than
+	// MaxSolverEdgeBits edge bits
+	ErrGraphTooBig = errors.New("graph too big to solve")
+)
+
+// A cuckoo graph as seen by the solver. Each edge has two endpoints, its
+// ports, identified by a key. Two edges are adjacent when the key of a port
+// of one is linked to the key of a port of the other. A cycle enters each
+// edge through a port and leaves it through the other port.
+type cycleGraph struct {
+	proofSize int
+	// Edge ports by nonce
+	ports func(nonce uint64) [2]uint64
+	// The key of the ports an edge leaving through a port of the key can be
+	// followed by. Linking a linked key gives back the key.
+	link func(key uint64) uint64
+}
+
+// A port of an edge
+type edgePort struct {
+	edge int
+	port int
+}
+
+// Finds the cycles of proofSize edges of the graph of edgeBits edge bits which
+// the verify function accepts
+func (g *cycleGraph) findCycles(edgeBits uint8, verify func(Proof) error) ([]Proof, error) {
+	if edgeBits > MaxSolverEdgeBits {
+		return nil, ErrGraphTooBig
+	}
+	numEdges := uint64(1) << edgeBits
+	nonces := make([]uint64, 0, numEdges)
+	ports := make([][2]uint64, 0, numEdges)
+	for nonce := uint64(0); nonce < numEdges; nonce++ {
+		nonces = append(nonces, nonce)
+		ports = append(ports, g.ports(nonce))
+	}
+	nonces, ports = g.trim(nonces, ports)
+
+	index := make(map[uint64][]edgePort)
+	for e, p := range ports {
+		index[p[0]] = append(index[p[0]], edgePort{e, 0})
+		index[p[1]] = append(index[p[1]], edgePort{e, 1})
+	}
+
+	// Each cycle is searched from its smallest nonce, entering through port 0
+	proofs := []Proof{}
+	path := make([]int, 0, g.proofSize)
+	used := make([]bool, len(nonces))
+	var follow func(start, e, port int)
+	follow = func(start, e, port int) {
+		path = append(path, e)
+		used[e] = true
+		next := g.link(ports[e][port^1])
+		if len(path) == g.proofSize {
+			if next == ports[start][0] {
+				proof := Proof{EdgeBits: edgeBits, Nonces: make([]uint64, g.proofSize)}
+				for i, edge := range path {
+					proof.Nonces[i] = nonces[edge]
+				}
+				sort.Slice(proof.Nonces, func(i, j int) bool { return proof.Nonces[i] < proof.Nonces[j] })
+				if verify(proof) == nil {
+					proofs = append(proofs, proof)
+				}
+			}
+		} else {
+			for _, p := range index[next] {
+				if !used[p.edge] && p.edge > start {
+					follow(start, p.edge, p.port)
+				}
+			}
+		}
+		used[e] = false
+		path = path[:len(path)-1]
+	}
+	for e := range nonces {
+		follow(e, e, 0)
+	}
+	if len(proofs) == 0 {
+		return nil, ErrNoSolution
+	}
+	return proofs, nil
+}
+
+// Trims the edges which can't be part of a cycle, those with a port not
+// linked to a port of another edge, until no edge is trimmed
+func (g *cycleGraph) trim(nonces []uint64, ports [][2]uint64) ([]uint64, [][2]uint64) {
+	for {
+		count := make(map[uint64]int, 2*len(ports))
+		for _, p := range ports {
+			count[p[0]]++
+			count[p[1]]++
+		}
+		n := 0
+		for e, p := range ports {
+			alive := true
+			for _, key := range p {
+				linked := g.link(key)
+				own := 0
+				if p[0] == linked {
+					own++
+				}
+				if p[1] == linked {
+					own++
+				}
+				if count[linked] <= own {
+					alive = false
+				}
+			}
+			if alive {
+				nonces[n] = nonces[e]
+				ports[n] = p
+				n++
+			}
+		}
+		if n == len(ports) {
+			return nonces, ports
+		}
+		nonces, ports = nonces[:n], ports[:n]
+	}
+}
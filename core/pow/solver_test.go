@@ -0,0 +1,87 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pow
+
+import (
+	"testing"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+// Solves the graphs of the header with increasing nonces until a solution is
+// found, verifying the solutions
+func solveUntilFound(t *testing.T, ctx PowContext, maxNonce uint32) {
+	header := make([]uint8, 80)
+	for nonce := uint32(0); nonce < maxNonce; nonce++ {
+		ctx.SetHeaderNonce(header, &nonce)
+		proofs, err := ctx.Solve()
+		if err == ErrNoSolution {
+			continue
+		}
+		assert.NoError(t, err)
+		assert.NotEmpty(t, proofs)
+		for _, proof := range proofs {
+			assert.NoError(t, ctx.Verify(proof))
+		}
+		return
+	}
+	t.Fatalf("no solution found up to nonce %d", maxNonce)
+}
+
+// Solves graphs of each PoW variant at the edge bits and proof size
+func solveVariants(t *testing.T, chainType consensus.ChainType, edgeBits uint8, proofSize int) {
+	cuckatoo, _ := NewCuckatooCtx(chainType, edgeBits, proofSize)
+	solveUntilFound(t, cuckatoo, 1000)
+	cuckaroo, _ := NewCuckarooCtx(chainType, edgeBits, proofSize)
+	solveUntilFound(t, cuckaroo, 1000)
+	cuckarood, _ := NewCuckaroodCtx(chainType, edgeBits, proofSize)
+	solveUntilFound(t, cuckarood, 1000)
+	cuckaroom, _ := NewCuckaroomCtx(chainType, edgeBits, proofSize)
+	solveUntilFound(t, cuckaroom, 1000)
+	cuckarooz, _ := NewCuckaroozCtx(chainType, edgeBits, proofSize)
+	solveUntilFound(t, cuckarooz, 1000)
+}
+
+func TestSolveAutomatedTesting(t *testing.T) {
+	solveVariants(t, consensus.AutomatedTesting, consensus.AutomatedTestingMinEdgeBits, consensus.AutomatedTestingProofSize)
+}
+
+func TestSolveUserTesting(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping 15 edge bits solving in short mode")
+	}
+	solveVariants(t, consensus.UserTesting, consensus.UserTestingMinEdgeBits, consensus.UserTestingProofSize)
+}
+
+func TestSolveNoSolution(t *testing.T) {
+	// A graph of 2 edges has no 4-cycle
+	ctx, _ := NewCuckarooCtx(consensus.AutomatedTesting, 1, consensus.AutomatedTestingProofSize)
+	nonce := uint32(0)
+	ctx.SetHeaderNonce(make([]uint8, 80), &nonce)
+	_, err := ctx.Solve()
+	assert.Equal(t, ErrNoSolution, err)
+
+	// Mainnet graphs are rejected before hashing their edges
+	cuckarood, _ := NewCuckaroodCtx(consensus.Mainnet, 29, consensus.ProofSize)
+	cuckaroom, _ := NewCuckaroomCtx(consensus.Mainnet, 29, consensus.ProofSize)
+	cuckarooz, _ := NewCuckaroozCtx(consensus.Mainnet, 29, consensus.ProofSize)
+	cuckatoo, _ := NewCuckatooCtx(consensus.Mainnet, 31, consensus.ProofSize)
+	ctx, _ = NewCuckarooCtx(consensus.Mainnet, 29, consensus.ProofSize)
+	for _, ctx := range []PowContext{ctx, cuckarood, cuckaroom, cuckarooz, cuckatoo} {
+		_, err = ctx.Solve()
+		assert.Equal(t, ErrGraphTooBig, err)
+	}
+}
@@ -15,11 +15,14 @@
 package pow
 
 import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"math"
 	"math/big"
-	"strconv"
 
 	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/core/ser"
 )
 
 // PowContext is a generic interface for a solver/verifier providing common
@@ -30,8 +33,40 @@ type PowContext interface {
 	SetHeaderNonce(header []uint8, nonce *uint32)
 	// Verify a solution with the stored parameters
 	Verify(proof Proof) error
+	// Find the cycles of the graph of the stored parameters, only for small
+	// graphs
+	Solve() ([]Proof, error)
 }
 
+// Errors returned by the verifiers for invalid proofs
+var (
+	// ErrWrongCycleLength is returned for a proof whose size isn't the proof
+	// size of the chain type
+	ErrWrongCycleLength = errors.New("wrong cycle length")
+	// ErrEdgeTooBig is returned for a nonce above the edge mask of the graph
+	ErrEdgeTooBig = errors.New("edge too big")
+	// ErrEdgesNotAscending is returned for nonces which aren't strictly
+	// ascending
+	ErrEdgesNotAscending = errors.New("edges not ascending")
+	// ErrEdgesNotBalanced is returned for a Cuckarood proof whose edges aren't
+	// half in each direction
+	ErrEdgesNotBalanced = errors.New("edges not balanced")
+	// ErrEndpointsMismatch is returned when the edge endpoints can't pair up
+	ErrEndpointsMismatch = errors.New("endpoints don't match up")
+	// ErrBranchInCycle is returned when a node of the cycle has more than two
+	// edges
+	ErrBranchInCycle = errors.New("branch in cycle")
+	// ErrCycleDeadEnds is returned when the cycle can't be followed back to
+	// its first edge
+	ErrCycleDeadEnds = errors.New("cycle dead ends")
+	// ErrCycleTooShort is returned when the edges form a cycle shorter than the
+	// proof
+	ErrCycleTooShort = errors.New("cycle too short")
+	// ErrNoSecondaryPoW is returned for secondary proofs after the secondary
+	// proof of work is retired
+	ErrNoSecondaryPoW = errors.New("no secondary proof of work")
+)
+
 // Proof is a Cuck(at)oo Cycle proof of work, consisting of the edge_bits to get
 // the graph size (i.e. the 2-log of the number of edges) and the nonces of the
 // graph solution. While being expressed as u64 for simplicity, nonces a.k.a.
@@ -47,36 +82,128 @@ type Proof struct {
 	Nonces []uint64
 }
 
-func (p *Proof) new(inNonces []uint64) Proof {
-	// No sorting here
-	return Proof{EdgeBits: consensus.DefaultMinEdgeBits, Nonces: inNonces}
+// NewProof builds a proof of edge bits from its nonces, which are not sorted
+func NewProof(edgeBits uint8, nonces []uint64) Proof {
+	return Proof{EdgeBits: edgeBits, Nonces: nonces}
 }
 
-// Builds a proof with all bytes zeroed out
-func (p *Proof) zero(proofSize int) Proof {
-	return Proof{consensus.DefaultMinEdgeBits, make([]uint64, proofSize)}
+// ZeroProof builds a proof of edge bits with proofSize zero nonces
+func ZeroProof(edgeBits uint8, proofSize int) Proof {
+	return Proof{EdgeBits: edgeBits, Nonces: make([]uint64, proofSize)}
 }
 
-// Returns the proof size
-func (p *Proof) proofSize() int {
+// ProofSize returns the proof size, its number of nonces
+func (p *Proof) ProofSize() int {
 	return len(p.Nonces)
 }
 
-// Difficulty achieved by this proof with given scaling factor
-func (p *Proof) scaledDifficulty(blockHashString string, scaleUint64 uint64) uint64 {
-	hash, _ := strconv.ParseUint(blockHashString[:16], 16, 64)
-	var scale big.Int
-	scale = *scale.SetUint64(scaleUint64)
-	scaleShifted := scale.Lsh(&scale, 64)
+// PackLen is the number of bytes required to store the nonces of a proof of
+// given edge bits and proof size
+func PackLen(edgeBits uint8, proofSize int) int {
+	return (int(edgeBits)*proofSize + 7) / 8
+}
+
+// PackNonces packs the nonces at their exact bit size, as a little endian bit
+// sequence padded with zero bits to be byte-aligned
+func (p *Proof) PackNonces() []byte {
+	packed := make([]byte, PackLen(p.EdgeBits, len(p.Nonces)))
+	for i, nonce := range p.Nonces {
+		for b := 0; b < int(p.EdgeBits); b++ {
+			if nonce>>uint(b)&1 == 1 {
+				pos := i*int(p.EdgeBits) + b
+				packed[pos/8] |= 1 << uint(pos%8)
+			}
+		}
+	}
+	return packed
+}
+
+// UnpackProof unpacks a proof of proofSize nonces of edgeBits bits. The
+// padding bits must be zero.
+func UnpackProof(edgeBits uint8, proofSize int, packed []byte) (Proof, error) {
+	if edgeBits == 0 || edgeBits > 63 || len(packed) != PackLen(edgeBits, proofSize) {
+		return Proof{}, ser.ErrCorruptedData
+	}
+	nonces := make([]uint64, proofSize)
+	for i := range nonces {
+		for b := 0; b < int(edgeBits); b++ {
+			pos := i*int(edgeBits) + b
+			if packed[pos/8]>>uint(pos%8)&1 == 1 {
+				nonces[i] |= 1 << uint(b)
+			}
+		}
+	}
+	for pos := proofSize * int(edgeBits); pos < len(packed)*8; pos++ {
+		if packed[pos/8]>>uint(pos%8)&1 == 1 {
+			return Proof{}, ser.ErrCorruptedData
+		}
+	}
+	return Proof{EdgeBits: edgeBits, Nonces: nonces}, nil
+}
+
+// Write serializes the proof: the edge bits (except in hash mode) followed by
+// the packed nonces
+func (p *Proof) Write(w *ser.Writer) error {
+	if !w.IsHashMode() {
+		if err := w.WriteU8(p.EdgeBits); err != nil {
+			return err
+		}
+	}
+	return w.WriteFixedBytes(p.PackNonces())
+}
+
+// Read deserializes a proof of proofSize nonces
+func (p *Proof) Read(r *ser.Reader, proofSize int) error {
+	edgeBits, err := r.ReadU8()
+	if err != nil {
+		return err
+	}
+	if edgeBits == 0 || edgeBits > 63 {
+		return ser.ErrCorruptedData
+	}
+	length := PackLen(edgeBits, proofSize)
+	if length < 8 {
+		return ser.ErrCorruptedData
+	}
+	packed, err := r.ReadFixedBytes(length)
+	if err != nil {
+		return err
+	}
+	proof, err := UnpackProof(edgeBits, proofSize, packed)
+	if err != nil {
+		return err
+	}
+	*p = proof
+	return nil
+}
+
+// Hash returns the hex encoded blake2b hash of the packed nonces
+func (p *Proof) Hash() string {
+	return hex.EncodeToString(blake2BHash256(p.PackNonces()))
+}
 
-	var maxUint64 big.Int
-	maxUint64 = *maxUint64.SetUint64(math.MaxUint64)
+// ToDifficulty is the difficulty achieved by this proof with given scaling
+// factor: the scale shifted by 64 bits divided by the first 64 bits of the
+// proof hash, saturating at the maximum uint64 and at least 1
+func (p *Proof) ToDifficulty(scale uint64) uint64 {
+	hash := blake2BHash256(p.PackNonces())
+	return scaledDifficulty(binary.BigEndian.Uint64(hash[:8]), scale)
+}
 
-	var diff big.Int
-	diff = *diff.Div(scaleShifted, big.NewInt(int64(hash)))
-	if diff.Cmp(&maxUint64) == 1 {
+// The scale shifted by 64 bits divided by the hash, saturating at the
+// maximum uint64. Like any difficulty it is at least 1.
+func scaledDifficulty(hash uint64, scale uint64) uint64 {
+	if hash == 0 {
+		hash = 1
+	}
+	diff := new(big.Int).Lsh(new(big.Int).SetUint64(scale), 64)
+	diff.Div(diff, new(big.Int).SetUint64(hash))
+	if !diff.IsUint64() {
 		return math.MaxUint64
 	}
+	if diff.Sign() == 0 {
+		return 1
+	}
 	return diff.Uint64()
 }
 
@@ -109,3 +236,50 @@ func (p *ProofOfWork) IsPrimary() bool {
 func (p *ProofOfWork) IsSecondary() bool {
 	return p.Proof.EdgeBits == consensus.SecondPoWEdgeBits
 }
+
+// ToDifficulty is the difficulty achieved by this proof of work at the given
+// height: secondary proofs are scaled by the secondary scaling factor and
+// primary proofs by their graph weight.
+func (p *ProofOfWork) ToDifficulty(chainType consensus.ChainType, height uint64) uint64 {
+	if p.IsSecondary() {
+		return p.Proof.ToDifficulty(uint64(p.SecondaryScaling))
+	}
+	return p.Proof.ToDifficulty(consensus.GraphWeight(chainType, height, p.Proof.EdgeBits))
+}
+
+// Write serializes the proof of work. In hash mode only the proof nonces are
+// written.
+func (p *ProofOfWork) Write(w *ser.Writer) error {
+	if !w.IsHashMode() {
+		if err := p.WritePrePoW(w); err != nil {
+			return err
+		}
+		if err := w.WriteU64(p.Nonce); err != nil {
+			return err
+		}
+	}
+	return p.Proof.Write(w)
+}
+
+// WritePrePoW writes the pre-hash portion of the proof of work
+func (p *ProofOfWork) WritePrePoW(w *ser.Writer) error {
+	if err := w.WriteU64(p.TotalDifficulty); err != nil {
+		return err
+	}
+	return w.WriteU32(p.SecondaryScaling)
+}
+
+// Read deserializes a proof of work with a proof of proofSize nonces
+func (p *ProofOfWork) Read(r *ser.Reader, proofSize int) error {
+	var err error
+	if p.TotalDifficulty, err = r.ReadU64(); err != nil {
+		return err
+	}
+	if p.SecondaryScaling, err = r.ReadU32(); err != nil {
+		return err
+	}
+	if p.Nonce, err = r.ReadU64(); err != nil {
+		return err
+	}
+	return p.Proof.Read(r, proofSize)
+}
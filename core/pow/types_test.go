@@ -15,14 +15,105 @@
 package pow
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"math/big"
 	"testing"
 
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/core/ser"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestNew(t *testing.T) {
-	p := new(Proof)
-	proof := p.new(v1_19Sol)
-	assert.Equal(t, proof.EdgeBits, uint8(31))
+func TestNewProof(t *testing.T) {
+	proof := NewProof(19, v1_19Sol)
+	assert.Equal(t, proof.EdgeBits, uint8(19))
 	assert.Equal(t, proof.Nonces, v1_19Sol)
+	assert.Equal(t, 42, proof.ProofSize())
+
+	proof = ZeroProof(29, 42)
+	assert.Equal(t, proof.EdgeBits, uint8(29))
+	assert.Equal(t, make([]uint64, 42), proof.Nonces)
+}
+
+func TestProofReadWrite(t *testing.T) {
+	for edgeBits := uint8(10); edgeBits < 63; edgeBits++ {
+		nonces := make([]uint64, consensus.ProofSize)
+		for i := range nonces {
+			nonces[i] = (uint64(i)*0x9e3779b97f4a7c15 + uint64(edgeBits)) & (1<<edgeBits - 1)
+		}
+		proof := Proof{EdgeBits: edgeBits, Nonces: nonces}
+		b, err := ser.Serialize(&proof, ser.CurrentProtocolVersion, ser.FullMode)
+		assert.NoError(t, err)
+		assert.Len(t, b, 1+(int(edgeBits)*consensus.ProofSize+7)/8)
+
+		var read Proof
+		assert.NoError(t, read.Read(ser.NewReader(bytes.NewReader(b), ser.CurrentProtocolVersion), consensus.ProofSize))
+		assert.Equal(t, proof, read)
+	}
+
+	// Padding bits must be zero
+	proof := Proof{EdgeBits: 29, Nonces: make([]uint64, consensus.ProofSize)}
+	b, err := ser.Serialize(&proof, ser.CurrentProtocolVersion, ser.FullMode)
+	assert.NoError(t, err)
+	b[len(b)-1] = 0x80
+	var read Proof
+	assert.Equal(t, ser.ErrCorruptedData, read.Read(ser.NewReader(bytes.NewReader(b), ser.CurrentProtocolVersion), consensus.ProofSize))
+
+	// Hash mode only writes the packed nonces
+	b, err = ser.Serialize(&proof, ser.CurrentProtocolVersion, ser.HashMode)
+	assert.NoError(t, err)
+	assert.Len(t, b, (29*consensus.ProofSize+7)/8)
+}
+
+func TestUnpackProof(t *testing.T) {
+	proof := Proof{EdgeBits: 29, Nonces: v1_29}
+	packed := proof.PackNonces()
+	assert.Len(t, packed, PackLen(29, consensus.ProofSize))
+
+	unpacked, err := UnpackProof(29, consensus.ProofSize, packed)
+	assert.NoError(t, err)
+	assert.Equal(t, proof, unpacked)
+
+	_, err = UnpackProof(29, consensus.ProofSize, packed[1:])
+	assert.Equal(t, ser.ErrCorruptedData, err)
+	_, err = UnpackProof(0, consensus.ProofSize, packed)
+	assert.Equal(t, ser.ErrCorruptedData, err)
+}
+
+func TestProofHash(t *testing.T) {
+	proof := Proof{EdgeBits: 29, Nonces: v1_29}
+	b, err := ser.Serialize(&proof, ser.CurrentProtocolVersion, ser.HashMode)
+	assert.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(blake2BHash256(b)), proof.Hash())
+
+	// The difficulty is the scale shifted by 64 bits divided by the first 64
+	// bits of the hash
+	hash, err := hex.DecodeString(proof.Hash())
+	assert.NoError(t, err)
+	for _, scale := range []uint64{1, 1856, 1 << 20} {
+		diff := new(big.Int).Lsh(new(big.Int).SetUint64(scale), 64)
+		diff.Div(diff, new(big.Int).SetUint64(binary.BigEndian.Uint64(hash[:8])))
+		assert.Equal(t, diff.Uint64(), proof.ToDifficulty(scale))
+	}
+
+	// Secondary proofs are scaled by the secondary scaling, which can't make
+	// the difficulty drop below 1
+	pow := ProofOfWork{SecondaryScaling: 1856, Proof: proof}
+	assert.Equal(t, proof.ToDifficulty(1856), pow.ToDifficulty(consensus.Mainnet, 0))
+	pow.SecondaryScaling = 0
+	assert.Equal(t, uint64(1), pow.ToDifficulty(consensus.Mainnet, 0))
+}
+
+func TestScaledDifficulty(t *testing.T) {
+	assert.Equal(t, uint64(2), scaledDifficulty(1<<63, 1))
+	assert.Equal(t, uint64(1), scaledDifficulty(math.MaxUint64, 1))
+	// At least 1
+	assert.Equal(t, uint64(1), scaledDifficulty(math.MaxUint64, 0))
+	assert.Equal(t, uint64(1), scaledDifficulty(1<<63, 0))
+	// Saturates at the maximum uint64
+	assert.Equal(t, uint64(math.MaxUint64), scaledDifficulty(1, 1))
+	assert.Equal(t, uint64(math.MaxUint64), scaledDifficulty(0, 1))
 }
@@ -0,0 +1,68 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pow
+
+import (
+	"errors"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+)
+
+// ErrUnknownHeaderVersion is returned when creating the verifier of a header
+// version which is not in the fork schedule of the chain type
+var ErrUnknownHeaderVersion = errors.New("unknown header version")
+
+// NewContext creates the PoW context of the graphs of edge bits when the
+// secondary proof of work is the variant: Cuckatoo for the graphs above
+// SecondPoWEdgeBits, the secondary proof of work otherwise.
+func NewContext(chainType consensus.ChainType, secondaryPoW consensus.PoWVariant, edgeBits uint8, proofSize int) (PowContext, error) {
+	if edgeBits > consensus.SecondPoWEdgeBits {
+		return NewCuckatooCtx(chainType, edgeBits, proofSize)
+	}
+	switch secondaryPoW {
+	case consensus.CuckatooPoW:
+		return NewCuckatooCtx(chainType, edgeBits, proofSize)
+	case consensus.CuckarooPoW:
+		return NewCuckarooCtx(chainType, edgeBits, proofSize)
+	case consensus.CuckaroodPoW:
+		return NewCuckaroodCtx(chainType, edgeBits, proofSize)
+	case consensus.CuckaroomPoW:
+		return NewCuckaroomCtx(chainType, edgeBits, proofSize)
+	case consensus.CuckaroozPoW:
+		return NewCuckaroozCtx(chainType, edgeBits, proofSize)
+	default:
+		return NoCuckarooCtx()
+	}
+}
+
+// NewVerifier creates the verifier of the proofs of a header of the chain
+// type, from its version, the edge bits of its proof and its pre-PoW, the
+// serialization hashed to build the graph. The proofs must be of the proof
+// size of the chain type, which must be registered.
+func NewVerifier(chainType consensus.ChainType, headerVersion uint16, edgeBits uint8, prePoW []byte) (PowContext, error) {
+	if !consensus.ChainTypeRegistered(chainType) {
+		return nil, consensus.ErrUnknownChainType
+	}
+	fork, ok := consensus.ForkOfVersion(chainType, headerVersion)
+	if !ok {
+		return nil, ErrUnknownHeaderVersion
+	}
+	ctx, err := NewContext(chainType, fork.SecondaryPoW, edgeBits, consensus.ChainTypeProofSize(chainType))
+	if err != nil {
+		return nil, err
+	}
+	ctx.SetHeaderNonce(prePoW, nil)
+	return ctx, nil
+}
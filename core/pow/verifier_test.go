@@ -0,0 +1,72 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pow
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewVerifier(t *testing.T) {
+	prePoW := make([]uint8, 80)
+	secondary := []PowContext{&CuckarooContext{}, &CuckaroodContext{}, &CuckaroomContext{}, &CuckaroozContext{}}
+	for i, expected := range secondary {
+		verifier, err := NewVerifier(consensus.Mainnet, uint16(i+1), 29, prePoW)
+		assert.NoError(t, err)
+		assert.IsType(t, expected, verifier)
+		verifier, err = NewVerifier(consensus.Mainnet, uint16(i+1), 31, prePoW)
+		assert.NoError(t, err)
+		assert.IsType(t, &CuckatooContext{}, verifier)
+	}
+	_, err := NewVerifier(consensus.Mainnet, 5, 29, prePoW)
+	assert.Equal(t, ErrNoSecondaryPoW, err)
+	_, err = NewVerifier(consensus.Mainnet, 6, 31, prePoW)
+	assert.Equal(t, ErrUnknownHeaderVersion, err)
+	_, err = NewVerifier(consensus.ChainType(100), 1, 31, prePoW)
+	assert.Equal(t, consensus.ErrUnknownChainType, err)
+
+	// The verifier is keyed on the pre-PoW
+	verifier, err := NewVerifier(consensus.Mainnet, 1, 31, prePoW)
+	assert.NoError(t, err)
+	assert.NotNil(t, verifier.Verify(NewProof(31, v1_31)))
+	nonce := uint32(99)
+	verifier.SetHeaderNonce(prePoW, &nonce)
+	assert.NoError(t, verifier.Verify(NewProof(31, v1_31)))
+}
+
+func TestVerifierErrors(t *testing.T) {
+	// Find a solution of an AutomatedTesting pre-PoW, ending with the nonce
+	prePoW := make([]uint8, 80)
+	var verifier PowContext
+	var proofs []Proof
+	var err error
+	for nonce := uint32(0); len(proofs) == 0; nonce++ {
+		binary.LittleEndian.PutUint32(prePoW[76:], nonce)
+		verifier, err = NewVerifier(consensus.AutomatedTesting, 1, 9, prePoW)
+		assert.NoError(t, err)
+		proofs, _ = verifier.Solve()
+	}
+	proof := proofs[0]
+	assert.NoError(t, verifier.Verify(proof))
+
+	nonces := proof.Nonces
+	assert.Equal(t, ErrWrongCycleLength, verifier.Verify(NewProof(9, nonces[1:])))
+	assert.Equal(t, ErrEdgeTooBig, verifier.Verify(NewProof(9, []uint64{nonces[0], nonces[1], nonces[2], 1 << 9})))
+	assert.Equal(t, ErrEdgesNotAscending, verifier.Verify(NewProof(9, []uint64{nonces[1], nonces[0], nonces[2], nonces[3]})))
+	assert.Equal(t, ErrEdgesNotAscending, verifier.Verify(ZeroProof(9, consensus.AutomatedTestingProofSize)))
+}
@@ -79,107 +79,192 @@ func TestVerifySizeWithoutNonceInPrePow(t *testing.T) {
 
 // Check that we create the appropriate PoW context
 func TestMainnetContext(t *testing.T) {
-	var zero []uint64
-
 	// One block before hf
-	ctx, err := createPoWContext(consensus.Mainnet, consensus.YearHeight/2-1, 29, 42, zero, maxSols)
+	ctx, err := createPoWContext(consensus.Mainnet, consensus.YearHeight/2-1, 29, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckarooContext{}, ctx)
-	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight/2-1, 31, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight/2-1, 31, 42)
 	assert.NoError(t, err)
 
 	assert.IsType(t, &pow.CuckatooContext{}, ctx)
 
 	// Hard fork height
-	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight/2, 29, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight/2, 29, 42)
 	assert.NoError(t, err)
 
 	assert.IsType(t, &pow.CuckaroodContext{}, ctx)
-	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight/2, 31, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight/2, 31, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckatooContext{}, ctx)
 
 	// After hard fork
-	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight/2+1, 29, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight/2+1, 29, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckaroodContext{}, ctx)
-	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight/2+1, 31, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight/2+1, 31, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckatooContext{}, ctx)
 
 	// One block before second hf
-	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight-1, 29, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight-1, 29, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckaroodContext{}, ctx)
-	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight-1, 31, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight-1, 31, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckatooContext{}, ctx)
 
 	// Second hard fork height
-	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight, 29, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight, 29, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckaroomContext{}, ctx)
-	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight, 31, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight, 31, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckatooContext{}, ctx)
 
 	// After second hard fork
-	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight+1, 29, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight+1, 29, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckaroomContext{}, ctx)
-	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight+1, 31, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Mainnet, consensus.YearHeight+1, 31, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckatooContext{}, ctx)
 }
 
 func TestTestnetContext(t *testing.T) {
-	var zero []uint64
-
 	// One block before first hf
-	ctx, err := createPoWContext(consensus.Testnet, consensus.TestnetFirstHardFork-1, 29, 42, zero, maxSols)
+	ctx, err := createPoWContext(consensus.Testnet, consensus.TestnetFirstHardFork-1, 29, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckarooContext{}, ctx)
-	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetFirstHardFork-1, 31, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetFirstHardFork-1, 31, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckatooContext{}, ctx)
 
 	// First hard fork height
-	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetFirstHardFork, 29, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetFirstHardFork, 29, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckaroodContext{}, ctx)
-	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetFirstHardFork, 31, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetFirstHardFork, 31, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckatooContext{}, ctx)
 
 	// After first hard fork
-	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetFirstHardFork+1, 29, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetFirstHardFork+1, 29, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckaroodContext{}, ctx)
-	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetFirstHardFork+1, 31, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetFirstHardFork+1, 31, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckatooContext{}, ctx)
 
 	// One block before second hf
-	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetSecondHardFork-1, 29, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetSecondHardFork-1, 29, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckaroodContext{}, ctx)
-	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetSecondHardFork-1, 31, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetSecondHardFork-1, 31, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckatooContext{}, ctx)
 
 	// Second hard fork height
-	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetSecondHardFork, 29, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetSecondHardFork, 29, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckaroomContext{}, ctx)
-	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetSecondHardFork, 31, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetSecondHardFork, 31, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckatooContext{}, ctx)
 
 	// After second hard fork
-	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetSecondHardFork+1, 29, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetSecondHardFork+1, 29, 42)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckaroomContext{}, ctx)
-	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetSecondHardFork+1, 31, 42, zero, maxSols)
+	ctx, err = createPoWContext(consensus.Testnet, consensus.TestnetSecondHardFork+1, 31, 42)
+	assert.NoError(t, err)
+	assert.IsType(t, &pow.CuckatooContext{}, ctx)
+}
+
+func TestCreatePoWContext(t *testing.T) {
+	ctx, err := createPoWContext(consensus.Mainnet, 0, 31, 42)
+	assert.NoError(t, err)
+	assert.IsType(t, &pow.CuckatooContext{}, ctx)
+
+	// The secondary PoW follows the fork schedule
+	secondary := []pow.PowContext{&pow.CuckarooContext{}, &pow.CuckaroodContext{}, &pow.CuckaroomContext{}, &pow.CuckaroozContext{}}
+	for i, expected := range secondary {
+		ctx, err := createPoWContext(consensus.Mainnet, uint64(i)*consensus.HardForkInterval, 29, 42)
+		assert.NoError(t, err)
+		assert.IsType(t, expected, ctx)
+	}
+	_, err = createPoWContext(consensus.Mainnet, 4*consensus.HardForkInterval, 29, 42)
+	assert.Error(t, err)
+
+	// Testing chains are Cuckatoo only
+	ctx, err = createPoWContext(consensus.AutomatedTesting, 0, 9, 4)
 	assert.NoError(t, err)
 	assert.IsType(t, &pow.CuckatooContext{}, ctx)
+
+	// A custom chain with Cuckarooz as secondary PoW from genesis
+	params, _ := consensus.ChainTypeParams(consensus.UserTesting)
+	params.Forks = []consensus.Fork{{Version: 1, Height: 0, SecondaryPoW: consensus.CuckaroozPoW}}
+	chainType := consensus.ChainType(300)
+	assert.NoError(t, consensus.RegisterChainType(chainType, params))
+	ctx, err = createPoWContext(chainType, 10, 15, 42)
+	assert.NoError(t, err)
+	assert.IsType(t, &pow.CuckaroozContext{}, ctx)
+}
+
+func TestVerifyPoWBatch(t *testing.T) {
+	assert.Empty(t, VerifyPoWBatch(consensus.Mainnet, nil))
+
+	headers := make([]*BlockHeader, 100)
+	for i := range headers {
+		header := mainnetGenesisHeader
+		if i%3 == 0 {
+			header.PoW.Nonce++
+		}
+		headers[i] = &header
+	}
+	errs := VerifyPoWBatch(consensus.Mainnet, headers)
+	assert.Len(t, errs, len(headers))
+	for i, err := range errs {
+		if i%3 == 0 {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+
+	// The mainnet genesis is not valid for an unregistered chain type
+	errs = VerifyPoWBatch(consensus.ChainType(100), headers[1:2])
+	assert.Equal(t, []error{ErrUnknownChainType}, errs)
+}
+
+func benchmarkHeaders(n int) []*BlockHeader {
+	headers := make([]*BlockHeader, n)
+	for i := range headers {
+		header := mainnetGenesisHeader
+		headers[i] = &header
+	}
+	return headers
+}
+
+func BenchmarkVerifyPoW(b *testing.B) {
+	headers := benchmarkHeaders(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, header := range headers {
+			if err := header.VerifyPoW(consensus.Mainnet); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkVerifyPoWBatch(b *testing.B) {
+	headers := benchmarkHeaders(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, err := range VerifyPoWBatch(consensus.Mainnet, headers) {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
 }
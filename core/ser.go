@@ -0,0 +1,655 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/core/ser"
+	"github.com/blockcypher/libgrin/v5/util/secp"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// Size of a Pedersen commitment
+	pedersenCommitmentSize = 33
+	// Size of a secret key or blinding factor
+	secretKeySize = 32
+	// Size of a hash
+	hashSize = 32
+	// Maximum size of a range proof
+	maxProofSize = 5134
+)
+
+// TimestampLayout is the rfc3339 layout of the timestamps returned by the node
+const TimestampLayout = "2006-01-02T15:04:05-07:00"
+
+// Writes an hex string as a fixed number of bytes
+func writeHex(w *ser.Writer, s string, size int) error {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	if len(b) != size {
+		return errors.New("invalid length")
+	}
+	return w.WriteFixedBytes(b)
+}
+
+// Reads a fixed number of bytes as an hex string
+func readHex(r *ser.Reader, size int) (string, error) {
+	b, err := r.ReadFixedBytes(size)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Signatures are serialized in their raw form in binary but in their compact
+// form in JSON
+func writeSignature(w *ser.Writer, s string) error {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	sig, err := secp.SignatureFromCompact(b)
+	if err != nil {
+		return err
+	}
+	return w.WriteFixedBytes(sig[:])
+}
+
+func readSignature(r *ser.Reader) (string, error) {
+	b, err := r.ReadFixedBytes(secp.SignatureSize)
+	if err != nil {
+		return "", err
+	}
+	sig, err := secp.SignatureFromRaw(b)
+	if err != nil {
+		return "", err
+	}
+	return sig.CompactHex(), nil
+}
+
+// Write serializes the output features as a single byte
+func (s OutputFeatures) Write(w *ser.Writer) error {
+	return w.WriteU8(uint8(s))
+}
+
+// Read deserializes the output features
+func (s *OutputFeatures) Read(r *ser.Reader) error {
+	b, err := r.ReadU8()
+	if err != nil {
+		return err
+	}
+	if _, ok := toStringOutputFeatures[OutputFeatures(b)]; !ok {
+		return ser.ErrCorruptedData
+	}
+	*s = OutputFeatures(b)
+	return nil
+}
+
+// Write serializes the input features and commitment
+func (i *Input) Write(w *ser.Writer) error {
+	if err := i.Features.Write(w); err != nil {
+		return err
+	}
+	return writeHex(w, i.Commit, pedersenCommitmentSize)
+}
+
+// Read deserializes an input. From protocol version 3 inputs are serialized as
+// their commitment only and the features are left to plain.
+func (i *Input) Read(r *ser.Reader) error {
+	if r.ProtocolVersion() < ser.ProtocolVersion3 {
+		if err := i.Features.Read(r); err != nil {
+			return err
+		}
+	} else {
+		i.Features = PlainOutput
+	}
+	commit, err := readHex(r, pedersenCommitmentSize)
+	if err != nil {
+		return err
+	}
+	i.Commit = commit
+	return nil
+}
+
+// Write serializes the output identifier
+func (o *OutputIdentifier) Write(w *ser.Writer) error {
+	if err := o.Features.Write(w); err != nil {
+		return err
+	}
+	return writeHex(w, o.Commit, pedersenCommitmentSize)
+}
+
+// Read deserializes an output identifier
+func (o *OutputIdentifier) Read(r *ser.Reader) error {
+	if err := o.Features.Read(r); err != nil {
+		return err
+	}
+	commit, err := readHex(r, pedersenCommitmentSize)
+	if err != nil {
+		return err
+	}
+	o.Commit = commit
+	return nil
+}
+
+// Write serializes the output: features, commitment and length prefixed range
+// proof. The range proof is not part of the hash mode serialization.
+func (o *Output) Write(w *ser.Writer) error {
+	identifier := OutputIdentifier{Features: o.Features, Commit: o.Commit}
+	if err := identifier.Write(w); err != nil {
+		return err
+	}
+	if w.IsHashMode() {
+		return nil
+	}
+	proof, err := hex.DecodeString(o.Proof)
+	if err != nil {
+		return err
+	}
+	return w.WriteBytes(proof)
+}
+
+// Read deserializes an output
+func (o *Output) Read(r *ser.Reader) error {
+	var identifier OutputIdentifier
+	if err := identifier.Read(r); err != nil {
+		return err
+	}
+	proof, err := r.ReadBytes(maxProofSize)
+	if err != nil {
+		return err
+	}
+	o.Features = identifier.Features
+	o.Commit = identifier.Commit
+	o.Proof = hex.EncodeToString(proof)
+	return nil
+}
+
+// Write serializes the kernel. Kernels are hashed using the original v1
+// serialization, with fixed size feature data.
+func (k *TxKernel) Write(w *ser.Writer) error {
+	var err error
+	if w.IsHashMode() || w.ProtocolVersion() < ser.ProtocolVersion2 {
+		err = k.writeFeaturesV1(w)
+	} else {
+		err = k.writeFeaturesV2(w)
+	}
+	if err != nil {
+		return err
+	}
+	if err := writeHex(w, k.Excess, pedersenCommitmentSize); err != nil {
+		return err
+	}
+	return writeSignature(w, k.ExcessSig)
+}
+
+// V1 features are always a feature byte, 8 bytes for the fee and 8 bytes for
+// the lock height or relative height, zeroed when unused
+func (k *TxKernel) writeFeaturesV1(w *ser.Writer) error {
+	if err := w.WriteU8(uint8(k.Features)); err != nil {
+		return err
+	}
+	switch k.Features {
+	case PlainKernel:
+		if err := w.WriteU64(uint64(k.Fee)); err != nil {
+			return err
+		}
+		return w.WriteEmptyBytes(8)
+	case CoinbaseKernel:
+		return w.WriteEmptyBytes(16)
+	case HeightLockedKernel:
+		if err := w.WriteU64(uint64(k.Fee)); err != nil {
+			return err
+		}
+		return w.WriteU64(uint64(k.LockHeight))
+	case NoRecentDuplicateKernel:
+		if err := w.WriteU64(uint64(k.Fee)); err != nil {
+			return err
+		}
+		if err := w.WriteEmptyBytes(6); err != nil {
+			return err
+		}
+		return w.WriteU16(k.RelativeHeight)
+	default:
+		return errors.New("invalid kernel features")
+	}
+}
+
+// V2 features only have the data relevant to the feature variant
+func (k *TxKernel) writeFeaturesV2(w *ser.Writer) error {
+	if err := w.WriteU8(uint8(k.Features)); err != nil {
+		return err
+	}
+	switch k.Features {
+	case PlainKernel:
+		return w.WriteU64(uint64(k.Fee))
+	case CoinbaseKernel:
+		return nil
+	case HeightLockedKernel:
+		if err := w.WriteU64(uint64(k.Fee)); err != nil {
+			return err
+		}
+		return w.WriteU64(uint64(k.LockHeight))
+	case NoRecentDuplicateKernel:
+		if err := w.WriteU64(uint64(k.Fee)); err != nil {
+			return err
+		}
+		return w.WriteU16(k.RelativeHeight)
+	default:
+		return errors.New("invalid kernel features")
+	}
+}
+
+// Read deserializes a kernel
+func (k *TxKernel) Read(r *ser.Reader) error {
+	var kernel TxKernel
+	v1 := r.ProtocolVersion() < ser.ProtocolVersion2
+	featureByte, err := r.ReadU8()
+	if err != nil {
+		return err
+	}
+	kernel.Features = KernelFeatures(featureByte)
+	switch kernel.Features {
+	case PlainKernel:
+		fee, err := r.ReadU64()
+		if err != nil {
+			return err
+		}
+		kernel.Fee = Uint64(fee)
+		if v1 {
+			if err := r.ReadEmptyBytes(8); err != nil {
+				return err
+			}
+		}
+	case CoinbaseKernel:
+		if v1 {
+			if err := r.ReadEmptyBytes(16); err != nil {
+				return err
+			}
+		}
+	case HeightLockedKernel:
+		fee, err := r.ReadU64()
+		if err != nil {
+			return err
+		}
+		lockHeight, err := r.ReadU64()
+		if err != nil {
+			return err
+		}
+		kernel.Fee = Uint64(fee)
+		kernel.LockHeight = Uint64(lockHeight)
+	case NoRecentDuplicateKernel:
+		fee, err := r.ReadU64()
+		if err != nil {
+			return err
+		}
+		if v1 {
+			if err := r.ReadEmptyBytes(6); err != nil {
+				return err
+			}
+		}
+		relativeHeight, err := r.ReadU16()
+		if err != nil {
+			return err
+		}
+		if relativeHeight == 0 || uint64(relativeHeight) > consensus.WeekHeight {
+			return ser.ErrCorruptedData
+		}
+		kernel.Fee = Uint64(fee)
+		kernel.RelativeHeight = relativeHeight
+	default:
+		return ser.ErrCorruptedData
+	}
+	if kernel.Excess, err = readHex(r, pedersenCommitmentSize); err != nil {
+		return err
+	}
+	if kernel.ExcessSig, err = readSignature(r); err != nil {
+		return err
+	}
+	*k = kernel
+	return nil
+}
+
+// Write serializes the body: the number of inputs, outputs and kernels followed
+// by each of them. From protocol version 3 inputs are serialized as their
+// commitment only, sorted by hash.
+func (b *TransactionBody) Write(w *ser.Writer) error {
+	for _, n := range []int{len(b.Inputs), len(b.Outputs), len(b.Kernels)} {
+		if err := w.WriteU64(uint64(n)); err != nil {
+			return err
+		}
+	}
+	if w.IsHashMode() || w.ProtocolVersion() < ser.ProtocolVersion3 {
+		for i := range b.Inputs {
+			if err := b.Inputs[i].Write(w); err != nil {
+				return err
+			}
+		}
+	} else {
+		// Commitment only inputs are sorted by the hash of their commitment
+		commits := make([][]byte, len(b.Inputs))
+		for i, input := range b.Inputs {
+			commit, err := hex.DecodeString(input.Commit)
+			if err != nil {
+				return err
+			}
+			if len(commit) != pedersenCommitmentSize {
+				return errors.New("invalid length")
+			}
+			commits[i] = commit
+		}
+		sort.Slice(commits, func(i, j int) bool {
+			hi, hj := blake2b.Sum256(commits[i]), blake2b.Sum256(commits[j])
+			return bytes.Compare(hi[:], hj[:]) < 0
+		})
+		for _, commit := range commits {
+			if err := w.WriteFixedBytes(commit); err != nil {
+				return err
+			}
+		}
+	}
+	for i := range b.Outputs {
+		if err := b.Outputs[i].Write(w); err != nil {
+			return err
+		}
+	}
+	for i := range b.Kernels {
+		if err := b.Kernels[i].Write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read deserializes a body, rejecting bodies heavier than the max block weight
+func (b *TransactionBody) Read(r *ser.Reader) error {
+	var counts [3]uint64
+	for i := range counts {
+		n, err := r.ReadU64()
+		if err != nil {
+			return err
+		}
+		// Quick check before any multiplication could overflow
+		if n > uint64(consensus.MaxBlockWeight) {
+			return ser.ErrTooLargeRead
+		}
+		counts[i] = n
+	}
+	weight := counts[0]*uint64(consensus.BlockInputWeight) +
+		counts[1]*uint64(consensus.BlockOutputWeight) +
+		counts[2]*uint64(consensus.BlockKernelWeight)
+	if weight > uint64(consensus.MaxBlockWeight) {
+		return ser.ErrTooLargeRead
+	}
+	body := TransactionBody{
+		Inputs:  make([]Input, counts[0]),
+		Outputs: make([]Output, counts[1]),
+		Kernels: make([]TxKernel, counts[2]),
+	}
+	for i := range body.Inputs {
+		if err := body.Inputs[i].Read(r); err != nil {
+			return err
+		}
+	}
+	for i := range body.Outputs {
+		if err := body.Outputs[i].Read(r); err != nil {
+			return err
+		}
+	}
+	for i := range body.Kernels {
+		if err := body.Kernels[i].Read(r); err != nil {
+			return err
+		}
+	}
+	*b = body
+	return nil
+}
+
+// Write serializes the transaction offset and body
+func (tx *Transaction) Write(w *ser.Writer) error {
+	if err := writeHex(w, tx.Offset, secretKeySize); err != nil {
+		return err
+	}
+	return tx.Body.Write(w)
+}
+
+// Read deserializes a transaction
+func (tx *Transaction) Read(r *ser.Reader) error {
+	offset, err := readHex(r, secretKeySize)
+	if err != nil {
+		return err
+	}
+	var body TransactionBody
+	if err := body.Read(r); err != nil {
+		return err
+	}
+	tx.Offset = offset
+	tx.Body = body
+	return nil
+}
+
+// Write serializes the header. In hash mode only the proof of work nonces are
+// written.
+func (h *BlockHeader) Write(w *ser.Writer) error {
+	if !w.IsHashMode() {
+		if err := h.WritePrePoW(w); err != nil {
+			return err
+		}
+	}
+	return h.PoW.Write(w)
+}
+
+// WritePrePoW writes the pre-hash portion of the header, without the proof of
+// work
+func (h *BlockHeader) WritePrePoW(w *ser.Writer) error {
+	timestamp, err := time.Parse(time.RFC3339, h.Timestamp)
+	if err != nil {
+		return err
+	}
+	if err := w.WriteU16(h.Version); err != nil {
+		return err
+	}
+	if err := w.WriteU64(h.Height); err != nil {
+		return err
+	}
+	if err := w.WriteI64(timestamp.Unix()); err != nil {
+		return err
+	}
+	for _, hash := range []string{h.PrevHash, h.PrevRoot, h.OutputRoot, h.RangeProofRoot, h.KernelRoot} {
+		if err := writeHex(w, hash, hashSize); err != nil {
+			return err
+		}
+	}
+	if err := writeHex(w, h.TotalKernelOffset, secretKeySize); err != nil {
+		return err
+	}
+	if err := w.WriteU64(h.OutputMmrSize); err != nil {
+		return err
+	}
+	return w.WriteU64(h.KernelMmrSize)
+}
+
+// Read deserializes a header of the given chain type, which defines the proof size
+func (h *BlockHeader) Read(r *ser.Reader, chainType consensus.ChainType) error {
+	var header BlockHeader
+	var err error
+	if header.Version, err = r.ReadU16(); err != nil {
+		return err
+	}
+	if header.Height, err = r.ReadU64(); err != nil {
+		return err
+	}
+	timestamp, err := r.ReadI64()
+	if err != nil {
+		return err
+	}
+	header.Timestamp = time.Unix(timestamp, 0).UTC().Format(TimestampLayout)
+	hashes := []*string{&header.PrevHash, &header.PrevRoot, &header.OutputRoot, &header.RangeProofRoot, &header.KernelRoot}
+	for _, hash := range hashes {
+		if *hash, err = readHex(r, hashSize); err != nil {
+			return err
+		}
+	}
+	if header.TotalKernelOffset, err = readHex(r, secretKeySize); err != nil {
+		return err
+	}
+	if header.OutputMmrSize, err = r.ReadU64(); err != nil {
+		return err
+	}
+	if header.KernelMmrSize, err = r.ReadU64(); err != nil {
+		return err
+	}
+	if err := header.PoW.Read(r, consensus.ChainTypeProofSize(chainType)); err != nil {
+		return err
+	}
+	*h = header
+	return nil
+}
+
+// Write serializes the block: its header followed by its body. Only the
+// header is hashed.
+func (b *Block) Write(w *ser.Writer) error {
+	if err := b.Header.Write(w); err != nil {
+		return err
+	}
+	if w.IsHashMode() {
+		return nil
+	}
+	return b.Body.Write(w)
+}
+
+// Read deserializes a block
+func (b *Block) Read(r *ser.Reader, chainType consensus.ChainType) error {
+	var block Block
+	if err := block.Header.Read(r, chainType); err != nil {
+		return err
+	}
+	if err := block.Body.Read(r); err != nil {
+		return err
+	}
+	*b = block
+	return nil
+}
+
+// Write serializes the short id
+func (id *ShortID) Write(w *ser.Writer) error {
+	return w.WriteFixedBytes(id[:])
+}
+
+// Read deserializes a short id
+func (id *ShortID) Read(r *ser.Reader) error {
+	b, err := r.ReadFixedBytes(ShortIDSize)
+	if err != nil {
+		return err
+	}
+	copy(id[:], b)
+	return nil
+}
+
+// Write serializes the compact block: its header, nonce, and the counts then
+// the full outputs, full kernels and short ids. Only the header is hashed.
+func (cb *CompactBlock) Write(w *ser.Writer) error {
+	if err := cb.Header.Write(w); err != nil {
+		return err
+	}
+	if w.IsHashMode() {
+		return nil
+	}
+	if err := w.WriteU64(cb.Nonce); err != nil {
+		return err
+	}
+	for _, n := range []int{len(cb.OutFull), len(cb.KernFull), len(cb.KernIDs)} {
+		if err := w.WriteU64(uint64(n)); err != nil {
+			return err
+		}
+	}
+	for i := range cb.OutFull {
+		if err := cb.OutFull[i].Write(w); err != nil {
+			return err
+		}
+	}
+	for i := range cb.KernFull {
+		if err := cb.KernFull[i].Write(w); err != nil {
+			return err
+		}
+	}
+	for i := range cb.KernIDs {
+		if err := cb.KernIDs[i].Write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read deserializes a compact block, which must be sorted
+func (cb *CompactBlock) Read(r *ser.Reader, chainType consensus.ChainType) error {
+	var block CompactBlock
+	if err := block.Header.Read(r, chainType); err != nil {
+		return err
+	}
+	var err error
+	if block.Nonce, err = r.ReadU64(); err != nil {
+		return err
+	}
+	var counts [3]uint64
+	for i := range counts {
+		n, err := r.ReadU64()
+		if err != nil {
+			return err
+		}
+		// Each short id stands for a kernel of the block
+		if n > uint64(consensus.MaxBlockWeight) {
+			return ser.ErrTooLargeRead
+		}
+		counts[i] = n
+	}
+	weight := counts[0]*uint64(consensus.BlockOutputWeight) +
+		(counts[1]+counts[2])*uint64(consensus.BlockKernelWeight)
+	if weight > uint64(consensus.MaxBlockWeight) {
+		return ser.ErrTooLargeRead
+	}
+	block.OutFull = make([]Output, counts[0])
+	block.KernFull = make([]TxKernel, counts[1])
+	block.KernIDs = make([]ShortID, counts[2])
+	for i := range block.OutFull {
+		if err := block.OutFull[i].Read(r); err != nil {
+			return err
+		}
+	}
+	for i := range block.KernFull {
+		if err := block.KernFull[i].Read(r); err != nil {
+			return err
+		}
+	}
+	for i := range block.KernIDs {
+		if err := block.KernIDs[i].Read(r); err != nil {
+			return err
+		}
+	}
+	if err := block.verifySorted(); err != nil {
+		return err
+	}
+	*cb = block
+	return nil
+}
@@ -0,0 +1,245 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ser implements grin's binary serialization: big-endian integers,
+// fixed size and length prefixed byte arrays, with protocol versions and a
+// hash mode used when serializing for hashing purposes.
+package ser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ProtocolVersion is the version of the binary serialization, some types
+// (kernels, inputs) are serialized differently depending on it
+type ProtocolVersion uint32
+
+const (
+	// LocalDBProtocolVersion is the protocol version used by the node local database
+	LocalDBProtocolVersion ProtocolVersion = 1
+	// ProtocolVersion2 serializes kernels with variable size feature data
+	ProtocolVersion2 ProtocolVersion = 2
+	// ProtocolVersion3 serializes inputs as commitments only
+	ProtocolVersion3 ProtocolVersion = 3
+	// CurrentProtocolVersion is the protocol version of the node (PROTOCOL_VERSION)
+	CurrentProtocolVersion ProtocolVersion = 1000
+)
+
+// SerializationMode signals to a serializable object how much of its data
+// should be serialized
+type SerializationMode int
+
+const (
+	// FullMode serializes everything sufficiently to fully reconstruct the object
+	FullMode SerializationMode = iota
+	// HashMode serializes the data that defines the object
+	HashMode
+)
+
+var (
+	// ErrCorruptedData is returned when the data read is invalid
+	ErrCorruptedData = errors.New("corrupted data")
+	// ErrUnexpectedData is returned when non empty bytes are read where empty
+	// bytes are expected
+	ErrUnexpectedData = errors.New("unexpected data")
+	// ErrTooLargeRead is returned when a length read exceeds the allowed maximum
+	ErrTooLargeRead = errors.New("too large read")
+	// ErrUnsupportedProtocolVersion is returned when the data can't be
+	// serialized with the requested protocol version
+	ErrUnsupportedProtocolVersion = errors.New("unsupported protocol version")
+)
+
+// Writeable is implemented by types that can be serialized to binary
+type Writeable interface {
+	Write(w *Writer) error
+}
+
+// Serialize serializes a writeable in memory
+func Serialize(thing Writeable, version ProtocolVersion, mode SerializationMode) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := thing.Write(NewWriter(&buf, version, mode)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Writer writes integers and byte arrays to an underlying stream
+type Writer struct {
+	w       io.Writer
+	version ProtocolVersion
+	mode    SerializationMode
+}
+
+// NewWriter creates a new writer
+func NewWriter(w io.Writer, version ProtocolVersion, mode SerializationMode) *Writer {
+	return &Writer{w: w, version: version, mode: mode}
+}
+
+// ProtocolVersion is the protocol version of the writer
+func (w *Writer) ProtocolVersion() ProtocolVersion {
+	return w.version
+}
+
+// IsHashMode is whether the writer is serializing for hashing purposes
+func (w *Writer) IsHashMode() bool {
+	return w.mode == HashMode
+}
+
+// WriteU8 writes an uint8
+func (w *Writer) WriteU8(n uint8) error {
+	return w.WriteFixedBytes([]byte{n})
+}
+
+// WriteU16 writes an uint16 as big-endian bytes
+func (w *Writer) WriteU16(n uint16) error {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], n)
+	return w.WriteFixedBytes(b[:])
+}
+
+// WriteU32 writes an uint32 as big-endian bytes
+func (w *Writer) WriteU32(n uint32) error {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], n)
+	return w.WriteFixedBytes(b[:])
+}
+
+// WriteU64 writes an uint64 as big-endian bytes
+func (w *Writer) WriteU64(n uint64) error {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], n)
+	return w.WriteFixedBytes(b[:])
+}
+
+// WriteI64 writes an int64 as big-endian bytes
+func (w *Writer) WriteI64(n int64) error {
+	return w.WriteU64(uint64(n))
+}
+
+// WriteBytes writes a variable number of bytes prefixed by their length as an uint64
+func (w *Writer) WriteBytes(b []byte) error {
+	if err := w.WriteU64(uint64(len(b))); err != nil {
+		return err
+	}
+	return w.WriteFixedBytes(b)
+}
+
+// WriteFixedBytes writes a fixed number of bytes, the reader is expected to
+// know the length
+func (w *Writer) WriteFixedBytes(b []byte) error {
+	_, err := w.w.Write(b)
+	return err
+}
+
+// WriteEmptyBytes writes a fixed number of zero bytes
+func (w *Writer) WriteEmptyBytes(length int) error {
+	return w.WriteFixedBytes(make([]byte, length))
+}
+
+// Reader reads integers and byte arrays from an underlying stream
+type Reader struct {
+	r       io.Reader
+	version ProtocolVersion
+}
+
+// NewReader creates a new reader
+func NewReader(r io.Reader, version ProtocolVersion) *Reader {
+	return &Reader{r: r, version: version}
+}
+
+// ProtocolVersion is the protocol version of the reader
+func (r *Reader) ProtocolVersion() ProtocolVersion {
+	return r.version
+}
+
+// ReadU8 reads an uint8
+func (r *Reader) ReadU8() (uint8, error) {
+	b, err := r.ReadFixedBytes(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// ReadU16 reads a big-endian uint16
+func (r *Reader) ReadU16() (uint16, error) {
+	b, err := r.ReadFixedBytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b), nil
+}
+
+// ReadU32 reads a big-endian uint32
+func (r *Reader) ReadU32() (uint32, error) {
+	b, err := r.ReadFixedBytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+// ReadU64 reads a big-endian uint64
+func (r *Reader) ReadU64() (uint64, error) {
+	b, err := r.ReadFixedBytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// ReadI64 reads a big-endian int64
+func (r *Reader) ReadI64() (int64, error) {
+	n, err := r.ReadU64()
+	return int64(n), err
+}
+
+// ReadBytes reads a variable number of bytes prefixed by their length,
+// returning ErrTooLargeRead if the length is above maxLength
+func (r *Reader) ReadBytes(maxLength int) ([]byte, error) {
+	length, err := r.ReadU64()
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(maxLength) {
+		return nil, ErrTooLargeRead
+	}
+	return r.ReadFixedBytes(int(length))
+}
+
+// ReadFixedBytes reads a fixed number of bytes
+func (r *Reader) ReadFixedBytes(length int) ([]byte, error) {
+	b := make([]byte, length)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ReadEmptyBytes reads a fixed number of bytes that must all be zero
+func (r *Reader) ReadEmptyBytes(length int) error {
+	b, err := r.ReadFixedBytes(length)
+	if err != nil {
+		return err
+	}
+	for _, x := range b {
+		if x != 0 {
+			return ErrUnexpectedData
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteRead(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, CurrentProtocolVersion, FullMode)
+	assert.NoError(t, w.WriteU8(1))
+	assert.NoError(t, w.WriteU16(0x0203))
+	assert.NoError(t, w.WriteU32(0x04050607))
+	assert.NoError(t, w.WriteU64(0x08090a0b0c0d0e0f))
+	assert.NoError(t, w.WriteI64(-1))
+	assert.NoError(t, w.WriteBytes([]byte{0xaa, 0xbb}))
+	assert.NoError(t, w.WriteEmptyBytes(3))
+	assert.Equal(t, []byte{
+		0x01,
+		0x02, 0x03,
+		0x04, 0x05, 0x06, 0x07,
+		0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0, 0, 0, 0, 0, 0, 0, 2, 0xaa, 0xbb,
+		0, 0, 0,
+	}, buf.Bytes())
+
+	r := NewReader(bytes.NewReader(buf.Bytes()), CurrentProtocolVersion)
+	u8, err := r.ReadU8()
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(1), u8)
+	u16, err := r.ReadU16()
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x0203), u16)
+	u32, err := r.ReadU32()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0x04050607), u32)
+	u64, err := r.ReadU64()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0x08090a0b0c0d0e0f), u64)
+	i64, err := r.ReadI64()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), i64)
+	b, err := r.ReadBytes(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xaa, 0xbb}, b)
+	assert.NoError(t, r.ReadEmptyBytes(3))
+	_, err = r.ReadU8()
+	assert.Error(t, err)
+}
+
+func TestReadErrors(t *testing.T) {
+	r := NewReader(bytes.NewReader([]byte{0, 0, 0, 0, 0, 0, 0, 3, 1, 2, 3}), LocalDBProtocolVersion)
+	_, err := r.ReadBytes(2)
+	assert.Equal(t, ErrTooLargeRead, err)
+
+	r = NewReader(bytes.NewReader([]byte{0, 1}), LocalDBProtocolVersion)
+	assert.Equal(t, ErrUnexpectedData, r.ReadEmptyBytes(2))
+}
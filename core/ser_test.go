@@ -0,0 +1,161 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/core/ser"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Mainnet genesis header and coinbase
+var (
+	mainnetGenesisHeader = genesisMain().Header
+	mainnetGenesisBody   = genesisMain().Body
+)
+
+func blake2bHex(b []byte) string {
+	hash := blake2b.Sum256(b)
+	return hex.EncodeToString(hash[:])
+}
+
+func TestSerializeGenesisHeader(t *testing.T) {
+	// The header hash is the hash of its hash mode serialization
+	hashBytes, err := ser.Serialize(&mainnetGenesisHeader, ser.CurrentProtocolVersion, ser.HashMode)
+	assert.NoError(t, err)
+	assert.Equal(t, "40adad0aec27797b48840aa9e00472015c21baea118ce7a2ff1a82c0f8f5bf82", blake2bHex(hashBytes))
+
+	headerBytes, err := ser.Serialize(&mainnetGenesisHeader, ser.CurrentProtocolVersion, ser.FullMode)
+	assert.NoError(t, err)
+	// 2 + 8 + 8 + 5 * 32 + 32 + 8 + 8 + 8 + 4 + 8 + 1 + (29 * 42 + 7) / 8
+	assert.Len(t, headerBytes, 400)
+
+	var header BlockHeader
+	r := ser.NewReader(bytes.NewReader(headerBytes), ser.CurrentProtocolVersion)
+	assert.NoError(t, header.Read(r, consensus.Mainnet))
+	assert.Equal(t, mainnetGenesisHeader, header)
+}
+
+func TestSerializeGenesisBlock(t *testing.T) {
+	var buf bytes.Buffer
+	w := ser.NewWriter(&buf, ser.LocalDBProtocolVersion, ser.FullMode)
+	assert.NoError(t, mainnetGenesisHeader.Write(w))
+	assert.NoError(t, mainnetGenesisBody.Write(w))
+	assert.Equal(t, "6be6f34b657b785e558e85cc3b8bdb5bcbe8c10e7e58524c8027da7727e189ef", blake2bHex(buf.Bytes()))
+
+	var header BlockHeader
+	var body TransactionBody
+	r := ser.NewReader(bytes.NewReader(buf.Bytes()), ser.LocalDBProtocolVersion)
+	assert.NoError(t, header.Read(r, consensus.Mainnet))
+	assert.NoError(t, body.Read(r))
+	assert.Equal(t, mainnetGenesisBody, body)
+
+	block := Block{Header: mainnetGenesisHeader, Body: mainnetGenesisBody}
+	blockBytes, err := ser.Serialize(&block, ser.LocalDBProtocolVersion, ser.FullMode)
+	assert.NoError(t, err)
+	assert.Equal(t, buf.Bytes(), blockBytes)
+	var read Block
+	assert.NoError(t, read.Read(ser.NewReader(bytes.NewReader(blockBytes), ser.LocalDBProtocolVersion), consensus.Mainnet))
+	assert.Equal(t, block, read)
+}
+
+func TestSerializeOutputHashMode(t *testing.T) {
+	output := mainnetGenesisBody.Outputs[0]
+	fullBytes, err := ser.Serialize(&output, ser.CurrentProtocolVersion, ser.FullMode)
+	assert.NoError(t, err)
+	// 1 + 33 + 8 + 675
+	assert.Len(t, fullBytes, 717)
+
+	// The range proof is left out of the hash, the genesis output root being
+	// the hash of the coinbase output with its position 0 prepended
+	hashBytes, err := ser.Serialize(&output, ser.CurrentProtocolVersion, ser.HashMode)
+	assert.NoError(t, err)
+	assert.Equal(t, fullBytes[:34], hashBytes)
+	assert.Equal(t, mainnetGenesisHeader.OutputRoot, blake2bHex(append(make([]byte, 8), hashBytes...)))
+}
+
+func TestSerializeKernel(t *testing.T) {
+	kernel := TxKernel{
+		Features:   HeightLockedKernel,
+		Fee:        7000000,
+		LockHeight: 100,
+		Excess:     mainnetGenesisBody.Kernels[0].Excess,
+		ExcessSig:  mainnetGenesisBody.Kernels[0].ExcessSig,
+	}
+	v1, err := ser.Serialize(&kernel, ser.LocalDBProtocolVersion, ser.FullMode)
+	assert.NoError(t, err)
+	v2, err := ser.Serialize(&kernel, ser.ProtocolVersion2, ser.FullMode)
+	assert.NoError(t, err)
+	hash, err := ser.Serialize(&kernel, ser.CurrentProtocolVersion, ser.HashMode)
+	assert.NoError(t, err)
+	assert.Equal(t, v1, hash)
+	assert.Equal(t, v1, v2)
+	assert.Equal(t, "0200000000006acfc00000000000000064", hex.EncodeToString(v1[:17]))
+
+	// Plain kernels have no lock height in v2
+	kernel = TxKernel{Features: PlainKernel, Fee: 7000000, Excess: kernel.Excess, ExcessSig: kernel.ExcessSig}
+	v1, err = ser.Serialize(&kernel, ser.LocalDBProtocolVersion, ser.FullMode)
+	assert.NoError(t, err)
+	v2, err = ser.Serialize(&kernel, ser.ProtocolVersion2, ser.FullMode)
+	assert.NoError(t, err)
+	assert.Len(t, v1, 17+33+64)
+	assert.Len(t, v2, 9+33+64)
+
+	var read TxKernel
+	assert.NoError(t, read.Read(ser.NewReader(bytes.NewReader(v2), ser.ProtocolVersion2)))
+	assert.Equal(t, kernel, read)
+
+	// NRD kernels with a relative height above a week are invalid
+	kernel = TxKernel{Features: NoRecentDuplicateKernel, Fee: 1, RelativeHeight: 10081, Excess: kernel.Excess, ExcessSig: kernel.ExcessSig}
+	v2, err = ser.Serialize(&kernel, ser.ProtocolVersion2, ser.FullMode)
+	assert.NoError(t, err)
+	assert.Equal(t, ser.ErrCorruptedData, read.Read(ser.NewReader(bytes.NewReader(v2), ser.ProtocolVersion2)))
+}
+
+func TestSerializeTransactionV3(t *testing.T) {
+	tx := Transaction{
+		Offset: zeroHashHex,
+		Body: TransactionBody{
+			Inputs: []Input{
+				{Features: CoinbaseOutput, Commit: "09b7e57c448db5ef25aa119dde2312c64d7ff1b890c416c6dda5ec73cbfed2edea"},
+				{Features: PlainOutput, Commit: "08b7e57c448db5ef25aa119dde2312c64d7ff1b890c416c6dda5ec73cbfed2edea"},
+			},
+			Outputs: mainnetGenesisBody.Outputs,
+			Kernels: mainnetGenesisBody.Kernels,
+		},
+	}
+	v3, err := ser.Serialize(&tx, ser.ProtocolVersion3, ser.FullMode)
+	assert.NoError(t, err)
+	v2, err := ser.Serialize(&tx, ser.ProtocolVersion2, ser.FullMode)
+	assert.NoError(t, err)
+	// Inputs have no features from v3
+	assert.Equal(t, len(v2)-2, len(v3))
+
+	var read Transaction
+	assert.NoError(t, read.Read(ser.NewReader(bytes.NewReader(v3), ser.ProtocolVersion3)))
+	// Commit only inputs are sorted by the hash of their commitment
+	assert.Equal(t, []Input{
+		{Features: PlainOutput, Commit: tx.Body.Inputs[0].Commit},
+		{Features: PlainOutput, Commit: tx.Body.Inputs[1].Commit},
+	}, read.Body.Inputs)
+
+	assert.NoError(t, read.Read(ser.NewReader(bytes.NewReader(v2), ser.ProtocolVersion2)))
+	assert.Equal(t, tx, read)
+}
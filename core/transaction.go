@@ -16,9 +16,16 @@ package core
 
 import (
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
 	"strconv"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/core/ser"
+	"golang.org/x/crypto/blake2b"
 )
 
 // Uint64 is an uint64 that can be unmarshal from a string or uint64 is
@@ -93,27 +100,77 @@ func (s KernelFeatures) MarshalJSON() ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
-// UnmarshalJSON unmarshals a quoted json string to the enum value
+// UnmarshalJSON unmarshals a quoted json string to the enum value. The node
+// represents features carrying data as a single key object (e.g.
+// {"Plain":{"fee":7000000}}), in which case the key is the enum value.
 func (s *KernelFeatures) UnmarshalJSON(b []byte) error {
 	var j string
 	err := json.Unmarshal(b, &j)
 	if err != nil {
-		// try to deserialize as map
+		// try to deserialize as a variant
 		var j2 map[string]json.RawMessage
 		err := json.Unmarshal(b, &j2)
 		if err != nil {
 			return err
 		}
-		// Hack to get the first key, TODO properly deserialize this
+		if len(j2) != 1 {
+			return errors.New("expected a single kernel features variant")
+		}
 		for k := range j2 {
 			j = k
 		}
 	}
-	// Note that if the string cannot be found then it will be set to the zero value, 'Created' in this case.
+	// Note that if the string cannot be found then it will be set to the zero value, 'Plain' in this case.
 	*s = toIDKernelFeatures[j]
 	return nil
 }
 
+// ParseKernelFeatures parses the name of kernel features (e.g. "Plain")
+func ParseKernelFeatures(name string) (KernelFeatures, error) {
+	features, ok := toIDKernelFeatures[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown kernel features %q", name)
+	}
+	return features, nil
+}
+
+// ErrInvalidFeeFields is returned when the fee or the fee shift do not fit in
+// the fee fields
+var ErrInvalidFeeFields = errors.New("invalid fee fields")
+
+// FeeFields is the 64-bit kernel fee field since HF4: the fee in the lower 40
+// bits and the fee shift in the next 4 bits. A fee shift above 0 is a priority
+// request, the transaction is accepted and ordered in the pool as if the fee
+// was shifted right by it.
+type FeeFields uint64
+
+const (
+	// Number of bits of the fee
+	feeBits = 40
+	// Mask of the fee
+	feeMask = 1<<feeBits - 1
+	// Mask of the fee shift, after shifting right by the fee bits
+	feeShiftMask = 0xf
+)
+
+// NewFeeFields packs a fee and a fee shift into fee fields
+func NewFeeFields(feeShift uint8, fee uint64) (FeeFields, error) {
+	if fee == 0 || fee > feeMask || feeShift > feeShiftMask {
+		return 0, ErrInvalidFeeFields
+	}
+	return FeeFields(uint64(feeShift)<<feeBits | fee), nil
+}
+
+// Fee is the fee paid
+func (f FeeFields) Fee() uint64 {
+	return uint64(f) & feeMask
+}
+
+// FeeShift is the requested priority
+func (f FeeFields) FeeShift() uint8 {
+	return uint8(uint64(f) >> feeBits & feeShiftMask)
+}
+
 // TxKernel is a proof that a transaction sums to zero. Includes both the transaction's
 // Pedersen commitment and the signature, that guarantees that the commitments
 // amount to zero.
@@ -122,6 +179,15 @@ func (s *KernelFeatures) UnmarshalJSON(b []byte) error {
 type TxKernel struct {
 	// Options for a kernel's structure or use
 	Features KernelFeatures `json:"features"`
+	// Fee fields of the kernel, the fee and the fee shift packed as FeeFields
+	// (fee | fee shift << 40), read from the features variant. Zero for
+	// coinbase kernels.
+	Fee Uint64 `json:"-"`
+	// Lock height of height locked kernels, read from the features variant
+	LockHeight Uint64 `json:"-"`
+	// Relative height of no recent duplicate kernels, read from the features
+	// variant
+	RelativeHeight uint16 `json:"-"`
 	// Remainder of the sum of all transaction commitments. If the transaction
 	// is well formed, amounts components should sum to zero and the excess
 	// is hence a valid public key.
@@ -131,6 +197,100 @@ type TxKernel struct {
 	ExcessSig string `json:"excess_sig"`
 }
 
+// Data carried by the kernel features variants, the fields not relevant to
+// the variant are omitted. Numbers are written as the node does but can be
+// read from strings too.
+type kernelFeaturesData struct {
+	Fee            *uint64 `json:"fee,omitempty"`
+	LockHeight     *uint64 `json:"lock_height,omitempty"`
+	RelativeHeight *uint16 `json:"relative_height,omitempty"`
+}
+
+type kernelFeaturesDataRead struct {
+	Fee            *Uint64 `json:"fee"`
+	LockHeight     *Uint64 `json:"lock_height"`
+	RelativeHeight *uint16 `json:"relative_height"`
+}
+
+// Kernel as represented by the node, with the features as a tagged variant
+type txKernelJSON struct {
+	Features  json.RawMessage `json:"features"`
+	Excess    string          `json:"excess"`
+	ExcessSig string          `json:"excess_sig"`
+}
+
+// MarshalJSON marshals the kernel the way the node does, with the fee, lock
+// height and relative height in the features variant (e.g.
+// {"HeightLocked":{"fee":7000000,"lock_height":10}}). Coinbase features carry
+// no data and are a plain string.
+func (k TxKernel) MarshalJSON() ([]byte, error) {
+	fee := uint64(k.Fee)
+	var data kernelFeaturesData
+	switch k.Features {
+	case PlainKernel:
+		data.Fee = &fee
+	case CoinbaseKernel:
+	case HeightLockedKernel:
+		lockHeight := uint64(k.LockHeight)
+		data.Fee = &fee
+		data.LockHeight = &lockHeight
+	case NoRecentDuplicateKernel:
+		relativeHeight := k.RelativeHeight
+		data.Fee = &fee
+		data.RelativeHeight = &relativeHeight
+	default:
+		return nil, fmt.Errorf("unknown kernel features %d", k.Features)
+	}
+
+	var features []byte
+	var err error
+	if k.Features == CoinbaseKernel {
+		features, err = json.Marshal(k.Features)
+	} else {
+		features, err = json.Marshal(map[string]kernelFeaturesData{k.Features.String(): data})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(txKernelJSON{Features: features, Excess: k.Excess, ExcessSig: k.ExcessSig})
+}
+
+// UnmarshalJSON unmarshals a kernel, reading the fee, lock height and relative
+// height from the features variant (e.g. {"Plain":{"fee":7000000}}) when there
+// is one
+func (k *TxKernel) UnmarshalJSON(b []byte) error {
+	var kernelJSON txKernelJSON
+	if err := json.Unmarshal(b, &kernelJSON); err != nil {
+		return err
+	}
+	kernel := TxKernel{Excess: kernelJSON.Excess, ExcessSig: kernelJSON.ExcessSig}
+	if err := json.Unmarshal(kernelJSON.Features, &kernel.Features); err != nil {
+		return err
+	}
+	var variant map[string]kernelFeaturesDataRead
+	// Features without data (e.g. "Coinbase") are plain strings
+	if err := json.Unmarshal(kernelJSON.Features, &variant); err == nil {
+		for _, data := range variant {
+			if data.Fee != nil {
+				kernel.Fee = *data.Fee
+			}
+			if data.LockHeight != nil {
+				kernel.LockHeight = *data.LockHeight
+			}
+			if data.RelativeHeight != nil {
+				kernel.RelativeHeight = *data.RelativeHeight
+			}
+		}
+	}
+	*k = kernel
+	return nil
+}
+
+// FeeFields are the fee fields of the kernel, packed in its fee
+func (k *TxKernel) FeeFields() FeeFields {
+	return FeeFields(k.Fee)
+}
+
 // TransactionBody is a common abstraction for transaction and block
 type TransactionBody struct {
 	// List of inputs spent by the transaction.
@@ -141,6 +301,48 @@ type TransactionBody struct {
 	Kernels []TxKernel `json:"kernels"`
 }
 
+// Weight is the weight of the body when counted against the max block weight
+// capacity, also used for fees
+func (b *TransactionBody) Weight() uint64 {
+	return consensus.TxBlockWeight(uint64(len(b.Inputs)), uint64(len(b.Outputs)), uint64(len(b.Kernels)))
+}
+
+// Fee is the sum of the fees of all the kernels
+func (b *TransactionBody) Fee() uint64 {
+	var fee uint64
+	for _, kernel := range b.Kernels {
+		fee += kernel.FeeFields().Fee()
+	}
+	return fee
+}
+
+// FeeShift is the maximum fee shift of all the kernels
+func (b *TransactionBody) FeeShift() uint8 {
+	var feeShift uint8
+	for _, kernel := range b.Kernels {
+		if shift := kernel.FeeFields().FeeShift(); shift > feeShift {
+			feeShift = shift
+		}
+	}
+	return feeShift
+}
+
+// ShiftedFee is the fee shifted right by the fee shift, used to decide whether
+// the transaction is accepted in the pool and relayed
+func (b *TransactionBody) ShiftedFee() uint64 {
+	return b.Fee() >> b.FeeShift()
+}
+
+// FeeRate is the shifted fee per unit of weight, used for block inclusion
+// priority
+func (b *TransactionBody) FeeRate() uint64 {
+	weight := b.Weight()
+	if weight == 0 {
+		return 0
+	}
+	return b.ShiftedFee() / weight
+}
+
 // Transaction represents a transaction
 type Transaction struct {
 	// The kernel "offset" k2
@@ -232,3 +434,94 @@ type OutputIdentifier struct {
 	// Output commitment
 	Commit string `json:"commit"`
 }
+
+// Hashes a core type: the blake2b hash of its hash mode serialization
+func hashWriteable(thing ser.Writeable) ([32]byte, error) {
+	b, err := ser.Serialize(thing, ser.CurrentProtocolVersion, ser.HashMode)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return blake2b.Sum256(b), nil
+}
+
+// Hash of an input, by which inputs with their features are sorted
+func (i *Input) hash() ([32]byte, error) {
+	return hashWriteable(i)
+}
+
+// Hash of an output, by which outputs are sorted
+func (o *Output) hash() ([32]byte, error) {
+	return hashWriteable(o)
+}
+
+// Hash of a kernel, by which kernels are sorted
+func (k *TxKernel) hash() ([32]byte, error) {
+	return hashWriteable(k)
+}
+
+// Hash of a commitment, by which commitment only inputs are sorted
+func commitHash(commit string) ([32]byte, error) {
+	b, err := hex.DecodeString(commit)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	if len(b) != pedersenCommitmentSize {
+		return [32]byte{}, errors.New("invalid length")
+	}
+	return blake2b.Sum256(b), nil
+}
+
+// Sorts elements by their hashes, swapping them with the swap function
+type hashSorter struct {
+	hashes [][32]byte
+	swap   func(i, j int)
+}
+
+func (s hashSorter) Len() int {
+	return len(s.hashes)
+}
+
+func (s hashSorter) Less(i, j int) bool {
+	return bytes.Compare(s.hashes[i][:], s.hashes[j][:]) < 0
+}
+
+func (s hashSorter) Swap(i, j int) {
+	s.hashes[i], s.hashes[j] = s.hashes[j], s.hashes[i]
+	s.swap(i, j)
+}
+
+// Sorts n elements by hash
+func sortByHash(n int, hash func(i int) ([32]byte, error), swap func(i, j int)) error {
+	hashes := make([][32]byte, n)
+	for i := range hashes {
+		var err error
+		if hashes[i], err = hash(i); err != nil {
+			return err
+		}
+	}
+	sort.Sort(hashSorter{hashes: hashes, swap: swap})
+	return nil
+}
+
+// Sort sorts the inputs, outputs and kernels by hash, the order grin requires
+func (b *TransactionBody) Sort() error {
+	if err := sortByHash(len(b.Inputs), func(i int) ([32]byte, error) {
+		return b.Inputs[i].hash()
+	}, func(i, j int) {
+		b.Inputs[i], b.Inputs[j] = b.Inputs[j], b.Inputs[i]
+	}); err != nil {
+		return err
+	}
+	if err := sortByHash(len(b.Outputs), func(i int) ([32]byte, error) {
+		return b.Outputs[i].hash()
+	}, func(i, j int) {
+		b.Outputs[i], b.Outputs[j] = b.Outputs[j], b.Outputs[i]
+	}); err != nil {
+		return err
+	}
+	return sortByHash(len(b.Kernels), func(i int) ([32]byte, error) {
+		return b.Kernels[i].hash()
+	}, func(i, j int) {
+		b.Kernels[i], b.Kernels[j] = b.Kernels[j], b.Kernels[i]
+	})
+}
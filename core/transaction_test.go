@@ -0,0 +1,124 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalTxKernelFee(t *testing.T) {
+	plainb := []byte(`{"features":{"Plain":{"fee":7000000}},"excess":"08b6","excess_sig":"66"}`)
+	var plain TxKernel
+	assert.NoError(t, json.Unmarshal(plainb, &plain))
+	assert.Equal(t, PlainKernel, plain.Features)
+	assert.Equal(t, Uint64(7000000), plain.Fee)
+	assert.Equal(t, "08b6", plain.Excess)
+
+	lockedb := []byte(`{"features":{"HeightLocked":{"fee":"8000000","lock_height":10}},"excess":"09b6","excess_sig":"66"}`)
+	var locked TxKernel
+	assert.NoError(t, json.Unmarshal(lockedb, &locked))
+	assert.Equal(t, HeightLockedKernel, locked.Features)
+	assert.Equal(t, Uint64(8000000), locked.Fee)
+
+	coinbaseb := []byte(`{"features":"Coinbase","excess":"09b6","excess_sig":"66"}`)
+	var coinbase TxKernel
+	assert.NoError(t, json.Unmarshal(coinbaseb, &coinbase))
+	assert.Equal(t, CoinbaseKernel, coinbase.Features)
+	assert.Equal(t, Uint64(0), coinbase.Fee)
+}
+
+func TestFeeFields(t *testing.T) {
+	feeFields, err := NewFeeFields(0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, FeeFields(10), feeFields)
+	assert.Equal(t, uint64(10), feeFields.Fee())
+	assert.Equal(t, uint8(0), feeFields.FeeShift())
+
+	feeFields, err = NewFeeFields(3, 7000000)
+	assert.NoError(t, err)
+	assert.Equal(t, FeeFields(3<<40|7000000), feeFields)
+	assert.Equal(t, uint64(7000000), feeFields.Fee())
+	assert.Equal(t, uint8(3), feeFields.FeeShift())
+
+	// Maximum values
+	feeFields, err = NewFeeFields(15, 1<<40-1)
+	assert.NoError(t, err)
+	assert.Equal(t, FeeFields(1<<44-1), feeFields)
+
+	_, err = NewFeeFields(0, 0)
+	assert.Equal(t, ErrInvalidFeeFields, err)
+	_, err = NewFeeFields(16, 1)
+	assert.Equal(t, ErrInvalidFeeFields, err)
+	_, err = NewFeeFields(0, 1<<40)
+	assert.Equal(t, ErrInvalidFeeFields, err)
+}
+
+func TestTransactionBodyFee(t *testing.T) {
+	shifted, err := NewFeeFields(2, 4*47*500000)
+	assert.NoError(t, err)
+	body := TransactionBody{
+		Inputs:  make([]Input, 2),
+		Outputs: make([]Output, 2),
+		Kernels: []TxKernel{{Fee: Uint64(shifted)}, {Fee: 1000}},
+	}
+	assert.Equal(t, uint64(2*1+2*21+2*3), body.Weight())
+	assert.Equal(t, uint64(4*47*500000+1000), body.Fee())
+	assert.Equal(t, uint8(2), body.FeeShift())
+	assert.Equal(t, uint64((4*47*500000+1000)>>2), body.ShiftedFee())
+	assert.Equal(t, uint64((4*47*500000+1000)>>2/50), body.FeeRate())
+
+	assert.Equal(t, uint64(0), (&TransactionBody{}).FeeRate())
+}
+
+func TestMarshalTxKernel(t *testing.T) {
+	kernels := []string{
+		`{"features":{"Plain":{"fee":7000000}},"excess":"08b6","excess_sig":"66"}`,
+		`{"features":"Coinbase","excess":"09b6","excess_sig":"66"}`,
+		`{"features":{"HeightLocked":{"fee":8000000,"lock_height":10}},"excess":"09b6","excess_sig":"66"}`,
+		`{"features":{"NoRecentDuplicate":{"fee":9000000,"relative_height":1440}},"excess":"0ab6","excess_sig":"66"}`,
+	}
+	for _, kernelb := range kernels {
+		var kernel TxKernel
+		assert.NoError(t, json.Unmarshal([]byte(kernelb), &kernel))
+		b, err := json.Marshal(kernel)
+		assert.NoError(t, err)
+		assert.Equal(t, kernelb, string(b))
+	}
+
+	nrd := TxKernel{Features: NoRecentDuplicateKernel, Fee: 9000000, RelativeHeight: 1440}
+	b, err := json.Marshal(&nrd)
+	assert.NoError(t, err)
+	var kernel TxKernel
+	assert.NoError(t, json.Unmarshal(b, &kernel))
+	assert.Equal(t, nrd, kernel)
+
+	_, err = json.Marshal(TxKernel{Features: KernelFeatures(4)})
+	assert.Error(t, err)
+
+	invalidb := []byte(`{"features":{"Plain":{"fee":1},"Coinbase":{}},"excess":"08b6","excess_sig":"66"}`)
+	assert.Error(t, json.Unmarshal(invalidb, &kernel))
+}
+
+func TestParseKernelFeatures(t *testing.T) {
+	features, err := ParseKernelFeatures("NoRecentDuplicate")
+	assert.NoError(t, err)
+	assert.Equal(t, NoRecentDuplicateKernel, features)
+
+	_, err = ParseKernelFeatures("Unknown")
+	assert.Error(t, err)
+}
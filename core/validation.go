@@ -0,0 +1,601 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/core/ser"
+	"github.com/blockcypher/libgrin/v5/util/secp"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"golang.org/x/crypto/blake2b"
+)
+
+var (
+	// ErrInvalidBlockHeight is returned when the header height is not the
+	// previous header height plus one
+	ErrInvalidBlockHeight = errors.New("invalid block height")
+	// ErrInvalidBlockVersion is returned when the header version is not valid
+	// for its height
+	ErrInvalidBlockVersion = errors.New("invalid block version")
+	// ErrInvalidBlockTime is returned when the header timestamp is not after
+	// the previous header one or too far in the future
+	ErrInvalidBlockTime = errors.New("invalid block time")
+	// ErrLowEdgeBits is returned when the proof edge bits are neither the
+	// secondary PoW ones nor above the minimum for the chain type
+	ErrLowEdgeBits = errors.New("invalid pow edge bits")
+	// ErrInvalidPoW is returned when the proof of work is not a valid cycle
+	ErrInvalidPoW = errors.New("invalid pow")
+	// ErrDifficultyTooLow is returned when the total difficulty does not
+	// increase or the proof of work does not reach the target difficulty
+	ErrDifficultyTooLow = errors.New("difficulty too low")
+	// ErrWrongTotalDifficulty is returned when the total difficulty does not
+	// increase by the network difficulty
+	ErrWrongTotalDifficulty = errors.New("wrong total difficulty")
+	// ErrInvalidScaling is returned when the secondary PoW scaling factor is
+	// not the expected one
+	ErrInvalidScaling = errors.New("invalid secondary pow scaling factor")
+)
+
+// Maximum time a header timestamp can be ahead of the local clock
+const maxFutureBlockTime = 12 * time.Duration(consensus.BlockTimeSec) * time.Second
+
+// ValidateHeader validates a block header against the previous header. The
+// difficulty iterator provides the header infos (with the block difficulty,
+// not the total difficulty) from the previous header backward and is used to
+// compute the expected network difficulty. The chain type must be registered.
+func ValidateHeader(chainType consensus.ChainType, header, prevHeader *BlockHeader, difficultyIter consensus.HeaderInfoIterator) error {
+	if !consensus.ChainTypeRegistered(chainType) {
+		return ErrUnknownChainType
+	}
+
+	// This header height must increase the height from the previous header by exactly 1.
+	if header.Height != prevHeader.Height+1 {
+		return ErrInvalidBlockHeight
+	}
+
+	// This header must have a valid header version for its height.
+	if !consensus.ValidHeaderVersion(chainType, header.Height, header.Version) {
+		return fmt.Errorf("%w: %d", ErrInvalidBlockVersion, header.Version)
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, header.Timestamp)
+	if err != nil {
+		return err
+	}
+	prevTimestamp, err := time.Parse(time.RFC3339, prevHeader.Timestamp)
+	if err != nil {
+		return err
+	}
+	if !timestamp.After(prevTimestamp) {
+		return ErrInvalidBlockTime
+	}
+	if timestamp.After(time.Now().Add(maxFutureBlockTime)) {
+		return ErrInvalidBlockTime
+	}
+
+	edgeBits := header.PoW.EdgeBits()
+	if edgeBits != consensus.SecondPoWEdgeBits && edgeBits < consensus.ChainTypeMinEdgeBits(chainType) {
+		return ErrLowEdgeBits
+	}
+	if err := header.VerifyPoW(chainType); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPoW, err)
+	}
+
+	// Check the pow hash shows a difficulty at least as large as the target
+	// difficulty
+	if header.PoW.TotalDifficulty <= prevHeader.PoW.TotalDifficulty {
+		return ErrDifficultyTooLow
+	}
+	targetDifficulty := header.PoW.TotalDifficulty - prevHeader.PoW.TotalDifficulty
+	if header.PoW.ToDifficulty(chainType, header.Height) < targetDifficulty {
+		return ErrDifficultyTooLow
+	}
+
+	// Explicit check to ensure total difficulty has increased by exactly the
+	// network difficulty of the previous block
+	nextHeaderInfo, err := consensus.NextDifficulty(chainType, header.Height, difficultyIter)
+	if err != nil {
+		return err
+	}
+	nextDifficulty := nextHeaderInfo.Difficulty()
+	if targetDifficulty != nextDifficulty.ToNum() {
+		return ErrWrongTotalDifficulty
+	}
+	// Check the secondary PoW scaling factor if applicable
+	if !consensus.ForkAt(chainType, header.Height).WTEMADifficulty && header.PoW.SecondaryScaling != nextHeaderInfo.SecondaryScaling() {
+		return ErrInvalidScaling
+	}
+	return nil
+}
+
+// ErrIncorrectSignature is returned when a kernel excess signature is invalid
+var ErrIncorrectSignature = errors.New("incorrect kernel signature")
+
+// Message is the message signed by the kernel excess: the blake2b hash of the
+// feature byte followed by the fee, lock height or relative height relevant
+// to the features
+func (k *TxKernel) Message() ([32]byte, error) {
+	var buf bytes.Buffer
+	if err := k.writeFeaturesV2(ser.NewWriter(&buf, ser.CurrentProtocolVersion, ser.HashMode)); err != nil {
+		return [32]byte{}, err
+	}
+	return blake2b.Sum256(buf.Bytes()), nil
+}
+
+// Parses the excess, signature and message of a kernel
+func (k *TxKernel) signatureData() (secp.Signature, [32]byte, *secp256k1.PublicKey, error) {
+	excess, err := secp.CommitmentFromHex(k.Excess)
+	if err != nil {
+		return secp.Signature{}, [32]byte{}, nil, err
+	}
+	pubKey, err := excess.ToPubKey()
+	if err != nil {
+		return secp.Signature{}, [32]byte{}, nil, err
+	}
+	sig, err := secp.SignatureFromCompactHex(k.ExcessSig)
+	if err != nil {
+		return secp.Signature{}, [32]byte{}, nil, err
+	}
+	msg, err := k.Message()
+	if err != nil {
+		return secp.Signature{}, [32]byte{}, nil, err
+	}
+	return sig, msg, pubKey, nil
+}
+
+// VerifySignature verifies the excess signature of the kernel: a Schnorr
+// signature of the kernel message by the excess commitment as a public key.
+// Kernels of the node API can be verified after api.TxKernelsPrintables
+// ToTxKernel and kernels of slates after slateversions.TxKernelV4 ToTxKernel.
+func (k *TxKernel) VerifySignature() error {
+	sig, msg, pubKey, err := k.signatureData()
+	if err != nil {
+		return err
+	}
+	if !secp.VerifySingle(sig, msg, pubKey) {
+		return ErrIncorrectSignature
+	}
+	return nil
+}
+
+// VerifyKernelSignatures batch verifies the excess signatures of kernels,
+// which is faster than verifying them one by one
+func VerifyKernelSignatures(kernels []TxKernel) error {
+	sigs := make([]secp.Signature, len(kernels))
+	msgs := make([][32]byte, len(kernels))
+	pubKeys := make([]*secp256k1.PublicKey, len(kernels))
+	for i := range kernels {
+		var err error
+		if sigs[i], msgs[i], pubKeys[i], err = kernels[i].signatureData(); err != nil {
+			return err
+		}
+	}
+	if !secp.VerifyBatch(sigs, msgs, pubKeys) {
+		return ErrIncorrectSignature
+	}
+	return nil
+}
+
+// ErrInvalidRangeProof is returned when an output range proof is invalid
+var ErrInvalidRangeProof = errors.New("invalid range proof")
+
+// Parses the commitment and range proof of an output
+func (o *Output) proofData() (secp.Commitment, []byte, error) {
+	commit, err := secp.CommitmentFromHex(o.Commit)
+	if err != nil {
+		return secp.Commitment{}, nil, err
+	}
+	proof, err := hex.DecodeString(o.Proof)
+	if err != nil {
+		return secp.Commitment{}, nil, err
+	}
+	return commit, proof, nil
+}
+
+// VerifyProof verifies the range proof of the output: a bulletproof that the
+// commitment is to a 64-bit value. Outputs of the node API can be verified
+// after api.OutputPrintable ToOutput and outputs of slates after
+// slateversions.CommitsV4 ToOutput.
+func (o *Output) VerifyProof() error {
+	commit, proof, err := o.proofData()
+	if err != nil {
+		return err
+	}
+	if !secp.VerifyBulletproof(commit, proof, nil) {
+		return ErrInvalidRangeProof
+	}
+	return nil
+}
+
+// VerifyOutputProofs batch verifies the range proofs of outputs, which is
+// faster than verifying them one by one
+func VerifyOutputProofs(outputs []Output) error {
+	commits := make([]secp.Commitment, len(outputs))
+	proofs := make([][]byte, len(outputs))
+	for i := range outputs {
+		var err error
+		if commits[i], proofs[i], err = outputs[i].proofData(); err != nil {
+			return err
+		}
+	}
+	if !secp.VerifyBulletproofs(commits, proofs, nil) {
+		return ErrInvalidRangeProof
+	}
+	return nil
+}
+
+var (
+	// ErrTooHeavy is returned when the weight of a transaction is above the
+	// maximum transaction weight
+	ErrTooHeavy = errors.New("transaction too heavy")
+	// ErrSortOrder is returned when inputs, outputs or kernels are not sorted
+	// by hash
+	ErrSortOrder = errors.New("inputs, outputs or kernels not sorted")
+	// ErrDuplicate is returned when inputs, outputs or kernels are duplicated
+	ErrDuplicate = errors.New("duplicate inputs, outputs or kernels")
+	// ErrCutThrough is returned when a commitment is both an input and an
+	// output, which should have been cut through
+	ErrCutThrough = errors.New("commitment both spent and created")
+	// ErrInvalidOutputFeatures is returned when a transaction has a coinbase
+	// output
+	ErrInvalidOutputFeatures = errors.New("invalid output features")
+	// ErrInvalidKernelFeatures is returned when a transaction has a coinbase
+	// kernel
+	ErrInvalidKernelFeatures = errors.New("invalid kernel features")
+	// ErrNRDKernelNotEnabled is returned for a no recent duplicate kernel on a
+	// chain type where they are not enabled
+	ErrNRDKernelNotEnabled = errors.New("no recent duplicate kernels not enabled")
+	// ErrInvalidNRDRelativeHeight is returned when the relative height of a no
+	// recent duplicate kernel is zero or above a week, or when two of them
+	// share an excess
+	ErrInvalidNRDRelativeHeight = errors.New("invalid no recent duplicate relative height")
+	// ErrKernelSumMismatch is returned when the outputs minus the inputs and
+	// the fees don't sum to the kernel excesses plus the offset
+	ErrKernelSumMismatch = errors.New("kernel sum mismatch")
+)
+
+// Verifies the body weight is at most the maximum weight
+func (b *TransactionBody) verifyWeight(maxWeight uint64) error {
+	if b.Weight() > maxWeight {
+		return ErrTooHeavy
+	}
+	return nil
+}
+
+// Verifies no recent duplicate kernels are enabled, have a valid relative
+// height and don't share an excess
+func (b *TransactionBody) verifyNRDKernels(chainType consensus.ChainType) error {
+	excesses := make(map[string]bool)
+	for _, kernel := range b.Kernels {
+		if kernel.Features != NoRecentDuplicateKernel {
+			continue
+		}
+		if !consensus.ChainTypeNRDEnabled(chainType) {
+			return ErrNRDKernelNotEnabled
+		}
+		if kernel.RelativeHeight == 0 || uint64(kernel.RelativeHeight) > consensus.WeekHeight {
+			return ErrInvalidNRDRelativeHeight
+		}
+		if excesses[kernel.Excess] {
+			return ErrInvalidNRDRelativeHeight
+		}
+		excesses[kernel.Excess] = true
+	}
+	return nil
+}
+
+// Verifies n elements are sorted by hash without duplicates
+func verifySortedByHash(n int, hash func(i int) ([32]byte, error)) error {
+	var prev [32]byte
+	for i := 0; i < n; i++ {
+		h, err := hash(i)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			switch c := bytes.Compare(prev[:], h[:]); {
+			case c > 0:
+				return ErrSortOrder
+			case c == 0:
+				return ErrDuplicate
+			}
+		}
+		prev = h
+	}
+	return nil
+}
+
+// Verifies inputs, outputs and kernels are sorted by hash without duplicates.
+// Inputs don't tell whether they were read with their features or as their
+// commitment only, so they may be sorted either way.
+func (b *TransactionBody) verifySorted() error {
+	err := verifySortedByHash(len(b.Inputs), func(i int) ([32]byte, error) {
+		return b.Inputs[i].hash()
+	})
+	if err != nil {
+		if verifySortedByHash(len(b.Inputs), func(i int) ([32]byte, error) {
+			return commitHash(b.Inputs[i].Commit)
+		}) != nil {
+			return err
+		}
+	}
+	if err := verifySortedByHash(len(b.Outputs), func(i int) ([32]byte, error) {
+		return b.Outputs[i].hash()
+	}); err != nil {
+		return err
+	}
+	return verifySortedByHash(len(b.Kernels), func(i int) ([32]byte, error) {
+		return b.Kernels[i].hash()
+	})
+}
+
+// Verifies no commitment appears twice among the inputs and outputs
+func (b *TransactionBody) verifyCutThrough() error {
+	commits := make(map[string]bool, len(b.Inputs)+len(b.Outputs))
+	add := func(commit string) error {
+		commit = strings.ToLower(commit)
+		if commits[commit] {
+			return ErrCutThrough
+		}
+		commits[commit] = true
+		return nil
+	}
+	for _, input := range b.Inputs {
+		if err := add(input.Commit); err != nil {
+			return err
+		}
+	}
+	for _, output := range b.Outputs {
+		if err := add(output.Commit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verifies a transaction has no coinbase output or kernel
+func (b *TransactionBody) verifyFeatures() error {
+	for _, output := range b.Outputs {
+		if output.Features == CoinbaseOutput {
+			return ErrInvalidOutputFeatures
+		}
+	}
+	for _, kernel := range b.Kernels {
+		if kernel.Features == CoinbaseKernel {
+			return ErrInvalidKernelFeatures
+		}
+	}
+	return nil
+}
+
+// Validates the body without looking at the kernel sums: its weight, the
+// sort order, cut-through, the range proofs and the kernel signatures
+func (b *TransactionBody) validate(chainType consensus.ChainType, maxWeight uint64) error {
+	if err := b.verifyWeight(maxWeight); err != nil {
+		return err
+	}
+	if err := b.verifyNRDKernels(chainType); err != nil {
+		return err
+	}
+	if err := b.verifySorted(); err != nil {
+		return err
+	}
+	if err := b.verifyCutThrough(); err != nil {
+		return err
+	}
+	if len(b.Outputs) > 0 {
+		if err := VerifyOutputProofs(b.Outputs); err != nil {
+			return err
+		}
+	}
+	return VerifyKernelSignatures(b.Kernels)
+}
+
+// Verifies the outputs minus the inputs plus the overage (the fees of a
+// transaction) sum to the kernel excesses plus the offset times G
+func (b *TransactionBody) verifyKernelSums(overage int64, offset secp.SecretKey) error {
+	positive := make([]secp.Commitment, 0, len(b.Outputs)+1)
+	negative := make([]secp.Commitment, 0, len(b.Inputs)+len(b.Kernels)+1)
+	add := func(commits []secp.Commitment, s string) ([]secp.Commitment, error) {
+		commit, err := secp.CommitmentFromHex(s)
+		if err != nil {
+			return nil, err
+		}
+		return append(commits, commit), nil
+	}
+	var err error
+	for _, output := range b.Outputs {
+		if positive, err = add(positive, output.Commit); err != nil {
+			return err
+		}
+	}
+	for _, input := range b.Inputs {
+		if negative, err = add(negative, input.Commit); err != nil {
+			return err
+		}
+	}
+	for _, kernel := range b.Kernels {
+		if negative, err = add(negative, kernel.Excess); err != nil {
+			return err
+		}
+	}
+	if overage != 0 {
+		value := uint64(overage)
+		if overage < 0 {
+			value = uint64(-overage)
+		}
+		overageCommit, err := secp.CommitValue(value)
+		if err != nil {
+			return err
+		}
+		if overage > 0 {
+			positive = append(positive, overageCommit)
+		} else {
+			negative = append(negative, overageCommit)
+		}
+	}
+	if !offset.IsZero() {
+		offsetCommit, err := secp.Commit(0, offset)
+		if err != nil {
+			return err
+		}
+		negative = append(negative, offsetCommit)
+	}
+	if !secp.VerifyCommitSum(positive, negative) {
+		return ErrKernelSumMismatch
+	}
+	return nil
+}
+
+// Validate runs the checks of a grin node before accepting a transaction in
+// its pool, so that a malformed transaction is caught before being sent: no
+// coinbase outputs or kernels, the weight within the maximum transaction
+// weight of the chain type, no recent duplicate kernels only if enabled,
+// inputs, outputs and kernels sorted without duplicates nor cut-through,
+// valid range proofs and kernel signatures, and the kernel sum: outputs
+// minus inputs plus fees times H equal to the kernel excesses plus the
+// offset times G.
+func (tx *Transaction) Validate(chainType consensus.ChainType) error {
+	offset, err := secp.SecretKeyFromHex(tx.Offset)
+	if err != nil {
+		return err
+	}
+	if err := tx.Body.verifyFeatures(); err != nil {
+		return err
+	}
+	if err := tx.Body.validate(chainType, consensus.ChainTypeMaxTxWeight(chainType)); err != nil {
+		return err
+	}
+	return tx.Body.verifyKernelSums(int64(tx.Body.Fee()), offset)
+}
+
+var (
+	// ErrInvalidCoinbaseKernels is returned when a block doesn't have exactly
+	// one coinbase kernel
+	ErrInvalidCoinbaseKernels = errors.New("block must have exactly one coinbase kernel")
+	// ErrCoinbaseSumMismatch is returned when the coinbase outputs minus the
+	// reward and fees don't sum to the coinbase kernel excess
+	ErrCoinbaseSumMismatch = errors.New("coinbase sum mismatch")
+	// ErrKernelLockHeight is returned when a block has a height locked kernel
+	// locked above its height
+	ErrKernelLockHeight = errors.New("kernel locked above block height")
+	// ErrNRDKernelPreHF3 is returned when a block before the third hard fork
+	// has a no recent duplicate kernel
+	ErrNRDKernelPreHF3 = errors.New("no recent duplicate kernel before hard fork 3")
+)
+
+// Verifies no height locked kernel is locked above the block height
+func (b *Block) verifyKernelLockHeights() error {
+	for _, kernel := range b.Body.Kernels {
+		if kernel.Features == HeightLockedKernel && uint64(kernel.LockHeight) > b.Header.Height {
+			return fmt.Errorf("%w: %d", ErrKernelLockHeight, kernel.LockHeight)
+		}
+	}
+	return nil
+}
+
+// Verifies no recent duplicate kernels only appear from the fork allowing
+// them, header version 4 for grin
+func (b *Block) verifyNRDKernelsForHeaderVersion(chainType consensus.ChainType) error {
+	for _, kernel := range b.Body.Kernels {
+		if kernel.Features == NoRecentDuplicateKernel && !consensus.ForkAt(chainType, b.Header.Height).NRDKernels {
+			return ErrNRDKernelPreHF3
+		}
+	}
+	return nil
+}
+
+// Verifies the block has exactly one coinbase kernel and that the coinbase
+// outputs sum to its excess plus the reward and fees
+func (b *Block) verifyCoinbase() error {
+	var outputs, kernels []secp.Commitment
+	for _, output := range b.Body.Outputs {
+		if output.Features != CoinbaseOutput {
+			continue
+		}
+		commit, err := secp.CommitmentFromHex(output.Commit)
+		if err != nil {
+			return err
+		}
+		outputs = append(outputs, commit)
+	}
+	for _, kernel := range b.Body.Kernels {
+		if kernel.Features != CoinbaseKernel {
+			continue
+		}
+		excess, err := secp.CommitmentFromHex(kernel.Excess)
+		if err != nil {
+			return err
+		}
+		kernels = append(kernels, excess)
+	}
+	if len(kernels) != 1 {
+		return ErrInvalidCoinbaseKernels
+	}
+	reward, err := secp.CommitValue(consensus.BlockReward(b.Body.Fee()))
+	if err != nil {
+		return err
+	}
+	if !secp.VerifyCommitSum(outputs, append(kernels, reward)) {
+		return ErrCoinbaseSumMismatch
+	}
+	return nil
+}
+
+// Validate validates the block as a grin node does, given the total kernel
+// offset of the previous header (zero for the genesis block): the body
+// within the max block weight, sorted without cut-through, with valid range
+// proofs and kernel signatures, no recent duplicate kernels only if enabled
+// and from header version 4, no kernel locked above the block height, one
+// coinbase kernel with the coinbase outputs of the reward plus fees, and the
+// kernel sum with the block kernel offset. The header should be validated
+// with ValidateHeader.
+func (b *Block) Validate(chainType consensus.ChainType, prevKernelOffset string) error {
+	if err := b.Body.validate(chainType, uint64(consensus.ChainTypeMaxBlockWeight(chainType))); err != nil {
+		return err
+	}
+	if err := b.verifyKernelLockHeights(); err != nil {
+		return err
+	}
+	if err := b.verifyNRDKernelsForHeaderVersion(chainType); err != nil {
+		return err
+	}
+	if err := b.verifyCoinbase(); err != nil {
+		return err
+	}
+
+	// The kernel offset of the block is the total kernel offset minus the
+	// previous one
+	totalOffset, err := secp.SecretKeyFromHex(b.Header.TotalKernelOffset)
+	if err != nil {
+		return err
+	}
+	prevOffset, err := secp.SecretKeyFromHex(prevKernelOffset)
+	if err != nil {
+		return err
+	}
+	offset, err := secp.BlindSum([]secp.SecretKey{totalOffset}, []secp.SecretKey{prevOffset})
+	if err != nil {
+		return err
+	}
+	return b.Body.verifyKernelSums(-int64(consensus.Reward), offset)
+}
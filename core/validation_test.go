@@ -0,0 +1,417 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"encoding/hex"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/core/pow"
+	"github.com/blockcypher/libgrin/v5/util/secp"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestValidateHeader(t *testing.T) {
+	prevHeader := BlockHeader{
+		Version:   1,
+		Height:    0,
+		Timestamp: "2020-09-13T12:26:40+00:00",
+		PoW: pow.ProofOfWork{
+			TotalDifficulty:  1,
+			SecondaryScaling: 1,
+			Proof:            pow.Proof{EdgeBits: 9, Nonces: []uint64{0, 0, 0, 0}},
+		},
+	}
+	// Next difficulty is 3 with a secondary scaling of 13, any proof reaches
+	// at least the graph weight difficulty. The proofs of this header and of
+	// the modified ones below were found with an AutomatedTesting cuckaroo
+	// solver.
+	validHeader := BlockHeader{
+		Version:           1,
+		Height:            1,
+		PrevHash:          zeroHashHex,
+		PrevRoot:          zeroHashHex,
+		Timestamp:         "2020-09-13T12:27:40+00:00",
+		OutputRoot:        zeroHashHex,
+		RangeProofRoot:    zeroHashHex,
+		KernelRoot:        zeroHashHex,
+		TotalKernelOffset: zeroHashHex,
+		PoW: pow.ProofOfWork{
+			TotalDifficulty:  4,
+			SecondaryScaling: 13,
+			Nonce:            5,
+			Proof:            pow.Proof{EdgeBits: 9, Nonces: []uint64{8, 15, 106, 212}},
+		},
+	}
+	difficultyIter := func() consensus.HeaderInfoIterator {
+		return consensus.NewHeaderInfoSliceIterator([]consensus.HeaderInfo{
+			consensus.NewHeaderInfo(1600000000, new(consensus.Difficulty).FromNum(1), 1, false),
+		})
+	}
+	assert.NoError(t, ValidateHeader(consensus.AutomatedTesting, &validHeader, &prevHeader, difficultyIter()))
+	err := ValidateHeader(consensus.ChainType(100), &validHeader, &prevHeader, difficultyIter())
+	assert.Equal(t, ErrUnknownChainType, err)
+
+	// The proof is not a valid cycle
+	header := validHeader
+	header.PoW.Proof = pow.Proof{EdgeBits: 9, Nonces: []uint64{1, 2, 3, 4}}
+	err = ValidateHeader(consensus.AutomatedTesting, &header, &prevHeader, difficultyIter())
+	assert.True(t, errors.Is(err, ErrInvalidPoW))
+
+	// The proof of work commits to the pre-PoW fields
+	header = validHeader
+	header.PoW.TotalDifficulty = 5
+	err = ValidateHeader(consensus.AutomatedTesting, &header, &prevHeader, difficultyIter())
+	assert.True(t, errors.Is(err, ErrInvalidPoW))
+
+	// Headers with a modified pre-PoW come with their own proof of work
+	withPoW := func(nonce uint64, nonces ...uint64) func(h *BlockHeader) {
+		return func(h *BlockHeader) {
+			h.PoW.Nonce = nonce
+			h.PoW.Proof = pow.Proof{EdgeBits: 9, Nonces: nonces}
+		}
+	}
+	tests := []struct {
+		name     string
+		modify   func(h *BlockHeader)
+		pow      func(h *BlockHeader)
+		expected error
+	}{
+		{"height", func(h *BlockHeader) { h.Height = 2 }, nil, ErrInvalidBlockHeight},
+		{"version", func(h *BlockHeader) { h.Version = 2 }, nil, ErrInvalidBlockVersion},
+		{"same timestamp", func(h *BlockHeader) { h.Timestamp = prevHeader.Timestamp }, nil, ErrInvalidBlockTime},
+		{"future timestamp", func(h *BlockHeader) {
+			h.Timestamp = time.Now().Add(time.Hour).UTC().Format(TimestampLayout)
+		}, nil, ErrInvalidBlockTime},
+		{"edge bits", func(h *BlockHeader) { h.PoW.Proof.EdgeBits = 8 }, nil, ErrLowEdgeBits},
+		{"total difficulty decrease", func(h *BlockHeader) { h.PoW.TotalDifficulty = 1 }, withPoW(2, 160, 165, 236, 305), ErrDifficultyTooLow},
+		{"target too high", func(h *BlockHeader) { h.PoW.TotalDifficulty = math.MaxUint64 }, withPoW(2, 272, 392, 417, 475), ErrDifficultyTooLow},
+		{"wrong total difficulty", func(h *BlockHeader) { h.PoW.TotalDifficulty = 5 }, withPoW(9, 185, 257, 348, 475), ErrWrongTotalDifficulty},
+		{"scaling", func(h *BlockHeader) { h.PoW.SecondaryScaling = 14 }, withPoW(0, 19, 30, 296, 457), ErrInvalidScaling},
+	}
+	for _, test := range tests {
+		header := validHeader
+		test.modify(&header)
+		if test.pow != nil {
+			test.pow(&header)
+		}
+		err := ValidateHeader(consensus.AutomatedTesting, &header, &prevHeader, difficultyIter())
+		assert.True(t, errors.Is(err, test.expected), "%s: %v", test.name, err)
+	}
+}
+
+// Signs a kernel with a secret key, the excess being its public key
+func signKernel(t *testing.T, kernel TxKernel, secKey secp.SecretKey) TxKernel {
+	pubKey, err := secKey.PubKey()
+	assert.NoError(t, err)
+	kernel.Excess = secp.CommitmentFromPubKey(pubKey).String()
+	msg, err := kernel.Message()
+	assert.NoError(t, err)
+	sig, err := secp.SignSingle(msg, secKey)
+	assert.NoError(t, err)
+	kernel.ExcessSig = sig.CompactHex()
+	return kernel
+}
+
+func TestKernelSignature(t *testing.T) {
+	genesisKernel := mainnetGenesisBody.Kernels[0]
+	assert.NoError(t, genesisKernel.VerifySignature())
+
+	// Any change to the signed message invalidates the signature
+	tampered := genesisKernel
+	tampered.Features = PlainKernel
+	assert.Equal(t, ErrIncorrectSignature, tampered.VerifySignature())
+	tampered = genesisKernel
+	tampered.ExcessSig = "242a6a482a0c64ba71ba216ba5361612696fc76fe8d5c03c79fae01cab29d050ed7567e9a6d4fbbc1b6c0bf4434b8450dafa3f29b31612fda815f6e4b2bcfd43"
+	assert.Equal(t, ErrIncorrectSignature, tampered.VerifySignature())
+	tampered.ExcessSig = "142a"
+	assert.Error(t, tampered.VerifySignature())
+
+	kernels := []TxKernel{genesisKernel}
+	for i, kernel := range []TxKernel{
+		{Features: PlainKernel, Fee: 7000000},
+		{Features: HeightLockedKernel, Fee: 8000000, LockHeight: 10},
+		{Features: NoRecentDuplicateKernel, Fee: 9000000, RelativeHeight: 1440},
+	} {
+		kernel = signKernel(t, kernel, secp.SecretKey{31: byte(i + 1)})
+		assert.NoError(t, kernel.VerifySignature())
+		kernels = append(kernels, kernel)
+	}
+	assert.NoError(t, VerifyKernelSignatures(kernels))
+	assert.NoError(t, VerifyKernelSignatures(nil))
+
+	// The lock height is part of the message
+	kernels[2].LockHeight++
+	assert.Equal(t, ErrIncorrectSignature, kernels[2].VerifySignature())
+	assert.Equal(t, ErrIncorrectSignature, VerifyKernelSignatures(kernels))
+}
+
+func TestOutputProof(t *testing.T) {
+	genesisOutput := mainnetGenesisBody.Outputs[0]
+	assert.NoError(t, genesisOutput.VerifyProof())
+	assert.NoError(t, VerifyOutputProofs([]Output{genesisOutput, genesisOutput}))
+	assert.NoError(t, VerifyOutputProofs(nil))
+
+	// The proof is bound to the commitment
+	tampered := genesisOutput
+	tampered.Commit = mainnetGenesisBody.Kernels[0].Excess
+	assert.Equal(t, ErrInvalidRangeProof, tampered.VerifyProof())
+	assert.Equal(t, ErrInvalidRangeProof, VerifyOutputProofs([]Output{genesisOutput, tampered}))
+
+	tampered = genesisOutput
+	tampered.Proof = "00" + genesisOutput.Proof[2:]
+	assert.Equal(t, ErrInvalidRangeProof, tampered.VerifyProof())
+	tampered.Proof = genesisOutput.Proof[:100]
+	assert.Equal(t, ErrInvalidRangeProof, tampered.VerifyProof())
+	tampered.Proof = "zz"
+	assert.Error(t, tampered.VerifyProof())
+}
+
+// Deterministic test blinding factor
+func testBlind(i byte) secp.SecretKey {
+	return secp.SecretKey(blake2b.Sum256([]byte{i}))
+}
+
+// Value and blinding factor of a test input or output
+type testAmount struct {
+	value uint64
+	blind secp.SecretKey
+}
+
+// Builds a valid sorted transaction spending the inputs to the outputs, the
+// difference being the fee. Returns the transaction and its excess secret
+// key, to sign modified kernels.
+func buildTestTransaction(t *testing.T, inputs, outputs []testAmount, offset secp.SecretKey) (Transaction, secp.SecretKey) {
+	var positive, negative []secp.SecretKey
+	var body TransactionBody
+	var fee uint64
+	for _, input := range inputs {
+		commit, err := secp.Commit(input.value, input.blind)
+		assert.NoError(t, err)
+		body.Inputs = append(body.Inputs, Input{Features: PlainOutput, Commit: commit.String()})
+		negative = append(negative, input.blind)
+		fee += input.value
+	}
+	for _, output := range outputs {
+		commit, err := secp.Commit(output.value, output.blind)
+		assert.NoError(t, err)
+		proof, err := secp.CreateBulletproof(output.value, output.blind, output.blind, output.blind, nil, nil)
+		assert.NoError(t, err)
+		body.Outputs = append(body.Outputs, Output{Features: PlainOutput, Commit: commit.String(), Proof: hex.EncodeToString(proof)})
+		positive = append(positive, output.blind)
+		fee -= output.value
+	}
+	excessKey, err := secp.BlindSum(positive, append(negative, offset))
+	assert.NoError(t, err)
+	body.Kernels = []TxKernel{signKernel(t, TxKernel{Features: PlainKernel, Fee: Uint64(fee)}, excessKey)}
+	assert.NoError(t, body.Sort())
+	return Transaction{Offset: offset.String(), Body: body}, excessKey
+}
+
+// Builds a valid sorted transaction spending inputs of the given values to
+// outputs of the given values, with test blinding factors
+func newTestTransaction(t *testing.T, inputValues, outputValues []uint64) (Transaction, secp.SecretKey) {
+	var inputs, outputs []testAmount
+	for _, value := range inputValues {
+		inputs = append(inputs, testAmount{value, testBlind(byte(len(inputs)))})
+	}
+	for _, value := range outputValues {
+		outputs = append(outputs, testAmount{value, testBlind(byte(len(inputs) + len(outputs)))})
+	}
+	return buildTestTransaction(t, inputs, outputs, testBlind(255))
+}
+
+// Copies a transaction so that its inputs, outputs and kernels can be changed
+func cloneTransaction(tx Transaction) Transaction {
+	tx.Body.Inputs = append([]Input(nil), tx.Body.Inputs...)
+	tx.Body.Outputs = append([]Output(nil), tx.Body.Outputs...)
+	tx.Body.Kernels = append([]TxKernel(nil), tx.Body.Kernels...)
+	return tx
+}
+
+func TestValidateTransaction(t *testing.T) {
+	tx, excessKey := newTestTransaction(t, []uint64{60000000000}, []uint64{40000000000, 19000000000})
+	assert.NoError(t, tx.Validate(consensus.Mainnet))
+	assert.NoError(t, tx.Validate(consensus.AutomatedTesting))
+
+	// Sort order and duplicates
+	tampered := cloneTransaction(tx)
+	tampered.Body.Outputs[0], tampered.Body.Outputs[1] = tampered.Body.Outputs[1], tampered.Body.Outputs[0]
+	assert.Equal(t, ErrSortOrder, tampered.Validate(consensus.Mainnet))
+	tampered = cloneTransaction(tx)
+	tampered.Body.Outputs[1] = tampered.Body.Outputs[0]
+	assert.Equal(t, ErrDuplicate, tampered.Validate(consensus.Mainnet))
+
+	// Inputs can also be sorted by commitment hash
+	tampered, _ = newTestTransaction(t, []uint64{10000000000, 20000000000, 30000000000}, []uint64{59000000000})
+	inputs := tampered.Body.Inputs
+	assert.NoError(t, sortByHash(len(inputs), func(i int) ([32]byte, error) {
+		return commitHash(inputs[i].Commit)
+	}, func(i, j int) {
+		inputs[i], inputs[j] = inputs[j], inputs[i]
+	}))
+	assert.NoError(t, tampered.Validate(consensus.Mainnet))
+	inputs[0], inputs[2] = inputs[2], inputs[0]
+	assert.Equal(t, ErrSortOrder, tampered.Validate(consensus.Mainnet))
+
+	// Spending an output of the transaction
+	tampered = cloneTransaction(tx)
+	tampered.Body.Inputs = append(tampered.Body.Inputs, Input{Features: PlainOutput, Commit: tx.Body.Outputs[0].Commit})
+	assert.NoError(t, tampered.Body.Sort())
+	assert.Equal(t, ErrCutThrough, tampered.Validate(consensus.Mainnet))
+
+	// Coinbase outputs and kernels
+	tampered = cloneTransaction(tx)
+	tampered.Body.Outputs[0].Features = CoinbaseOutput
+	assert.Equal(t, ErrInvalidOutputFeatures, tampered.Validate(consensus.Mainnet))
+	tampered = cloneTransaction(tx)
+	tampered.Body.Kernels[0] = signKernel(t, TxKernel{Features: CoinbaseKernel}, excessKey)
+	assert.Equal(t, ErrInvalidKernelFeatures, tampered.Validate(consensus.Mainnet))
+
+	// Range proofs and kernel signatures
+	tampered = cloneTransaction(tx)
+	tampered.Body.Outputs[0].Proof, tampered.Body.Outputs[1].Proof = tx.Body.Outputs[1].Proof, tx.Body.Outputs[0].Proof
+	assert.Equal(t, ErrInvalidRangeProof, tampered.Validate(consensus.Mainnet))
+	tampered = cloneTransaction(tx)
+	tampered.Body.Kernels[0].ExcessSig = mainnetGenesisBody.Kernels[0].ExcessSig
+	assert.Equal(t, ErrIncorrectSignature, tampered.Validate(consensus.Mainnet))
+
+	// Kernel sums
+	tampered = cloneTransaction(tx)
+	tampered.Body.Kernels[0] = signKernel(t, TxKernel{Features: PlainKernel, Fee: tx.Body.Kernels[0].Fee + 1}, excessKey)
+	assert.Equal(t, ErrKernelSumMismatch, tampered.Validate(consensus.Mainnet))
+	tampered = cloneTransaction(tx)
+	tampered.Offset = testBlind(254).String()
+	assert.Equal(t, ErrKernelSumMismatch, tampered.Validate(consensus.Mainnet))
+
+	// No recent duplicate kernels are only enabled outside mainnet
+	tampered = cloneTransaction(tx)
+	tampered.Body.Kernels[0] = signKernel(t, TxKernel{Features: NoRecentDuplicateKernel, Fee: tx.Body.Kernels[0].Fee, RelativeHeight: 10}, excessKey)
+	assert.Equal(t, ErrNRDKernelNotEnabled, tampered.Validate(consensus.Mainnet))
+	assert.NoError(t, tampered.Validate(consensus.AutomatedTesting))
+	tampered.Body.Kernels[0] = signKernel(t, TxKernel{Features: NoRecentDuplicateKernel, Fee: tx.Body.Kernels[0].Fee}, excessKey)
+	assert.Equal(t, ErrInvalidNRDRelativeHeight, tampered.Validate(consensus.AutomatedTesting))
+
+	// Weight above the testing maximum
+	heavy, _ := newTestTransaction(t, []uint64{60000000000}, []uint64{1, 2, 3, 4, 5, 6})
+	assert.NoError(t, heavy.Validate(consensus.Mainnet))
+	assert.Equal(t, ErrTooHeavy, heavy.Validate(consensus.AutomatedTesting))
+}
+
+// Builds a valid sorted block at height 5 with the transaction and a
+// coinbase of the given value, its total kernel offset adding the transaction
+// offset to the previous one. The other header fields are the genesis ones.
+func buildTestBlock(t *testing.T, tx Transaction, coinbaseValue uint64, prevKernelOffset secp.SecretKey) Block {
+	coinbaseBlind := testBlind(100)
+	commit, err := secp.Commit(coinbaseValue, coinbaseBlind)
+	assert.NoError(t, err)
+	proof, err := secp.CreateBulletproof(coinbaseValue, coinbaseBlind, coinbaseBlind, coinbaseBlind, nil, nil)
+	assert.NoError(t, err)
+	txOffset, err := secp.SecretKeyFromHex(tx.Offset)
+	assert.NoError(t, err)
+	totalOffset, err := secp.BlindSum([]secp.SecretKey{prevKernelOffset, txOffset}, nil)
+	assert.NoError(t, err)
+
+	block := Block{Header: mainnetGenesisHeader, Body: cloneTransaction(tx).Body}
+	block.Header.Version = 4
+	block.Header.Height = 5
+	block.Header.TotalKernelOffset = totalOffset.String()
+	block.Body.Outputs = append(block.Body.Outputs, Output{Features: CoinbaseOutput, Commit: commit.String(), Proof: hex.EncodeToString(proof)})
+	block.Body.Kernels = append(block.Body.Kernels, signKernel(t, TxKernel{Features: CoinbaseKernel}, coinbaseBlind))
+	assert.NoError(t, block.Body.Sort())
+	return block
+}
+
+// Copies a block so that its inputs, outputs and kernels can be changed
+func cloneBlock(block Block) Block {
+	block.Body = cloneTransaction(Transaction{Body: block.Body}).Body
+	return block
+}
+
+func TestValidateBlock(t *testing.T) {
+	tx, excessKey := newTestTransaction(t, []uint64{60000000000}, []uint64{40000000000, 19000000000})
+	prevOffset := testBlind(200)
+	block := buildTestBlock(t, tx, consensus.BlockReward(tx.Body.Fee()), prevOffset)
+	assert.NoError(t, block.Validate(consensus.Mainnet, prevOffset.String()))
+	assert.NoError(t, block.Validate(consensus.AutomatedTesting, prevOffset.String()))
+
+	// Kernel sum with the previous total kernel offset
+	assert.Equal(t, ErrKernelSumMismatch, block.Validate(consensus.Mainnet, testBlind(201).String()))
+	tampered := cloneBlock(block)
+	tampered.Header.TotalKernelOffset = prevOffset.String()
+	assert.Equal(t, ErrKernelSumMismatch, tampered.Validate(consensus.Mainnet, prevOffset.String()))
+
+	// The coinbase is the reward plus the fees
+	tampered = buildTestBlock(t, tx, consensus.Reward, prevOffset)
+	assert.Equal(t, ErrCoinbaseSumMismatch, tampered.Validate(consensus.Mainnet, prevOffset.String()))
+	tampered = buildTestBlock(t, tx, consensus.BlockReward(tx.Body.Fee())+1, prevOffset)
+	assert.Equal(t, ErrCoinbaseSumMismatch, tampered.Validate(consensus.Mainnet, prevOffset.String()))
+
+	// Exactly one coinbase kernel and coinbase output features
+	tampered = cloneBlock(block)
+	for i, kernel := range tampered.Body.Kernels {
+		if kernel.Features == CoinbaseKernel {
+			tampered.Body.Kernels = append(tampered.Body.Kernels[:i], tampered.Body.Kernels[i+1:]...)
+			break
+		}
+	}
+	assert.Equal(t, ErrInvalidCoinbaseKernels, tampered.Validate(consensus.Mainnet, prevOffset.String()))
+	tampered = cloneBlock(block)
+	tampered.Body.Kernels = append(tampered.Body.Kernels, signKernel(t, TxKernel{Features: CoinbaseKernel}, testBlind(101)))
+	assert.NoError(t, tampered.Body.Sort())
+	assert.Equal(t, ErrInvalidCoinbaseKernels, tampered.Validate(consensus.Mainnet, prevOffset.String()))
+	tampered = cloneBlock(block)
+	for i := range tampered.Body.Outputs {
+		tampered.Body.Outputs[i].Features = PlainOutput
+	}
+	assert.NoError(t, tampered.Body.Sort())
+	assert.Equal(t, ErrCoinbaseSumMismatch, tampered.Validate(consensus.Mainnet, prevOffset.String()))
+
+	// Spending an output of the block
+	tampered = cloneBlock(block)
+	tampered.Body.Inputs = append(tampered.Body.Inputs, Input{Features: CoinbaseOutput, Commit: block.Body.Outputs[0].Commit})
+	assert.NoError(t, tampered.Body.Sort())
+	assert.Equal(t, ErrCutThrough, tampered.Validate(consensus.Mainnet, prevOffset.String()))
+
+	// Kernels locked up to the block height
+	locked := cloneTransaction(tx)
+	locked.Body.Kernels[0] = signKernel(t, TxKernel{Features: HeightLockedKernel, Fee: tx.Body.Kernels[0].Fee, LockHeight: 5}, excessKey)
+	tampered = buildTestBlock(t, locked, consensus.BlockReward(tx.Body.Fee()), prevOffset)
+	assert.NoError(t, tampered.Validate(consensus.Mainnet, prevOffset.String()))
+	locked.Body.Kernels[0] = signKernel(t, TxKernel{Features: HeightLockedKernel, Fee: tx.Body.Kernels[0].Fee, LockHeight: 6}, excessKey)
+	tampered = buildTestBlock(t, locked, consensus.BlockReward(tx.Body.Fee()), prevOffset)
+	assert.True(t, errors.Is(tampered.Validate(consensus.Mainnet, prevOffset.String()), ErrKernelLockHeight))
+
+	// No recent duplicate kernels from the third hard fork outside mainnet
+	nrd := cloneTransaction(tx)
+	nrd.Body.Kernels[0] = signKernel(t, TxKernel{Features: NoRecentDuplicateKernel, Fee: tx.Body.Kernels[0].Fee, RelativeHeight: 10}, excessKey)
+	tampered = buildTestBlock(t, nrd, consensus.BlockReward(tx.Body.Fee()), prevOffset)
+	tampered.Header.Height = 3 * consensus.TestingHardForkInterval
+	assert.NoError(t, tampered.Validate(consensus.AutomatedTesting, prevOffset.String()))
+	assert.Equal(t, ErrNRDKernelNotEnabled, tampered.Validate(consensus.Mainnet, prevOffset.String()))
+	tampered.Header.Height--
+	assert.Equal(t, ErrNRDKernelPreHF3, tampered.Validate(consensus.AutomatedTesting, prevOffset.String()))
+
+	// Weight above the testing maximum block weight
+	heavy, _ := newTestTransaction(t, []uint64{60000000000}, []uint64{1, 2, 3, 4, 5, 6})
+	tampered = buildTestBlock(t, heavy, consensus.BlockReward(heavy.Body.Fee()), prevOffset)
+	assert.NoError(t, tampered.Validate(consensus.Mainnet, prevOffset.String()))
+	assert.Equal(t, ErrTooHeavy, tampered.Validate(consensus.AutomatedTesting, prevOffset.String()))
+}
@@ -47,6 +47,15 @@ func (i *Identifier) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// IdentifierFromSerializedPath builds an identifier from the depth and the
+// serialized derivation path that follows it
+func IdentifierFromSerializedPath(depth uint8, path []byte) Identifier {
+	var id Identifier
+	id[0] = depth
+	copy(id[1:], path)
+	return id
+}
+
 // MarshalJSON is a custom marshal function for Identifier
 func (i Identifier) MarshalJSON() ([]byte, error) {
 	buffer := bytes.NewBufferString(`"`)
@@ -55,3 +64,34 @@ func (i Identifier) MarshalJSON() ([]byte, error) {
 	buffer.WriteString(`"`)
 	return buffer.Bytes(), nil
 }
+
+// SwitchCommitmentType is whether the blinding factor of an output is
+// derived with a switch commitment
+type SwitchCommitmentType uint8
+
+const (
+	// SwitchCommitmentTypeNone is the raw derived key
+	SwitchCommitmentTypeNone SwitchCommitmentType = iota
+	// SwitchCommitmentTypeRegular is the derived key with the switch
+	// commitment hash added
+	SwitchCommitmentTypeRegular
+)
+
+func (s SwitchCommitmentType) String() string {
+	return toStringSwitchCommitmentType[s]
+}
+
+var toStringSwitchCommitmentType = map[SwitchCommitmentType]string{
+	SwitchCommitmentTypeNone:    "None",
+	SwitchCommitmentTypeRegular: "Regular",
+}
+
+// SwitchCommitmentTypeFromByte parses the switch commitment type from its
+// byte, as stored in the proof messages
+func SwitchCommitmentTypeFromByte(b uint8) (SwitchCommitmentType, error) {
+	s := SwitchCommitmentType(b)
+	if _, ok := toStringSwitchCommitmentType[s]; !ok {
+		return s, errors.New("invalid switch commitment type")
+	}
+	return s, nil
+}
@@ -36,3 +36,22 @@ func TestIdentifier(t *testing.T) {
 	assert.Equal(t, identifier, unmarshaledIdentifier)
 
 }
+
+func TestIdentifierFromSerializedPath(t *testing.T) {
+	path := []byte{0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0}
+	identifier := IdentifierFromSerializedPath(3, path)
+	assert.Equal(t, Identifier{3, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0}, identifier)
+}
+
+func TestSwitchCommitmentType(t *testing.T) {
+	switchType, err := SwitchCommitmentTypeFromByte(1)
+	assert.Nil(t, err)
+	assert.Equal(t, SwitchCommitmentTypeRegular, switchType)
+	assert.Equal(t, "Regular", switchType.String())
+	switchType, err = SwitchCommitmentTypeFromByte(0)
+	assert.Nil(t, err)
+	assert.Equal(t, SwitchCommitmentTypeNone, switchType)
+
+	_, err = SwitchCommitmentTypeFromByte(2)
+	assert.NotNil(t, err)
+}
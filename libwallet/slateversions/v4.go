@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
@@ -252,6 +253,15 @@ type CommitsV4 struct {
 	P *string `json:"p,omitempty"`
 }
 
+// ToOutput converts the v4 slate commitment to an output, only transaction
+// outputs have a range proof
+func (c *CommitsV4) ToOutput() (core.Output, error) {
+	if c.P == nil {
+		return core.Output{}, errors.New("missing range proof")
+	}
+	return core.Output{Features: core.OutputFeatures(c.F), Commit: c.C, Proof: *c.P}, nil
+}
+
 // OutputFeaturesV4 is a v4 output features
 type OutputFeaturesV4 uint8
 
@@ -310,3 +320,46 @@ type TxKernelV4 struct {
 	// the transaction fee.
 	ExcessSig string `json:"excess_sig"`
 }
+
+// NewTxKernelV4 converts a kernel to its v4 slate representation, the
+// relative height of no recent duplicate kernels is carried by the lock height
+func NewTxKernelV4(kernel core.TxKernel) TxKernelV4 {
+	txKernel := TxKernelV4{
+		Features:   kernel.Features,
+		Fee:        kernel.Fee,
+		LockHeight: kernel.LockHeight,
+		Excess:     kernel.Excess,
+		ExcessSig:  kernel.ExcessSig,
+	}
+	if kernel.Features == core.NoRecentDuplicateKernel {
+		txKernel.LockHeight = core.Uint64(kernel.RelativeHeight)
+	}
+	return txKernel
+}
+
+// ToTxKernel converts the v4 slate kernel to a kernel, only keeping the
+// fields relevant to its features
+func (k *TxKernelV4) ToTxKernel() (core.TxKernel, error) {
+	kernel := core.TxKernel{
+		Features:  k.Features,
+		Excess:    k.Excess,
+		ExcessSig: k.ExcessSig,
+	}
+	switch k.Features {
+	case core.PlainKernel:
+		kernel.Fee = k.Fee
+	case core.CoinbaseKernel:
+	case core.HeightLockedKernel:
+		kernel.Fee = k.Fee
+		kernel.LockHeight = k.LockHeight
+	case core.NoRecentDuplicateKernel:
+		if k.LockHeight > math.MaxUint16 {
+			return core.TxKernel{}, errors.New("invalid relative height")
+		}
+		kernel.Fee = k.Fee
+		kernel.RelativeHeight = uint16(k.LockHeight)
+	default:
+		return core.TxKernel{}, fmt.Errorf("unknown kernel features %d", k.Features)
+	}
+	return kernel, nil
+}
@@ -19,6 +19,7 @@ import (
 	"io/ioutil"
 	"testing"
 
+	"github.com/blockcypher/libgrin/v5/core"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -117,3 +118,47 @@ func TestMarshalSlateV4(t *testing.T) {
 	}
 	assert.Equal(t, slateV4JSON, serializedSlateV4)
 }
+
+func TestTxKernelV4(t *testing.T) {
+	kernels := []core.TxKernel{
+		{Features: core.PlainKernel, Fee: 7000000, Excess: "08b6", ExcessSig: "66"},
+		{Features: core.CoinbaseKernel, Excess: "09b6", ExcessSig: "66"},
+		{Features: core.HeightLockedKernel, Fee: 8000000, LockHeight: 10, Excess: "09b6", ExcessSig: "66"},
+		{Features: core.NoRecentDuplicateKernel, Fee: 9000000, RelativeHeight: 1440, Excess: "0ab6", ExcessSig: "66"},
+	}
+	for _, kernel := range kernels {
+		txKernel := NewTxKernelV4(kernel)
+		converted, err := txKernel.ToTxKernel()
+		assert.NoError(t, err)
+		assert.Equal(t, kernel, converted)
+	}
+	nrd := NewTxKernelV4(kernels[3])
+	assert.Equal(t, core.Uint64(1440), nrd.LockHeight)
+
+	nrd.LockHeight = 1 << 16
+	_, err := nrd.ToTxKernel()
+	assert.Error(t, err)
+
+	// Slate kernels carry the compact signature and verify once converted
+	genesis := NewTxKernelV4(core.TxKernel{
+		Features:  core.CoinbaseKernel,
+		Excess:    "096385d86c5cfda718aa0b7295be0adf7e5ac051edfe130593a2a257f09f78a3b1",
+		ExcessSig: "142a6a482a0c64ba71ba216ba5361612696fc76fe8d5c03c79fae01cab29d050ed7567e9a6d4fbbc1b6c0bf4434b8450dafa3f29b31612fda815f6e4b2bcfd43",
+	})
+	kernel, err := genesis.ToTxKernel()
+	assert.NoError(t, err)
+	assert.NoError(t, kernel.VerifySignature())
+}
+
+func TestCommitsV4ToOutput(t *testing.T) {
+	proof := "9330"
+	commit := CommitsV4{F: 1, C: "08b7", P: &proof}
+	output, err := commit.ToOutput()
+	assert.NoError(t, err)
+	assert.Equal(t, core.Output{Features: core.CoinbaseOutput, Commit: "08b7", Proof: "9330"}, output)
+
+	// Inputs have no range proof
+	commit.P = nil
+	_, err = commit.ToOutput()
+	assert.Error(t, err)
+}
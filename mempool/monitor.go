@@ -0,0 +1,128 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mempool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/pool"
+	log "github.com/sirupsen/logrus"
+)
+
+// Node is the node API used to poll the pool. It is implemented by
+// client.NodeForeignAPI.
+type Node interface {
+	GetUnconfirmedTransactions() (*[]pool.PoolEntry, error)
+}
+
+// MonitorConfig is the configuration of a Monitor
+type MonitorConfig struct {
+	// Interval between two polls of the pool
+	PollInterval time.Duration
+	// Maximum block weight the pool is compared to
+	MaxBlockWeight uint64
+	// Minimum fee per unit of weight accepted by the pool
+	AcceptFeeBase uint64
+	// Fee rate histogram bucket bounds, DefaultFeeRateBounds if nil
+	FeeRateBounds []uint64
+	// Age histogram bucket bounds in seconds, DefaultAgeBounds if nil
+	AgeBounds []uint64
+}
+
+// DefaultMonitorConfig is the default mainnet monitor configuration
+var DefaultMonitorConfig = MonitorConfig{
+	PollInterval:   30 * time.Second,
+	MaxBlockWeight: uint64(consensus.MaxBlockWeight),
+	AcceptFeeBase:  DefaultAcceptFeeBase,
+}
+
+// Monitor periodically polls the node pool and keeps the latest snapshot
+type Monitor struct {
+	node   Node
+	config MonitorConfig
+	now    func() time.Time
+
+	mu     sync.RWMutex
+	latest *Snapshot
+}
+
+// NewMonitor creates a new pool monitor
+func NewMonitor(node Node, config MonitorConfig) *Monitor {
+	if config.FeeRateBounds == nil {
+		config.FeeRateBounds = DefaultFeeRateBounds(config.AcceptFeeBase)
+	}
+	if config.AgeBounds == nil {
+		config.AgeBounds = DefaultAgeBounds
+	}
+	return &Monitor{node: node, config: config, now: time.Now}
+}
+
+// Poll retrieves the pool from the node and computes a new snapshot
+func (m *Monitor) Poll() (*Snapshot, error) {
+	entries, err := m.node.GetUnconfirmedTransactions()
+	if err != nil {
+		return nil, err
+	}
+	var poolEntries []pool.PoolEntry
+	if entries != nil {
+		poolEntries = *entries
+	}
+	snapshot, err := Analyze(poolEntries, m.now(), m.config.MaxBlockWeight, m.config.FeeRateBounds, m.config.AgeBounds)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latest = &snapshot
+	return &snapshot, nil
+}
+
+// Latest returns the latest snapshot, nil if the pool was never polled
+func (m *Monitor) Latest() *Snapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.latest
+}
+
+// SuggestFeeRate suggests a fee per unit of weight for inclusion in the next
+// block based on the latest snapshot
+func (m *Monitor) SuggestFeeRate() uint64 {
+	latest := m.Latest()
+	if latest == nil {
+		return m.config.AcceptFeeBase
+	}
+	return latest.SuggestFeeRate(m.config.AcceptFeeBase)
+}
+
+// Run polls the pool every PollInterval until the context is done
+func (m *Monitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := m.Poll(); err != nil {
+				log.WithFields(log.Fields{
+					"error": err,
+				}).Error("Monitor: Error during Poll")
+			}
+		}
+	}
+}
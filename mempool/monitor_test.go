@@ -0,0 +1,47 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mempool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blockcypher/libgrin/v5/pool"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeNode struct {
+	entries []pool.PoolEntry
+}
+
+func (n *fakeNode) GetUnconfirmedTransactions() (*[]pool.PoolEntry, error) {
+	return &n.entries, nil
+}
+
+func TestMonitor(t *testing.T) {
+	node := &fakeNode{}
+	monitor := NewMonitor(node, DefaultMonitorConfig)
+	monitor.now = func() time.Time { return time.Date(2020, 7, 2, 15, 0, 0, 0, time.UTC) }
+	assert.Nil(t, monitor.Latest())
+	assert.Equal(t, DefaultAcceptFeeBase, monitor.SuggestFeeRate())
+
+	node.entries = []pool.PoolEntry{testEntry("2020-07-02T14:30:00Z", 2, 2, 47*DefaultAcceptFeeBase)}
+	snapshot, err := monitor.Poll()
+	assert.NoError(t, err)
+	assert.Len(t, snapshot.Txs, 1)
+	assert.Equal(t, snapshot, monitor.Latest())
+	assert.Len(t, snapshot.FeeRateHistogram, 17)
+	assert.Equal(t, DefaultAcceptFeeBase, monitor.SuggestFeeRate())
+}
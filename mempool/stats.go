@@ -0,0 +1,205 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mempool
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/pool"
+)
+
+// DefaultAcceptFeeBase is the default minimum fee per unit of weight accepted
+// by the grin pool (GrinBase / 100 / 20 = 500_000)
+const DefaultAcceptFeeBase uint64 = consensus.DefaultAcceptFeeBase
+
+// TxStats are the statistics of a single pool entry
+type TxStats struct {
+	// Kernel excess of the first kernel, identifying the transaction
+	KernelExcess string
+	// Where the transaction originated from
+	Src pool.TxSource
+	// Time the transaction was added to the pool
+	TxAt time.Time
+	// Time spent in the pool
+	Age time.Duration
+	// Weight of the transaction
+	Weight uint64
+	// Total fee of the transaction
+	Fee uint64
+	// Fee per unit of weight, shifted right by the fee shift
+	FeeRate uint64
+}
+
+// NewTxStats computes the statistics of a pool entry at a given time
+func NewTxStats(entry pool.PoolEntry, now time.Time) (TxStats, error) {
+	txAt, err := time.Parse(time.RFC3339Nano, entry.TxAt)
+	if err != nil {
+		return TxStats{}, err
+	}
+	stats := TxStats{
+		Src:     entry.Src,
+		TxAt:    txAt,
+		Age:     now.Sub(txAt),
+		Weight:  entry.Tx.Body.Weight(),
+		Fee:     entry.Tx.Body.Fee(),
+		FeeRate: entry.Tx.Body.FeeRate(),
+	}
+	if len(entry.Tx.Body.Kernels) > 0 {
+		stats.KernelExcess = entry.Tx.Body.Kernels[0].Excess
+	}
+	return stats, nil
+}
+
+// Bucket is an histogram bucket covering the values in [Min, Max)
+type Bucket struct {
+	// Inclusive lower bound
+	Min uint64
+	// Exclusive upper bound
+	Max uint64
+	// Number of transactions in the bucket
+	Count int
+	// Total weight of the transactions in the bucket
+	Weight uint64
+}
+
+// Snapshot are the statistics of the whole pool at a given time
+type Snapshot struct {
+	// Time of the snapshot
+	At time.Time
+	// Per transaction statistics, ordered by decreasing fee rate
+	Txs []TxStats
+	// Total weight of the pool
+	TotalWeight uint64
+	// Total fees of the pool
+	TotalFees uint64
+	// Maximum block weight the pool weight is compared to
+	MaxBlockWeight uint64
+	// Fee rate histogram, in nanogrin per unit of weight
+	FeeRateHistogram []Bucket
+	// Age histogram, in seconds
+	AgeHistogram []Bucket
+}
+
+// BlockFullness is the total pool weight relative to the maximum block weight,
+// a value above 1 means the pool does not fit in the next block
+func (s *Snapshot) BlockFullness() float64 {
+	if s.MaxBlockWeight == 0 {
+		return 0
+	}
+	return float64(s.TotalWeight) / float64(s.MaxBlockWeight)
+}
+
+// SuggestFeeRate suggests a fee per unit of weight for inclusion in the next
+// block. If the pool fits in a block the minimum accepted fee rate is enough,
+// otherwise the transaction has to outbid the last one that would be included.
+func (s *Snapshot) SuggestFeeRate(acceptFeeBase uint64) uint64 {
+	available := saturatingSub(s.MaxBlockWeight, uint64(consensus.CoinbaseWeight))
+	var used uint64
+	for _, tx := range s.Txs {
+		if used+tx.Weight > available {
+			// Everything at or below this fee rate may be left out
+			if tx.FeeRate+1 > acceptFeeBase {
+				return tx.FeeRate + 1
+			}
+			return acceptFeeBase
+		}
+		used += tx.Weight
+	}
+	return acceptFeeBase
+}
+
+// SuggestFee suggests a fee for a transaction with the given number of inputs,
+// outputs and kernels to be included in the next block
+func (s *Snapshot) SuggestFee(acceptFeeBase uint64, numInputs, numOutputs, numKernels uint64) uint64 {
+	return consensus.MinRelayFee(s.SuggestFeeRate(acceptFeeBase), numInputs, numOutputs, numKernels)
+}
+
+// DefaultFeeRateBounds are fee rate bucket bounds: below the accept fee base
+// and then powers of two multiples of it, matching the possible fee shifts
+func DefaultFeeRateBounds(acceptFeeBase uint64) []uint64 {
+	bounds := []uint64{0}
+	for shift := uint(0); shift <= 15; shift++ {
+		bounds = append(bounds, acceptFeeBase<<shift)
+	}
+	return bounds
+}
+
+// DefaultAgeBounds are the age bucket bounds, in seconds
+var DefaultAgeBounds = []uint64{0, 60, 5 * 60, 15 * 60, 60 * 60, 6 * 60 * 60, 24 * 60 * 60}
+
+// Analyze computes the statistics of the pool entries at a given time.
+// Entries with an invalid timestamp are reported as an error.
+func Analyze(entries []pool.PoolEntry, now time.Time, maxBlockWeight uint64, feeRateBounds, ageBounds []uint64) (Snapshot, error) {
+	snapshot := Snapshot{
+		At:               now,
+		Txs:              make([]TxStats, 0, len(entries)),
+		MaxBlockWeight:   maxBlockWeight,
+		FeeRateHistogram: newHistogram(feeRateBounds),
+		AgeHistogram:     newHistogram(ageBounds),
+	}
+	for _, entry := range entries {
+		stats, err := NewTxStats(entry, now)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		snapshot.Txs = append(snapshot.Txs, stats)
+		snapshot.TotalWeight += stats.Weight
+		snapshot.TotalFees += stats.Fee
+		addToHistogram(snapshot.FeeRateHistogram, stats.FeeRate, stats.Weight)
+		var age uint64
+		if stats.Age > 0 {
+			age = uint64(stats.Age / time.Second)
+		}
+		addToHistogram(snapshot.AgeHistogram, age, stats.Weight)
+	}
+	sort.SliceStable(snapshot.Txs, func(i, j int) bool {
+		return snapshot.Txs[i].FeeRate > snapshot.Txs[j].FeeRate
+	})
+	return snapshot, nil
+}
+
+// Buckets are [bounds[i], bounds[i+1]) with the last one open ended
+func newHistogram(bounds []uint64) []Bucket {
+	buckets := make([]Bucket, len(bounds))
+	for i, bound := range bounds {
+		buckets[i].Min = bound
+		if i+1 < len(bounds) {
+			buckets[i].Max = bounds[i+1]
+		} else {
+			buckets[i].Max = math.MaxUint64
+		}
+	}
+	return buckets
+}
+
+func addToHistogram(buckets []Bucket, value, weight uint64) {
+	for i := range buckets {
+		if value >= buckets[i].Min && value < buckets[i].Max {
+			buckets[i].Count++
+			buckets[i].Weight += weight
+			return
+		}
+	}
+}
+
+func saturatingSub(a, b uint64) uint64 {
+	if a < b {
+		return 0
+	}
+	return a - b
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mempool
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/blockcypher/libgrin/v5/core"
+	"github.com/blockcypher/libgrin/v5/pool"
+	"github.com/stretchr/testify/assert"
+)
+
+// A pool entry as returned by get_unconfirmed_transactions
+var poolEntryJSON = []byte(`{
+	"src": "Broadcast",
+	"tx_at": "2020-07-02T14:23:13.123456Z",
+	"tx": {
+		"offset": "d202964900000000d302964900000000d402964900000000d502964900000000",
+		"body": {
+			"inputs": [
+				{"features": "Plain", "commit": "087df32304c5d4ae8b2af0bc31e700019d722910ef87dd4eec3197b80b207e3045"},
+				{"features": "Plain", "commit": "08e1da9e6dc4d6e808a718b2f110a991dd775d65ce5ae408a4e1f002a4961aa9e7"}
+			],
+			"outputs": [
+				{"features": "Plain", "commit": "0812276cc788e6870612296d926cba9f0e7b9810670710b5a6e6f1ba006d395774", "proof": "dcff"},
+				{"features": "Plain", "commit": "08cb1b2ee8a6b4fb66e09c5d6daaa5e16e2ec48a3c4f1ed2c01ab62c68d9ad5e7c", "proof": "dcff"}
+			],
+			"kernels": [
+				{"features": {"Plain": {"fee": 23500000}}, "excess": "08b6", "excess_sig": "66"}
+			]
+		}
+	}
+}`)
+
+func testEntry(txAt string, inputs, outputs int, fee uint64) pool.PoolEntry {
+	tx := core.Transaction{}
+	tx.Body.Inputs = make([]core.Input, inputs)
+	tx.Body.Outputs = make([]core.Output, outputs)
+	tx.Body.Kernels = []core.TxKernel{{Fee: core.Uint64(fee)}}
+	return pool.PoolEntry{TxAt: txAt, Tx: tx}
+}
+
+func TestTxStats(t *testing.T) {
+	var entry pool.PoolEntry
+	assert.NoError(t, json.Unmarshal(poolEntryJSON, &entry))
+	now := time.Date(2020, 7, 2, 14, 24, 13, 123456000, time.UTC)
+	stats, err := NewTxStats(entry, now)
+	assert.NoError(t, err)
+	// 2 * 1 + 2 * 21 + 1 * 3
+	assert.Equal(t, uint64(47), stats.Weight)
+	assert.Equal(t, uint64(23500000), stats.Fee)
+	assert.Equal(t, uint64(500000), stats.FeeRate)
+	assert.Equal(t, time.Minute, stats.Age)
+	assert.Equal(t, "08b6", stats.KernelExcess)
+	assert.Equal(t, pool.BroadcastTxSource, stats.Src)
+
+	_, err = NewTxStats(pool.PoolEntry{TxAt: "yesterday"}, now)
+	assert.Error(t, err)
+}
+
+func TestAnalyze(t *testing.T) {
+	now := time.Date(2020, 7, 2, 15, 0, 0, 0, time.UTC)
+	entries := []pool.PoolEntry{
+		// weight 47, rate 1x, 30 minutes old
+		testEntry("2020-07-02T14:30:00Z", 2, 2, 47*DefaultAcceptFeeBase),
+		// weight 24, rate 4x, 30 seconds old
+		testEntry("2020-07-02T14:59:30Z", 0, 1, 24*4*DefaultAcceptFeeBase),
+		// weight 46, rate 2x, 2 hours old
+		testEntry("2020-07-02T13:00:00Z", 1, 2, 46*2*DefaultAcceptFeeBase),
+	}
+	snapshot, err := Analyze(entries, now, 100, DefaultFeeRateBounds(DefaultAcceptFeeBase), DefaultAgeBounds)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(117), snapshot.TotalWeight)
+	assert.InDelta(t, 1.17, snapshot.BlockFullness(), 0.0001)
+
+	// Ordered by fee rate
+	assert.Equal(t, 4*DefaultAcceptFeeBase, snapshot.Txs[0].FeeRate)
+	assert.Equal(t, 2*DefaultAcceptFeeBase, snapshot.Txs[1].FeeRate)
+	assert.Equal(t, DefaultAcceptFeeBase, snapshot.Txs[2].FeeRate)
+
+	// Nothing below the accept fee base, then one tx in each of 1x, 2x and 4x
+	assert.Equal(t, 0, snapshot.FeeRateHistogram[0].Count)
+	assert.Equal(t, 1, snapshot.FeeRateHistogram[1].Count)
+	assert.Equal(t, 1, snapshot.FeeRateHistogram[2].Count)
+	assert.Equal(t, 1, snapshot.FeeRateHistogram[3].Count)
+	assert.Equal(t, uint64(24), snapshot.FeeRateHistogram[3].Weight)
+
+	// Age buckets: <1m, <5m, <15m, <1h, <6h
+	assert.Equal(t, 1, snapshot.AgeHistogram[0].Count)
+	assert.Equal(t, 1, snapshot.AgeHistogram[3].Count)
+	assert.Equal(t, 1, snapshot.AgeHistogram[4].Count)
+
+	// 100 - 24 of coinbase leaves room for 76: the 4x tx (24) and the 2x tx (46)
+	// fit, the 1x tx does not so it has to be outbid
+	assert.Equal(t, DefaultAcceptFeeBase+1, snapshot.SuggestFeeRate(DefaultAcceptFeeBase))
+	assert.Equal(t, 47*(DefaultAcceptFeeBase+1), snapshot.SuggestFee(DefaultAcceptFeeBase, 2, 2, 1))
+
+	// Everything fits, the minimum is enough
+	snapshot.MaxBlockWeight = 40000
+	assert.Equal(t, DefaultAcceptFeeBase, snapshot.SuggestFeeRate(DefaultAcceptFeeBase))
+}
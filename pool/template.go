@@ -0,0 +1,256 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/blockcypher/libgrin/v5/core"
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/keychain"
+	"github.com/blockcypher/libgrin/v5/libwallet"
+)
+
+// CoinbaseBuilder builds the coinbase output and kernel of a block candidate.
+// It is implemented by client.WalletForeignAPI.
+type CoinbaseBuilder interface {
+	BuildCoinbase(blockFees libwallet.BlockFees) (*libwallet.CbData, error)
+}
+
+// BlockTemplate is the body of a block candidate: the selected pool
+// transactions aggregated together with the coinbase
+type BlockTemplate struct {
+	// Height of the block candidate
+	Height uint64
+	// Pool transactions included in the block, in selection order
+	Txs []core.Transaction
+	// Aggregated body of the transactions and the coinbase, after cut-through
+	// and sorted
+	Body core.TransactionBody
+	// Sum of the transaction offsets, the total kernel offset of the block is
+	// the previous total kernel offset plus this one
+	Offset string
+	// Total fees of the included transactions
+	Fees uint64
+	// Coinbase reward: the block subsidy plus the fees
+	Reward uint64
+	// Weight of the body, coinbase included
+	Weight uint64
+	// Key id of the coinbase output returned by the wallet
+	KeyID *keychain.Identifier
+}
+
+// BuildBlockTemplate selects the pool entries to include in a block at the
+// given height and aggregates them together with a coinbase built by the
+// wallet. Transactions are bucketed with their pool parents, ordered by fee
+// rate and packed greedily so the block weight does not exceed maxBlockWeight
+// (e.g. consensus.ChainTypeMaxBlockWeight(chainType)).
+//
+// The entries are expected to be in pool insertion order, as returned by the
+// node, and are not validated against the chain state.
+func BuildBlockTemplate(entries []PoolEntry, builder CoinbaseBuilder, height uint64, keyID *keychain.Identifier, maxBlockWeight uint64) (*BlockTemplate, error) {
+	coinbaseWeight := uint64(consensus.CoinbaseWeight)
+	if maxBlockWeight < coinbaseWeight {
+		return nil, errors.New("max block weight too small for the coinbase")
+	}
+	txs := PrepareMineableTransactions(entries, maxBlockWeight-coinbaseWeight)
+
+	agg, err := core.Aggregate(txs)
+	if err != nil {
+		return nil, err
+	}
+	template := BlockTemplate{
+		Height: height,
+		Txs:    txs,
+		Body:   agg.Body,
+		Offset: agg.Offset,
+		Fees:   agg.Body.Fee(),
+	}
+
+	blockFees := libwallet.BlockFees{
+		Fees:   core.Uint64(template.Fees),
+		Height: core.Uint64(height),
+		KeyID:  keyID,
+	}
+	cbData, err := builder.BuildCoinbase(blockFees)
+	if err != nil {
+		return nil, err
+	}
+	if cbData == nil {
+		return nil, errors.New("empty coinbase")
+	}
+	template.Body.Outputs = append(template.Body.Outputs, cbData.Output)
+	template.Body.Kernels = append(template.Body.Kernels, cbData.Kernel)
+	if err := template.Body.Sort(); err != nil {
+		return nil, err
+	}
+	template.KeyID = cbData.KeyID
+	template.Reward = consensus.BlockReward(template.Fees)
+	template.Weight = template.Body.Weight()
+	return &template, nil
+}
+
+// A bucket is a set of dependent transactions aggregated together
+type bucket struct {
+	txs     []core.Transaction
+	agg     core.Transaction
+	feeRate uint64
+	ageIdx  int
+}
+
+func newBucket(tx core.Transaction, ageIdx int) bucket {
+	return bucket{
+		txs:     []core.Transaction{tx},
+		agg:     tx,
+		feeRate: tx.Body.FeeRate(),
+		ageIdx:  ageIdx,
+	}
+}
+
+func (b *bucket) aggregateWithTx(tx core.Transaction, maxWeight uint64) (bucket, error) {
+	txs := append(append([]core.Transaction{}, b.txs...), tx)
+	agg, err := core.Aggregate(txs)
+	if err != nil {
+		return bucket{}, err
+	}
+	if agg.Body.Weight() > maxWeight {
+		return bucket{}, errors.New("bucket too heavy")
+	}
+	return bucket{txs: txs, agg: agg, feeRate: agg.Body.FeeRate(), ageIdx: b.ageIdx}, nil
+}
+
+// PrepareMineableTransactions selects the pool entries fitting in maxWeight,
+// which excludes the coinbase. Transactions spending the output of another
+// pool transaction are bucketed with their parent so dependency order is kept
+// and cut-through is maximized. Buckets are ordered by decreasing fee rate then
+// by age and packed greedily.
+func PrepareMineableTransactions(entries []PoolEntry, maxWeight uint64) []core.Transaction {
+	var buckets []bucket
+	outputCommits := make(map[string]int)
+	rejected := make(map[string]bool)
+
+	for _, entry := range entries {
+		tx := entry.Tx
+		insertPos := -1
+		isRejected := false
+		for _, input := range tx.Body.Inputs {
+			if rejected[input.Commit] {
+				// Depends on a rejected tx, so reject this one
+				isRejected = true
+			} else if pos, ok := outputCommits[input.Commit]; ok {
+				if insertPos >= 0 && insertPos != pos {
+					// Multiple dependencies, pick it up in the next block
+					isRejected = true
+				} else {
+					insertPos = pos
+				}
+			}
+		}
+
+		if !isRejected {
+			if insertPos < 0 {
+				// No parent tx, the common case
+				insertPos = len(buckets)
+				buckets = append(buckets, newBucket(tx, len(buckets)))
+			} else if newB, err := buckets[insertPos].aggregateWithTx(tx, maxWeight); err == nil {
+				// Only aggregate if it would not reduce the fee rate, otherwise
+				// put it in its own bucket with a lower fee rate than its parent
+				if newB.feeRate >= buckets[insertPos].feeRate {
+					buckets[insertPos] = newB
+				} else {
+					insertPos = len(buckets)
+					buckets = append(buckets, newBucket(tx, len(buckets)))
+				}
+			} else {
+				isRejected = true
+			}
+		}
+
+		for _, output := range tx.Body.Outputs {
+			if isRejected {
+				rejected[output.Commit] = true
+			} else {
+				outputCommits[output.Commit] = insertPos
+			}
+		}
+	}
+
+	sort.SliceStable(buckets, func(i, j int) bool {
+		if buckets[i].feeRate != buckets[j].feeRate {
+			return buckets[i].feeRate > buckets[j].feeRate
+		}
+		return buckets[i].ageIdx < buckets[j].ageIdx
+	})
+
+	// Pack the transactions as long as the aggregate stays under the weight
+	// limit, skipping the ones depending on a transaction left out. The
+	// aggregate weight is tracked as transactions are added: an input spending
+	// an output of the selection cuts through with it.
+	var selected []core.Transaction
+	var numInputs, numOutputs, numKernels uint64
+	created := make(map[string]bool)
+	spent := make(map[string]bool)
+	skipped := make(map[string]bool)
+	for _, b := range buckets {
+		for _, tx := range b.txs {
+			if !canInclude(tx, spent, skipped) {
+				skip(tx, skipped)
+				continue
+			}
+			inputs, outputs := numInputs, numOutputs
+			for _, input := range tx.Body.Inputs {
+				if created[input.Commit] {
+					outputs--
+				} else {
+					inputs++
+				}
+			}
+			outputs += uint64(len(tx.Body.Outputs))
+			kernels := numKernels + uint64(len(tx.Body.Kernels))
+			if consensus.TxBlockWeight(inputs, outputs, kernels) > maxWeight {
+				skip(tx, skipped)
+				continue
+			}
+			for _, input := range tx.Body.Inputs {
+				spent[input.Commit] = true
+				delete(created, input.Commit)
+			}
+			for _, output := range tx.Body.Outputs {
+				created[output.Commit] = true
+			}
+			numInputs, numOutputs, numKernels = inputs, outputs, kernels
+			selected = append(selected, tx)
+		}
+	}
+	return selected
+}
+
+// A transaction can be included if it does not double spend an input and does
+// not spend an output that was left out
+func canInclude(tx core.Transaction, spent, skipped map[string]bool) bool {
+	for _, input := range tx.Body.Inputs {
+		if spent[input.Commit] || skipped[input.Commit] {
+			return false
+		}
+	}
+	return true
+}
+
+func skip(tx core.Transaction, skipped map[string]bool) {
+	for _, output := range tx.Body.Outputs {
+		skipped[output.Commit] = true
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pool
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/blockcypher/libgrin/v5/core"
+	"github.com/blockcypher/libgrin/v5/core/consensus"
+	"github.com/blockcypher/libgrin/v5/libwallet"
+	"github.com/blockcypher/libgrin/v5/util/secp"
+	"github.com/stretchr/testify/assert"
+)
+
+// Value and blinding factor of a test input or output, the blinding factor
+// being the secret key of the given index
+type testAmount struct {
+	value uint64
+	blind byte
+}
+
+func testKey(i byte) secp.SecretKey {
+	return secp.SecretKey{31: i}
+}
+
+func testCommit(t *testing.T, amount testAmount) string {
+	commit, err := secp.Commit(amount.value, testKey(amount.blind))
+	assert.NoError(t, err)
+	return commit.String()
+}
+
+func testOutput(t *testing.T, features core.OutputFeatures, amount testAmount) core.Output {
+	blind := testKey(amount.blind)
+	proof, err := secp.CreateBulletproof(amount.value, blind, blind, blind, nil, nil)
+	assert.NoError(t, err)
+	return core.Output{Features: features, Commit: testCommit(t, amount), Proof: hex.EncodeToString(proof)}
+}
+
+// Signs a kernel with a secret key, the excess being its public key
+func testKernel(t *testing.T, kernel core.TxKernel, secKey secp.SecretKey) core.TxKernel {
+	pubKey, err := secKey.PubKey()
+	assert.NoError(t, err)
+	kernel.Excess = secp.CommitmentFromPubKey(pubKey).String()
+	msg, err := kernel.Message()
+	assert.NoError(t, err)
+	sig, err := secp.SignSingle(msg, secKey)
+	assert.NoError(t, err)
+	kernel.ExcessSig = sig.CompactHex()
+	return kernel
+}
+
+// Builds the pool entry of a valid transaction spending the inputs to the
+// outputs, the difference being the fee
+func testPoolEntry(t *testing.T, offset byte, inputs, outputs []testAmount) PoolEntry {
+	var positive, negative []secp.SecretKey
+	var body core.TransactionBody
+	var fee uint64
+	for _, input := range inputs {
+		body.Inputs = append(body.Inputs, core.Input{Features: core.PlainOutput, Commit: testCommit(t, input)})
+		negative = append(negative, testKey(input.blind))
+		fee += input.value
+	}
+	for _, output := range outputs {
+		body.Outputs = append(body.Outputs, testOutput(t, core.PlainOutput, output))
+		positive = append(positive, testKey(output.blind))
+		fee -= output.value
+	}
+	excess, err := secp.BlindSum(positive, append(negative, testKey(offset)))
+	assert.NoError(t, err)
+	body.Kernels = []core.TxKernel{testKernel(t, core.TxKernel{Features: core.PlainKernel, Fee: core.Uint64(fee)}, excess)}
+	assert.NoError(t, body.Sort())
+	return PoolEntry{Tx: core.Transaction{Offset: testKey(offset).String(), Body: body}}
+}
+
+// Builds a valid coinbase of the block reward
+type testCoinbaseBuilder struct {
+	t         *testing.T
+	blockFees libwallet.BlockFees
+}
+
+func (b *testCoinbaseBuilder) BuildCoinbase(blockFees libwallet.BlockFees) (*libwallet.CbData, error) {
+	b.blockFees = blockFees
+	amount := testAmount{consensus.BlockReward(uint64(blockFees.Fees)), 100}
+	return &libwallet.CbData{
+		Output: testOutput(b.t, core.CoinbaseOutput, amount),
+		Kernel: testKernel(b.t, core.TxKernel{Features: core.CoinbaseKernel}, testKey(amount.blind)),
+	}, nil
+}
+
+// Amounts spent and created by the test transactions
+var (
+	amountA = testAmount{10000000, 1}
+	amountB = testAmount{5000000, 2}
+	amountC = testAmount{amountA.value - amountB.value - 46000, 3}
+	amountD = testAmount{10000000, 4}
+	amountE = testAmount{amountD.value - 100000, 5}
+	amountF = testAmount{amountB.value - 147000, 6}
+	amountG = testAmount{amountD.value - 25, 7}
+)
+
+func TestPrepareMineableTransactions(t *testing.T) {
+	entries := []PoolEntry{
+		// weight 1 + 2 * 21 + 3 = 46, rate 1000
+		testPoolEntry(t, 0, []testAmount{amountA}, []testAmount{amountB, amountC}),
+		// weight 25, rate 4000
+		testPoolEntry(t, 0, []testAmount{amountD}, []testAmount{amountE}),
+		// child of the first one, aggregated weight 1 + 2 * 21 + 6 = 49 after cut-through
+		testPoolEntry(t, 0, []testAmount{amountB}, []testAmount{amountF}),
+		// double spend of the second one, lower rate
+		testPoolEntry(t, 0, []testAmount{amountD}, []testAmount{amountG}),
+	}
+
+	// Everything but the double spend fits
+	txs := PrepareMineableTransactions(entries, 1000)
+	assert.Equal(t, []core.Transaction{entries[1].Tx, entries[0].Tx, entries[2].Tx}, txs)
+
+	// Only the best bucket fits
+	txs = PrepareMineableTransactions(entries, 48)
+	assert.Equal(t, []core.Transaction{entries[1].Tx}, txs)
+}
+
+func TestBuildBlockTemplate(t *testing.T) {
+	amountH := testAmount{amountB.value - 50000, 8}
+	entries := []PoolEntry{
+		testPoolEntry(t, 1, []testAmount{amountA}, []testAmount{amountB, amountC}),
+		testPoolEntry(t, 2, []testAmount{amountB}, []testAmount{amountH}),
+	}
+	builder := &testCoinbaseBuilder{t: t}
+	template, err := BuildBlockTemplate(entries, builder, 42, nil, uint64(consensus.MaxBlockWeight))
+	assert.NoError(t, err)
+	assert.Len(t, template.Txs, 2)
+	assert.Equal(t, uint64(96000), template.Fees)
+	assert.Equal(t, consensus.Reward+96000, template.Reward)
+	assert.Equal(t, testKey(3).String(), template.Offset)
+	assert.Equal(t, core.Uint64(96000), builder.blockFees.Fees)
+	assert.Equal(t, core.Uint64(42), builder.blockFees.Height)
+	// a -> c, h and the coinbase
+	assert.Equal(t, []core.Input{{Features: core.PlainOutput, Commit: testCommit(t, amountA)}}, template.Body.Inputs)
+	var commits []string
+	for _, output := range template.Body.Outputs {
+		commits = append(commits, output.Commit)
+	}
+	assert.ElementsMatch(t, []string{
+		testCommit(t, amountC),
+		testCommit(t, amountH),
+		testCommit(t, testAmount{template.Reward, 100}),
+	}, commits)
+	assert.Len(t, template.Body.Kernels, 3)
+	assert.Equal(t, uint64(1+3*21+3*3), template.Weight)
+
+	// The template is the sorted body of a valid block: the outputs minus the
+	// inputs sum to the kernel excesses plus the offset and the subsidy
+	sorted := core.TransactionBody{
+		Inputs:  append([]core.Input{}, template.Body.Inputs...),
+		Outputs: append([]core.Output{}, template.Body.Outputs...),
+		Kernels: append([]core.TxKernel{}, template.Body.Kernels...),
+	}
+	assert.NoError(t, sorted.Sort())
+	assert.Equal(t, sorted, template.Body)
+	assert.NoError(t, core.VerifyOutputProofs(template.Body.Outputs))
+	assert.NoError(t, core.VerifyKernelSignatures(template.Body.Kernels))
+	var positive, negative []secp.Commitment
+	for _, output := range template.Body.Outputs {
+		commit, err := secp.CommitmentFromHex(output.Commit)
+		assert.NoError(t, err)
+		positive = append(positive, commit)
+	}
+	for _, input := range template.Body.Inputs {
+		commit, err := secp.CommitmentFromHex(input.Commit)
+		assert.NoError(t, err)
+		negative = append(negative, commit)
+	}
+	for _, kernel := range template.Body.Kernels {
+		commit, err := secp.CommitmentFromHex(kernel.Excess)
+		assert.NoError(t, err)
+		negative = append(negative, commit)
+	}
+	subsidy, err := secp.CommitValue(consensus.Reward)
+	assert.NoError(t, err)
+	offset, err := secp.Commit(0, testKey(3))
+	assert.NoError(t, err)
+	assert.True(t, secp.VerifyCommitSum(positive, append(negative, subsidy, offset)))
+
+	_, err = BuildBlockTemplate(entries, builder, 42, nil, 10)
+	assert.Error(t, err)
+}
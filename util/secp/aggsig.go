@@ -0,0 +1,239 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secp
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// SignatureSize is the size of an aggsig signature
+const SignatureSize = 64
+
+// ErrInvalidSignature is returned when a signature can't be parsed
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Signature is an aggsig Schnorr signature in its raw form: the x coordinate
+// of the public nonce R followed by the scalar s, both big-endian. This is
+// the form used in the binary serialization and by the node API printables.
+// The JSON serialization of transactions and slates uses the compact form, in
+// which both halves are byte-reversed.
+type Signature [SignatureSize]byte
+
+// SignatureFromRaw parses a raw signature
+func SignatureFromRaw(b []byte) (Signature, error) {
+	var sig Signature
+	if len(b) != SignatureSize {
+		return sig, ErrInvalidSignature
+	}
+	copy(sig[:], b)
+	return sig, nil
+}
+
+// SignatureFromCompact parses a compact signature
+func SignatureFromCompact(b []byte) (Signature, error) {
+	if len(b) != SignatureSize {
+		return Signature{}, ErrInvalidSignature
+	}
+	return SignatureFromRaw(reverseHalves(b))
+}
+
+// SignatureFromCompactHex parses an hex encoded compact signature, as found in
+// the transaction JSON
+func SignatureFromCompactHex(s string) (Signature, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return Signature{}, err
+	}
+	return SignatureFromCompact(b)
+}
+
+// Compact returns the compact form of the signature
+func (s Signature) Compact() []byte {
+	return reverseHalves(s[:])
+}
+
+// CompactHex returns the hex encoded compact form of the signature
+func (s Signature) CompactHex() string {
+	return hex.EncodeToString(s.Compact())
+}
+
+// String returns the hex encoding of the raw signature
+func (s Signature) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func reverseHalves(b []byte) []byte {
+	reversed := make([]byte, len(b))
+	half := len(b) / 2
+	for i := 0; i < half; i++ {
+		reversed[i] = b[half-1-i]
+		reversed[half+i] = b[len(b)-1-i]
+	}
+	return reversed
+}
+
+// Computes the signature challenge sha256(R.x || P || msg)
+func challenge(rx []byte, pubKey *secp256k1.PublicKey, msg *[32]byte) *secp256k1.ModNScalar {
+	h := sha256.New()
+	h.Write(rx)
+	h.Write(pubKey.SerializeCompressed())
+	h.Write(msg[:])
+	var e secp256k1.ModNScalar
+	e.SetByteSlice(h.Sum(nil))
+	return &e
+}
+
+// Parses the signature R and s, R having the quadratic residue y coordinate
+func (s *Signature) parse(r *secp256k1.JacobianPoint, sc *secp256k1.ModNScalar) bool {
+	var rx secp256k1.FieldVal
+	if overflow := rx.SetByteSlice(s[:32]); overflow {
+		return false
+	}
+	if overflow := sc.SetByteSlice(s[32:]); overflow {
+		return false
+	}
+	return pointFromXQuad(&rx, r)
+}
+
+// SignSingle signs a message with a secret key, the public key being the one
+// of the secret key. The nonce is derived deterministically (RFC6979) from the
+// secret key and the message.
+func SignSingle(msg [32]byte, secKey SecretKey) (Signature, error) {
+	x, err := secKey.scalar()
+	if err != nil {
+		return Signature{}, err
+	}
+	if x.IsZero() {
+		return Signature{}, ErrInvalidSecretKey
+	}
+	pubKey, err := secKey.PubKey()
+	if err != nil {
+		return Signature{}, err
+	}
+	for iteration := uint32(0); ; iteration++ {
+		k := secp256k1.NonceRFC6979(secKey[:], msg[:], nil, nil, iteration)
+		var r secp256k1.JacobianPoint
+		secp256k1.ScalarBaseMultNonConst(k, &r)
+		r.ToAffine()
+		// The public nonce must have a quadratic residue y coordinate
+		if !isQuad(&r.Y) {
+			k.Negate()
+		}
+		var sig Signature
+		r.X.PutBytesUnchecked(sig[:32])
+		e := challenge(sig[:32], pubKey, &msg)
+		// s = k + e*x
+		s := new(secp256k1.ModNScalar).Mul2(e, x).Add(k)
+		if s.IsZero() {
+			continue
+		}
+		s.PutBytesUnchecked(sig[32:])
+		return sig, nil
+	}
+}
+
+// VerifySingle verifies a signature of a message by a public key, the public
+// key also being the one committed to in the challenge, as for transaction
+// kernels
+func VerifySingle(sig Signature, msg [32]byte, pubKey *secp256k1.PublicKey) bool {
+	var r secp256k1.JacobianPoint
+	var s secp256k1.ModNScalar
+	if !sig.parse(&r, &s) {
+		return false
+	}
+	e := challenge(sig[:32], pubKey, &msg)
+
+	// sG - eP must be R
+	var sG, eP, p, rCheck secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&s, &sG)
+	pubKey.AsJacobian(&p)
+	secp256k1.ScalarMultNonConst(e.Negate(), &p, &eP)
+	secp256k1.AddNonConst(&sG, &eP, &rCheck)
+	if isInfinity(&rCheck) {
+		return false
+	}
+	rCheck.ToAffine()
+	return rCheck.X.Equals(&r.X) && isQuad(&rCheck.Y)
+}
+
+// VerifyBatch verifies many signatures at once, each signature being of the
+// message by the public key at the same index. It checks that
+// sum(a_i*s_i)G - sum(a_i*R_i) - sum(a_i*e_i*P_i) is zero with randomizers a_i
+// derived from all the signatures, messages and public keys.
+func VerifyBatch(sigs []Signature, msgs [][32]byte, pubKeys []*secp256k1.PublicKey) bool {
+	if len(sigs) != len(msgs) || len(sigs) != len(pubKeys) {
+		return false
+	}
+	if len(sigs) == 0 {
+		return true
+	}
+
+	seedHasher := sha256.New()
+	for i := range sigs {
+		seedHasher.Write(sigs[i][:])
+		seedHasher.Write(msgs[i][:])
+		seedHasher.Write(pubKeys[i].SerializeCompressed())
+	}
+	seed := seedHasher.Sum(nil)
+
+	var sSum secp256k1.ModNScalar
+	var acc secp256k1.JacobianPoint
+	add := func(k *secp256k1.ModNScalar, point *secp256k1.JacobianPoint) {
+		var term, sum secp256k1.JacobianPoint
+		secp256k1.ScalarMultNonConst(k, point, &term)
+		secp256k1.AddNonConst(&acc, &term, &sum)
+		acc.Set(&sum)
+	}
+	for i := range sigs {
+		var r, p secp256k1.JacobianPoint
+		var s secp256k1.ModNScalar
+		if !sigs[i].parse(&r, &s) {
+			return false
+		}
+		a := batchRandomizer(seed, i)
+		e := challenge(sigs[i][:32], pubKeys[i], &msgs[i])
+
+		sSum.Add(new(secp256k1.ModNScalar).Mul2(a, &s))
+		// -a_i*R_i
+		add(new(secp256k1.ModNScalar).NegateVal(a), &r)
+		// -a_i*e_i*P_i
+		pubKeys[i].AsJacobian(&p)
+		add(new(secp256k1.ModNScalar).Mul2(a, e).Negate(), &p)
+	}
+	var sG, total secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&sSum, &sG)
+	secp256k1.AddNonConst(&acc, &sG, &total)
+	return isInfinity(&total)
+}
+
+// Randomizer of the i-th signature of a batch, the first one is 1
+func batchRandomizer(seed []byte, i int) *secp256k1.ModNScalar {
+	var a secp256k1.ModNScalar
+	if i == 0 {
+		return a.SetInt(1)
+	}
+	var index [8]byte
+	binary.BigEndian.PutUint64(index[:], uint64(i))
+	h := sha256.New()
+	h.Write(seed)
+	h.Write(index[:])
+	a.SetByteSlice(h.Sum(nil))
+	return &a
+}
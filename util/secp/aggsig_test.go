@@ -0,0 +1,101 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secp
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Mainnet genesis coinbase kernel excess signature, in its compact form
+const genesisKernelSig = "142a6a482a0c64ba71ba216ba5361612696fc76fe8d5c03c79fae01cab29d050ed7567e9a6d4fbbc1b6c0bf4434b8450dafa3f29b31612fda815f6e4b2bcfd43"
+
+func genesisKernelSignature(t *testing.T) (Signature, [32]byte, *secp256k1.PublicKey) {
+	sig, err := SignatureFromCompactHex(genesisKernelSig)
+	assert.NoError(t, err)
+	excess, err := CommitmentFromHex(genesisKernelExcess)
+	assert.NoError(t, err)
+	pubKey, err := excess.ToPubKey()
+	assert.NoError(t, err)
+	// Coinbase kernel message is the hash of its feature byte
+	msg := blake2b.Sum256([]byte{1})
+	return sig, msg, pubKey
+}
+
+func TestSignatureEncoding(t *testing.T) {
+	sig, err := SignatureFromCompactHex(genesisKernelSig)
+	assert.NoError(t, err)
+	assert.Equal(t, genesisKernelSig, sig.CompactHex())
+	assert.Equal(t, "50d029ab1ce0fa793cc0d5e86fc76f69121636a56b21ba71ba640c2a486a2a1443fdbcb2e4f615a8fd1216b3293ffada50844b43f40b6c1bbcfbd4a6e96775ed", sig.String())
+
+	_, err = SignatureFromCompactHex("142a6a48")
+	assert.Equal(t, ErrInvalidSignature, err)
+}
+
+func TestVerifySingle(t *testing.T) {
+	sig, msg, pubKey := genesisKernelSignature(t)
+	assert.True(t, VerifySingle(sig, msg, pubKey))
+
+	otherMsg := blake2b.Sum256([]byte{0})
+	assert.False(t, VerifySingle(sig, otherMsg, pubKey))
+	tampered := sig
+	tampered[63] ^= 1
+	assert.False(t, VerifySingle(tampered, msg, pubKey))
+
+	secKey := mustSecretKey(t, "2e8b7b6ad4b2a8f3df6bab3b6e1dc4d5ba7b0a5f3f2c8d1e4b6a7c8d9e0f1a2b")
+	signerPubKey, err := secKey.PubKey()
+	assert.NoError(t, err)
+	msg = sha256.Sum256([]byte("libgrin"))
+	signed, err := SignSingle(msg, secKey)
+	assert.NoError(t, err)
+	assert.True(t, VerifySingle(signed, msg, signerPubKey))
+	assert.False(t, VerifySingle(signed, msg, pubKey))
+
+	_, err = SignSingle(msg, ZeroKey)
+	assert.Equal(t, ErrInvalidSecretKey, err)
+}
+
+func TestVerifyBatch(t *testing.T) {
+	sig, msg, pubKey := genesisKernelSignature(t)
+	sigs := []Signature{sig}
+	msgs := [][32]byte{msg}
+	pubKeys := []*secp256k1.PublicKey{pubKey}
+	for i := byte(1); i <= 4; i++ {
+		secKey := SecretKey{31: i}
+		signerPubKey, err := secKey.PubKey()
+		assert.NoError(t, err)
+		m := sha256.Sum256([]byte{i})
+		s, err := SignSingle(m, secKey)
+		assert.NoError(t, err)
+		sigs = append(sigs, s)
+		msgs = append(msgs, m)
+		pubKeys = append(pubKeys, signerPubKey)
+	}
+	assert.True(t, VerifyBatch(sigs, msgs, pubKeys))
+	assert.True(t, VerifyBatch(nil, nil, nil))
+
+	// Swapping two messages invalidates the batch
+	msgs[1], msgs[2] = msgs[2], msgs[1]
+	assert.False(t, VerifyBatch(sigs, msgs, pubKeys))
+	msgs[1], msgs[2] = msgs[2], msgs[1]
+
+	sigs[3][40] ^= 1
+	assert.False(t, VerifyBatch(sigs, msgs, pubKeys))
+	assert.False(t, VerifyBatch(sigs[:2], msgs, pubKeys))
+}
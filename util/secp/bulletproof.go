@@ -0,0 +1,581 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/bits"
+	"sync"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// MaxProofSize is the size of a grin bulletproof: a 64-bit range proof of a
+// single commitment
+const MaxProofSize = 675
+
+const (
+	// Number of bits proven by a range proof
+	bulletproofBits = 64
+	// Number of generators, as created by grin
+	bulletproofGeneratorsCount = 256
+	// Number of scalars remaining at the end of the inner product argument
+	ipABScalars = 4
+	// Number of L and R rounds of the inner product argument: log2(2n/4)
+	ipRounds = 5
+	// Offset of the inner product argument in the proof, after taux, mu and
+	// the A, S, T1 and T2 points
+	ipOffset = 64 + 1 + 4*32
+	// Offset of the L and R points in the inner product argument, after the
+	// dot product and a1, a2, b1, b2
+	ipLROffset = 32 + ipABScalars*32
+)
+
+// Generator G, used for the blinding factors of the range proofs
+var generatorG = newGeneratorPoint(
+	"79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+	"483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8",
+)
+
+var (
+	bulletproofGens     []secp256k1.JacobianPoint
+	bulletproofGensOnce sync.Once
+)
+
+// The G_i and H_i generators of the range proofs, H_i starting at the middle.
+// They are generated from an RFC6979 HMAC-SHA256 stream seeded with G.
+func bulletproofGenerators() []secp256k1.JacobianPoint {
+	bulletproofGensOnce.Do(func() {
+		var seed [64]byte
+		generatorG.X.PutBytesUnchecked(seed[:32])
+		generatorG.Y.PutBytesUnchecked(seed[32:])
+		rng := newRFC6979HMACSHA256(seed[:])
+		bulletproofGens = make([]secp256k1.JacobianPoint, bulletproofGeneratorsCount)
+		for i := range bulletproofGens {
+			generatorGenerate(rng.generate(), &bulletproofGens[i])
+		}
+	})
+	return bulletproofGens
+}
+
+// RFC6979 HMAC-SHA256 deterministic random generator of secp256k1
+type rfc6979HMACSHA256 struct {
+	k, v  []byte
+	retry bool
+}
+
+func newRFC6979HMACSHA256(key []byte) *rfc6979HMACSHA256 {
+	rng := &rfc6979HMACSHA256{k: make([]byte, 32), v: make([]byte, 32)}
+	for i := range rng.v {
+		rng.v[i] = 1
+	}
+	for _, b := range []byte{0, 1} {
+		rng.k = hmacSHA256(rng.k, rng.v, []byte{b}, key)
+		rng.v = hmacSHA256(rng.k, rng.v)
+	}
+	return rng
+}
+
+func (rng *rfc6979HMACSHA256) generate() []byte {
+	if rng.retry {
+		rng.k = hmacSHA256(rng.k, rng.v, []byte{0})
+		rng.v = hmacSHA256(rng.k, rng.v)
+	}
+	rng.v = hmacSHA256(rng.k, rng.v)
+	rng.retry = true
+	out := make([]byte, 32)
+	copy(out, rng.v)
+	return out
+}
+
+func hmacSHA256(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+func hexToFieldVal(s string) *secp256k1.FieldVal {
+	b, _ := hex.DecodeString(s)
+	var f secp256k1.FieldVal
+	f.SetByteSlice(b)
+	return &f
+}
+
+var (
+	// sqrt(-3)
+	swuC = hexToFieldVal("0a2d2ba93507f1df233770c2a797962cc61f6d15da14ecd47d8d27ae1cd5f852")
+	// (sqrt(-3) - 1) / 2
+	swuD = hexToFieldVal("851695d49a83f8ef919bb86153cbcb16630fb68aed0a766a3ec693d68e6afa40")
+)
+
+// Maps a field element to a curve point with the Shallue-van de Woestijne
+// encoding, as secp256k1-zkp does
+func shallueVanDeWoestijne(t *secp256k1.FieldVal, p *secp256k1.JacobianPoint) {
+	var wn, wd, tmp, x1n, x2n, x3n, x3d, jinv secp256k1.FieldVal
+	wn.Mul2(swuC, t).Normalize()
+	wd.SquareVal(t).AddInt(8).Normalize()
+	tmp.Mul2(t, &wn).Negate(1).Normalize()
+	x1n.Mul2(swuD, &wd).Add(&tmp).Normalize()
+	x2n.Set(&x1n).Add(&wd).Negate(2).Normalize()
+	x3d.Mul2(swuC, t).Square().Normalize()
+	x3n.SquareVal(&wd).Add(&x3d).Normalize()
+	jinv.Mul2(&x3d, &wd).Inverse().Normalize()
+
+	var x1, x2, x3 secp256k1.FieldVal
+	x1.Mul2(&x1n, &x3d).Mul(&jinv).Normalize()
+	x2.Mul2(&x2n, &x3d).Mul(&jinv).Normalize()
+	x3.Mul2(&x3n, &wd).Mul(&jinv).Normalize()
+
+	var alpha, beta, gamma, y1, y2, y3 secp256k1.FieldVal
+	alpha.SquareVal(&x1).Mul(&x1).AddInt(7).Normalize()
+	beta.SquareVal(&x2).Mul(&x2).AddInt(7).Normalize()
+	gamma.SquareVal(&x3).Mul(&x3).AddInt(7).Normalize()
+	alphaQuad := y1.SquareRootVal(&alpha)
+	betaQuad := y2.SquareRootVal(&beta)
+	y3.SquareRootVal(&gamma)
+
+	switch {
+	case alphaQuad:
+		p.X.Set(&x1)
+		p.Y.Set(&y1)
+	case betaQuad:
+		p.X.Set(&x2)
+		p.Y.Set(&y2)
+	default:
+		p.X.Set(&x3)
+		p.Y.Set(&y3)
+	}
+	p.Y.Normalize()
+	// The sign of y follows the oddness of t
+	if t.IsOdd() {
+		p.Y.Negate(1).Normalize()
+	}
+	p.Z.SetInt(1)
+}
+
+// Generates a NUMS generator from a 32 bytes key, as
+// secp256k1_generator_generate does
+func generatorGenerate(key []byte, p *secp256k1.JacobianPoint) {
+	var points [2]secp256k1.JacobianPoint
+	for i, prefix := range []string{"1st generation: ", "2nd generation: "} {
+		h := sha256.New()
+		h.Write([]byte(prefix))
+		h.Write(key)
+		var t secp256k1.FieldVal
+		t.SetByteSlice(h.Sum(nil))
+		shallueVanDeWoestijne(&t, &points[i])
+	}
+	secp256k1.AddNonConst(&points[0], &points[1], p)
+	p.ToAffine()
+}
+
+// Deserializes the i-th of n points serialized as a bit vector of the y
+// coordinates signs followed by the x coordinates
+func deserializeBulletproofPoint(data []byte, i, n int, p *secp256k1.JacobianPoint) bool {
+	offset := (n+7)/8 + i*32
+	var x secp256k1.FieldVal
+	x.SetByteSlice(data[offset : offset+32])
+	if !pointFromXQuad(&x, p) {
+		return false
+	}
+	if data[i/8]&(1<<(i%8)) != 0 {
+		p.Y.Negate(1).Normalize()
+	}
+	return true
+}
+
+// Updates the Fiat-Shamir commitment with two affine points
+func updateBulletproofCommit(commit *[32]byte, l, r *secp256k1.JacobianPoint) {
+	var parity byte
+	if !isQuad(&l.Y) {
+		parity = 2
+	}
+	if !isQuad(&r.Y) {
+		parity++
+	}
+	h := sha256.New()
+	h.Write(commit[:])
+	h.Write([]byte{parity})
+	var x [32]byte
+	l.X.PutBytesUnchecked(x[:])
+	h.Write(x[:])
+	r.X.PutBytesUnchecked(x[:])
+	h.Write(x[:])
+	h.Sum(commit[:0])
+}
+
+// Parses a non overflowing and non zero scalar
+func nonZeroScalar(b []byte, s *secp256k1.ModNScalar) bool {
+	overflow := s.SetByteSlice(b)
+	return !overflow && !s.IsZero()
+}
+
+// State of the verification of a single range proof
+type bulletproofVerifier struct {
+	// Range proof
+	v, a, s, t1, t2             secp256k1.JacobianPoint
+	y, z, x, yinv, t, random61  secp256k1.ModNScalar
+	gExponent, zRandomized, zsq secp256k1.ModNScalar
+	commit                      [32]byte
+	pOffs                       secp256k1.ModNScalar
+
+	// Inner product argument
+	ip         []byte
+	randomizer secp256k1.ModNScalar
+	abinv      [ipABScalars]secp256k1.ModNScalar
+	xsq        [ipRounds]secp256k1.ModNScalar
+	xsqinv     [ipRounds]secp256k1.ModNScalar
+	xsqinvy    [ipRounds]secp256k1.ModNScalar
+	xcache     [ipRounds + 3]secp256k1.ModNScalar
+	xsqinvMask secp256k1.ModNScalar
+}
+
+// Parses the range proof and computes its challenges
+func (bp *bulletproofVerifier) init(commit *Commitment, proof, extraData []byte) bool {
+	if len(proof) != MaxProofSize {
+		return false
+	}
+	if err := commit.load(&bp.v); err != nil {
+		return false
+	}
+
+	var c [32]byte
+	bulletproofInitialCommit(&c, &bp.v, extraData)
+
+	// Compute y, z, x
+	if !deserializeBulletproofPoint(proof[64:], 0, 4, &bp.a) ||
+		!deserializeBulletproofPoint(proof[64:], 1, 4, &bp.s) {
+		return false
+	}
+	updateBulletproofCommit(&c, &bp.a, &bp.s)
+	if !nonZeroScalar(c[:], &bp.y) {
+		return false
+	}
+	updateBulletproofCommit(&c, &bp.a, &bp.s)
+	if !nonZeroScalar(c[:], &bp.z) {
+		return false
+	}
+	if !deserializeBulletproofPoint(proof[64:], 2, 4, &bp.t1) ||
+		!deserializeBulletproofPoint(proof[64:], 3, 4, &bp.t2) {
+		return false
+	}
+	updateBulletproofCommit(&c, &bp.t1, &bp.t2)
+	if !nonZeroScalar(c[:], &bp.x) {
+		return false
+	}
+	bp.yinv.InverseValNonConst(&bp.y)
+
+	// Commit to taux and mu, the randomizer of the polynomial check is then
+	// derived from the commitment
+	h := sha256.New()
+	h.Write(c[:])
+	h.Write(proof[:64])
+	h.Sum(c[:0])
+	random61 := sha256.Sum256(c[:])
+	if !nonZeroScalar(random61[:], &bp.random61) {
+		return false
+	}
+
+	var taux, mu secp256k1.ModNScalar
+	if !nonZeroScalar(proof[:32], &taux) || !nonZeroScalar(proof[32:64], &mu) {
+		return false
+	}
+	// t is read from the front of the inner product argument
+	if !nonZeroScalar(proof[ipOffset:ipOffset+32], &bp.t) {
+		return false
+	}
+	bp.pOffs.Mul2(&taux, &bp.random61).Add(&mu)
+	bp.commit = c
+	bp.ip = proof[ipOffset:]
+	return true
+}
+
+// Computes the inner product argument challenges, returning the offset of
+// the blinding generator of the proof
+func (bp *bulletproofVerifier) initInnerProduct(seed *[32]byte, pOffs *secp256k1.ModNScalar) bool {
+	ip := bp.ip
+	var dot secp256k1.ModNScalar
+	if overflow := dot.SetByteSlice(ip[:32]); overflow {
+		return false
+	}
+	h := sha256.New()
+	h.Write(bp.commit[:])
+	h.Write(ip[:32])
+	var proofCommit [32]byte
+	h.Sum(proofCommit[:0])
+
+	var ab [ipABScalars]secp256k1.ModNScalar
+	for j := range ab {
+		if !nonZeroScalar(ip[32+32*j:64+32*j], &ab[j]) {
+			return false
+		}
+	}
+	// a1*b1 + a2*b2
+	var abDot secp256k1.ModNScalar
+	abDot.Mul2(&ab[0], &ab[2]).Add(new(secp256k1.ModNScalar).Mul2(&ab[1], &ab[3]))
+
+	*seed = sha256.Sum256(seed[:])
+	if !nonZeroScalar(seed[:], &bp.randomizer) {
+		return false
+	}
+
+	// r*(x*(dot - a.b) + p_offs)
+	var x secp256k1.ModNScalar
+	if !nonZeroScalar(proofCommit[:], &x) {
+		return false
+	}
+	x.Mul(abDot.Negate().Add(&dot)).Add(&bp.pOffs).Mul(&bp.randomizer)
+	pOffs.Set(&x)
+
+	// r*x_1*...*x_n in the last slot of ab
+	b2 := ab[ipABScalars-1]
+	ab[ipABScalars-1].Set(&bp.randomizer)
+	lr := ip[ipLROffset:]
+	const bitVecLen = (2*ipRounds + 7) / 8
+	for j := 0; j < ipRounds; j++ {
+		lidx, ridx := 2*j, 2*j+1
+		var parity byte
+		if lr[lidx/8]&(1<<(lidx%8)) != 0 {
+			parity = 2
+		}
+		if lr[ridx/8]&(1<<(ridx%8)) != 0 {
+			parity++
+		}
+		h := sha256.New()
+		h.Write(proofCommit[:])
+		h.Write([]byte{parity})
+		h.Write(lr[bitVecLen+32*lidx : bitVecLen+32*lidx+32])
+		h.Write(lr[bitVecLen+32*ridx : bitVecLen+32*ridx+32])
+		h.Sum(proofCommit[:0])
+		var xi secp256k1.ModNScalar
+		if !nonZeroScalar(proofCommit[:], &xi) {
+			return false
+		}
+		ab[ipABScalars-1].Mul(&xi)
+		bp.xsq[j].SquareVal(&xi)
+	}
+	for j := range ab {
+		bp.abinv[j].InverseValNonConst(&ab[j])
+	}
+	ab[ipABScalars-1] = b2
+
+	// (-a1*r*x_1*...*x_n)^-1 masks out the individual x_i^-2
+	bp.xsqinvMask.NegateVal(&bp.abinv[0]).Mul(&bp.abinv[ipABScalars-1])
+
+	// Ratios used to switch from a1 to a2, from a2 to b1 and from b1 to b2
+	for j := ipABScalars - 1; j > 0; j-- {
+		prev := j & (j - 1)
+		if j == ipABScalars/2 {
+			prev = j - 1
+		}
+		bp.abinv[j-1].Mul2(&bp.abinv[prev], &ab[j])
+	}
+
+	// First coefficient: -a1*r*(x_1*...*x_n)^-1
+	var ra secp256k1.ModNScalar
+	ra.Mul2(&bp.randomizer, &ab[0]).Square()
+	bp.xcache[0].Mul2(&bp.xsqinvMask, &ra)
+	return true
+}
+
+// Scalar of the G_i (idx < n) and H_i (n <= idx < 2n) generators for this
+// proof, idx must be iterated in order
+func (bp *bulletproofVerifier) generatorScalar(idx int, sc *secp256k1.ModNScalar) {
+	const n = bulletproofBits
+	const grouping = ipABScalars / 2
+	const lgGrouping = 1
+
+	// Inner product argument
+	cacheIdx := bits.OnesCount(uint(idx))
+	if cacheIdx > 0 {
+		switch {
+		case idx%(n/grouping) == 0:
+			abinvIdx := idx/(n/grouping) - 1
+			prevCacheIdx := cacheIdx - 1
+			if idx == n {
+				// The H_i are really y^-i*H_i, multiply the x_k^-2 by
+				// y^-(2^k) to get the powers of y^-1 in the right places
+				yinvn := bp.yinv
+				prevCacheIdx = bits.OnesCount(uint(idx - 1))
+				for j := 0; j < bits.TrailingZeros(uint(idx))-lgGrouping; j++ {
+					bp.xsqinvy[j].Mul2(&bp.xsqinv[j], &yinvn)
+					yinvn.Square()
+				}
+				bp.abinv[2].Mul(&yinvn)
+			}
+			bp.xcache[cacheIdx].Mul2(&bp.xcache[prevCacheIdx], &bp.abinv[abinvIdx])
+		case idx < n:
+			bp.xcache[cacheIdx].Mul2(&bp.xcache[cacheIdx-1], &bp.xsq[bits.TrailingZeros(uint(idx))])
+		default:
+			bp.xcache[cacheIdx].Mul2(&bp.xcache[cacheIdx-1], &bp.xsqinvy[bits.TrailingZeros(uint(idx))])
+		}
+	}
+	sc.Set(&bp.xcache[cacheIdx])
+	// Compute the x_k^-2 along the G_i scalars
+	if idx < n/grouping && cacheIdx == ipRounds-1 {
+		bp.xsqinv[bits.TrailingZeros(^uint(idx))].Mul2(&bp.xcache[cacheIdx], &bp.xsqinvMask)
+	}
+
+	// Range proof
+	if idx == 0 {
+		bp.gExponent.NegateVal(&bp.z).Mul(&bp.randomizer)
+		bp.zRandomized.Mul2(&bp.z, &bp.randomizer)
+	}
+	if idx < n {
+		sc.Add(&bp.gExponent)
+		return
+	}
+	if idx == n {
+		bp.zsq.SquareVal(&bp.z).Mul(&bp.randomizer)
+	}
+	sc.Add(&bp.zsq).Add(&bp.zRandomized)
+	bp.zsq.Mul(&bp.yinv)
+	bp.zsq.Add(&bp.zsq)
+}
+
+// Scalar of the value generator H for this proof:
+// r*r61*[(z - z^2)*sum(y^i) - z^3*sum(2^i) - t]
+func (bp *bulletproofVerifier) valueGeneratorScalar(sc *secp256k1.ModNScalar) {
+	var one, yn, twon secp256k1.ModNScalar
+	one.SetInt(1)
+	for j := 0; j < bulletproofBits; j++ {
+		yn.Mul(&bp.y).Add(&one)
+		twon.Add(&twon).Add(&one)
+	}
+	var zsq, twosum secp256k1.ModNScalar
+	zsq.SquareVal(&bp.z)
+	twosum.Mul2(&zsq, &bp.z).Negate().Mul(&twon)
+
+	sc.NegateVal(&zsq).Add(&bp.z).Mul(&yn).Add(&twosum)
+	sc.Add(new(secp256k1.ModNScalar).NegateVal(&bp.t))
+	sc.Mul(&bp.random61).Mul(&bp.randomizer)
+}
+
+// Verifies the range proofs at once: the sum of all the scalar
+// multiplications derived from the proofs must be the point at infinity
+func verifyBulletproofs(verifiers []bulletproofVerifier) bool {
+	var seed [32]byte
+	h := sha256.New()
+	for i := range verifiers {
+		bp := &verifiers[i]
+		h.Write(bp.ip)
+		h.Write(bp.commit[:])
+		pOffs := bp.pOffs.Bytes()
+		h.Write(pOffs[:])
+	}
+	h.Sum(seed[:0])
+
+	var gScalar secp256k1.ModNScalar
+	for i := range verifiers {
+		var pOffs secp256k1.ModNScalar
+		if !verifiers[i].initInnerProduct(&seed, &pOffs) {
+			return false
+		}
+		gScalar.Add(&pOffs)
+	}
+
+	var acc secp256k1.JacobianPoint
+	add := func(sc *secp256k1.ModNScalar, p *secp256k1.JacobianPoint) {
+		var term, sum secp256k1.JacobianPoint
+		secp256k1.ScalarMultNonConst(sc, p, &term)
+		secp256k1.AddNonConst(&acc, &term, &sum)
+		acc.Set(&sum)
+	}
+
+	// G_i and H_i, shared by all the proofs
+	gens := bulletproofGenerators()
+	for idx := 0; idx < 2*bulletproofBits; idx++ {
+		var sc secp256k1.ModNScalar
+		for i := range verifiers {
+			var term secp256k1.ModNScalar
+			verifiers[i].generatorScalar(idx, &term)
+			sc.Add(&term)
+		}
+		gen := &gens[idx]
+		if idx >= bulletproofBits {
+			gen = &gens[bulletproofGeneratorsCount/2+idx-bulletproofBits]
+		}
+		add(&sc, gen)
+	}
+
+	var hScalar secp256k1.ModNScalar
+	for i := range verifiers {
+		bp := &verifiers[i]
+		// L and R
+		for j := 0; j < 2*ipRounds; j++ {
+			var p secp256k1.JacobianPoint
+			if !deserializeBulletproofPoint(bp.ip[ipLROffset:], j, 2*ipRounds, &p) {
+				return false
+			}
+			sc := bp.xsq[j/2]
+			if j%2 == 1 {
+				sc = bp.xsqinv[j/2]
+			}
+			add(sc.Mul(&bp.randomizer), &p)
+		}
+
+		var sc secp256k1.ModNScalar
+		bp.valueGeneratorScalar(&sc)
+		hScalar.Add(&sc)
+
+		// A, S^x, T1^(x*r61), T2^(x^2*r61) and V^(z^2*r61)
+		add(&bp.randomizer, &bp.a)
+		add(sc.Mul2(&bp.x, &bp.randomizer), &bp.s)
+		add(sc.Mul2(&bp.x, &bp.random61).Mul(&bp.randomizer), &bp.t1)
+		add(sc.SquareVal(&bp.x).Mul(&bp.random61).Mul(&bp.randomizer), &bp.t2)
+		add(sc.SquareVal(&bp.z).Mul(&bp.random61).Mul(&bp.randomizer), &bp.v)
+	}
+	h2 := generatorH
+	add(&hScalar, &h2)
+
+	var gTerm, total secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&gScalar, &gTerm)
+	secp256k1.AddNonConst(&acc, &gTerm, &total)
+	return isInfinity(&total)
+}
+
+// VerifyBulletproof verifies that the range proof proves that the commitment
+// commits to a 64-bit value. The extra data must be the one committed to when
+// creating the proof, nil for the outputs of grin.
+func VerifyBulletproof(commit Commitment, proof, extraData []byte) bool {
+	return VerifyBulletproofs([]Commitment{commit}, [][]byte{proof}, [][]byte{extraData})
+}
+
+// VerifyBulletproofs batch verifies range proofs, each proof being of the
+// commitment at the same index, which is faster than verifying them one by
+// one. The extra data can be nil if no proof commits to extra data.
+func VerifyBulletproofs(commits []Commitment, proofs [][]byte, extraData [][]byte) bool {
+	if len(commits) != len(proofs) || (extraData != nil && len(extraData) != len(proofs)) {
+		return false
+	}
+	if len(proofs) == 0 {
+		return true
+	}
+	verifiers := make([]bulletproofVerifier, len(proofs))
+	for i := range proofs {
+		var extra []byte
+		if extraData != nil {
+			extra = extraData[i]
+		}
+		if !verifiers[i].init(&commits[i], proofs[i], extra) {
+			return false
+		}
+	}
+	return verifyBulletproofs(verifiers)
+}
@@ -0,0 +1,425 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secp
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"golang.org/x/crypto/chacha20"
+)
+
+// ProofMessageSize is the size of the message embedded in a range proof
+const ProofMessageSize = 20
+
+var (
+	// ErrProofCreation is returned when a range proof can't be created
+	ErrProofCreation = errors.New("range proof creation failed")
+	// ErrProofRewind is returned when a range proof can't be rewound, most
+	// likely because it wasn't created with the rewind nonce
+	ErrProofRewind = errors.New("range proof rewind failed")
+)
+
+// ProofMessage is the message embedded in a range proof along with the value,
+// it is recovered when rewinding the proof
+type ProofMessage [ProofMessageSize]byte
+
+// ProofInfo is the information recovered when rewinding a range proof
+type ProofInfo struct {
+	// Value committed to
+	Value uint64
+	// Blinding factor of the commitment, if the proof has no private nonce
+	Blind SecretKey
+	// Message embedded in the proof
+	Message ProofMessage
+}
+
+// Derives two scalars from a seed and an index as secp256k1_scalar_chacha20
+// does: a ChaCha20 block keyed with the seed, with the index as block counter
+// and the number of retries on overflow in the nonce
+func scalarChaCha20(seed *SecretKey, idx uint64, r1, r2 *secp256k1.ModNScalar) {
+	var nonce [chacha20.NonceSize]byte
+	binary.LittleEndian.PutUint32(nonce[:4], uint32(idx>>32))
+	for overCount := uint32(0); ; overCount++ {
+		binary.LittleEndian.PutUint32(nonce[8:], overCount)
+		cipher, _ := chacha20.NewUnauthenticatedCipher(seed[:], nonce[:])
+		cipher.SetCounter(uint32(idx))
+		var block [64]byte
+		cipher.XORKeyStream(block[:], block[:])
+		overflow1 := r1.SetByteSlice(block[:32])
+		overflow2 := r2.SetByteSlice(block[32:])
+		if !overflow1 && !overflow2 {
+			return
+		}
+	}
+}
+
+// Serializes affine points as a bit vector of the y coordinates signs
+// followed by the x coordinates
+func serializeBulletproofPoints(out []byte, points []secp256k1.JacobianPoint) {
+	bitVecLen := (len(points) + 7) / 8
+	for i := 0; i < bitVecLen; i++ {
+		out[i] = 0
+	}
+	for i := range points {
+		points[i].X.PutBytesUnchecked(out[bitVecLen+32*i : bitVecLen+32*i+32])
+		if !isQuad(&points[i].Y) {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+}
+
+// Adds p to the accumulator
+func addPoint(acc, p *secp256k1.JacobianPoint) {
+	var sum secp256k1.JacobianPoint
+	secp256k1.AddNonConst(acc, p, &sum)
+	acc.Set(&sum)
+}
+
+// Adds sc*p to the accumulator
+func addScalarMult(acc *secp256k1.JacobianPoint, sc *secp256k1.ModNScalar, p *secp256k1.JacobianPoint) {
+	var term secp256k1.JacobianPoint
+	secp256k1.ScalarMultNonConst(sc, p, &term)
+	addPoint(acc, &term)
+}
+
+// State of the creation of a single range proof
+type bulletproofProver struct {
+	value  uint64
+	sl, sr [bulletproofBits]secp256k1.ModNScalar
+	y, z   secp256k1.ModNScalar
+}
+
+// Evaluates the l and r vector polynomials at x:
+// l_i = v_i - z + sl_i*x and r_i = y^i*(v_i - 1 + z + sr_i*x) + z^2*2^i
+func (bp *bulletproofProver) lr(x *secp256k1.ModNScalar, l, r []secp256k1.ModNScalar) {
+	var yn, z22n, negz secp256k1.ModNScalar
+	yn.SetInt(1)
+	z22n.SquareVal(&bp.z)
+	negz.NegateVal(&bp.z)
+	for i := 0; i < bulletproofBits; i++ {
+		bit := uint32(bp.value>>i) & 1
+		var sl, sr secp256k1.ModNScalar
+		sl.Mul2(&bp.sl[i], x)
+		sr.Mul2(&bp.sr[i], x)
+		l[i].SetInt(bit).Add(&negz).Add(&sl)
+		r[i].SetInt(1 - bit).Negate().Add(&bp.z).Add(&sr).Mul(&yn).Add(&z22n)
+		yn.Mul(&bp.y)
+		z22n.Add(&z22n)
+	}
+}
+
+// Inner product of two vectors of scalars
+func innerProduct(a, b []secp256k1.ModNScalar) secp256k1.ModNScalar {
+	var dot secp256k1.ModNScalar
+	for i := range a {
+		dot.Add(new(secp256k1.ModNScalar).Mul2(&a[i], &b[i]))
+	}
+	return dot
+}
+
+// Proves the inner product of a and b on the generators G_i and y^-i*H_i,
+// writing the argument to the proof. The vectors are overwritten.
+func proveInnerProduct(proof []byte, a, b []secp256k1.ModNScalar, yinv *secp256k1.ModNScalar, commit [32]byte) bool {
+	n := len(a)
+	gens := bulletproofGenerators()
+	g := make([]secp256k1.JacobianPoint, n)
+	h := make([]secp256k1.JacobianPoint, n)
+	var yinvn secp256k1.ModNScalar
+	yinvn.SetInt(1)
+	for i := 0; i < n; i++ {
+		g[i] = gens[i]
+		secp256k1.ScalarMultNonConst(&yinvn, &gens[bulletproofGeneratorsCount/2+i], &h[i])
+		yinvn.Mul(yinv)
+	}
+
+	// Hash the dot product to get the randomizer of G
+	dot := innerProduct(a, b)
+	dot.PutBytesUnchecked(proof[:32])
+	h256 := sha256.New()
+	h256.Write(commit[:])
+	h256.Write(proof[:32])
+	h256.Sum(commit[:0])
+	var ux secp256k1.ModNScalar
+	if !nonZeroScalar(commit[:], &ux) {
+		return false
+	}
+
+	// Halve the vectors until ipABScalars scalars remain, committing to
+	// L = a_even.G_odd + b_odd.H_even + ux*<a_even, b_odd>*G and
+	// R = a_odd.G_even + b_even.H_odd + ux*<a_odd, b_even>*G
+	var lr [2 * ipRounds]secp256k1.JacobianPoint
+	for round, half := 0, n/2; half >= ipABScalars/2; round, half = round+1, half/2 {
+		l, r := &lr[2*round], &lr[2*round+1]
+		var lDot, rDot secp256k1.ModNScalar
+		for j := 0; j < half; j++ {
+			addScalarMult(l, &a[2*j], &g[2*j+1])
+			addScalarMult(l, &b[2*j+1], &h[2*j])
+			lDot.Add(new(secp256k1.ModNScalar).Mul2(&a[2*j], &b[2*j+1]))
+			addScalarMult(r, &a[2*j+1], &g[2*j])
+			addScalarMult(r, &b[2*j], &h[2*j+1])
+			rDot.Add(new(secp256k1.ModNScalar).Mul2(&a[2*j+1], &b[2*j]))
+		}
+		addScalarMult(l, lDot.Mul(&ux), &generatorG)
+		addScalarMult(r, rDot.Mul(&ux), &generatorG)
+		l.ToAffine()
+		r.ToAffine()
+
+		updateBulletproofCommit(&commit, l, r)
+		var x, xinv secp256k1.ModNScalar
+		if !nonZeroScalar(commit[:], &x) {
+			return false
+		}
+		xinv.InverseValNonConst(&x)
+
+		// a' = x*a_even + x^-1*a_odd, b' = x^-1*b_even + x*b_odd and the
+		// generators accordingly so that L and R are consistent
+		for j := 0; j < half; j++ {
+			var s secp256k1.ModNScalar
+			a[j] = *s.Mul2(&a[2*j], &x).Add(new(secp256k1.ModNScalar).Mul2(&a[2*j+1], &xinv))
+			b[j] = *s.Mul2(&b[2*j], &xinv).Add(new(secp256k1.ModNScalar).Mul2(&b[2*j+1], &x))
+
+			var gj, hj secp256k1.JacobianPoint
+			addScalarMult(&gj, &xinv, &g[2*j])
+			addScalarMult(&gj, &x, &g[2*j+1])
+			addScalarMult(&hj, &x, &h[2*j])
+			addScalarMult(&hj, &xinv, &h[2*j+1])
+			g[j], h[j] = gj, hj
+		}
+	}
+
+	for j := 0; j < ipABScalars/2; j++ {
+		a[j].PutBytesUnchecked(proof[32+32*j : 64+32*j])
+		b[j].PutBytesUnchecked(proof[32+32*(j+ipABScalars/2) : 64+32*(j+ipABScalars/2)])
+	}
+	serializeBulletproofPoints(proof[ipLROffset:], lr[:])
+	return true
+}
+
+// Commits to the Pedersen commitment, value generator and extra data
+func bulletproofInitialCommit(commit *[32]byte, v *secp256k1.JacobianPoint, extraData []byte) {
+	updateBulletproofCommit(commit, v, &generatorH)
+	if extraData != nil {
+		h := sha256.New()
+		h.Write(commit[:])
+		h.Write(extraData)
+		h.Sum(commit[:0])
+	}
+}
+
+// CreateBulletproof creates a range proof that the commitment of the value
+// with the blinding factor commits to a 64-bit value, as secp256k1-zkp does.
+// The value and the optional message are recoverable by rewinding the proof
+// with the rewind nonce, while the private nonce must remain secret. The extra
+// data, nil for the outputs of grin, is committed to by the proof.
+func CreateBulletproof(value uint64, blind, rewindNonce, privateNonce SecretKey, extraData []byte, message *ProofMessage) ([]byte, error) {
+	blindSc, err := blind.scalar()
+	if err != nil {
+		return nil, err
+	}
+	if blindSc.IsZero() {
+		return nil, ErrInvalidSecretKey
+	}
+	if _, err := rewindNonce.scalar(); err != nil {
+		return nil, err
+	}
+	if _, err := privateNonce.scalar(); err != nil {
+		return nil, err
+	}
+
+	var v, vH secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(blindSc, &v)
+	valueMultH(value, &vH)
+	addPoint(&v, &vH)
+	if isInfinity(&v) {
+		return nil, ErrInvalidCommit
+	}
+	v.ToAffine()
+	var commit [32]byte
+	bulletproofInitialCommit(&commit, &v, extraData)
+
+	bp := bulletproofProver{value: value}
+	var alpha, rho, tau1, tau2 secp256k1.ModNScalar
+	scalarChaCha20(&rewindNonce, 0, &alpha, &rho)
+	scalarChaCha20(&privateNonce, 1, &tau1, &tau2)
+
+	// Encrypt the value and message into alpha, so that they are recoverable
+	// from mu by whoever knows the rewind nonce
+	var valsBytes [32]byte
+	binary.BigEndian.PutUint64(valsBytes[24:], value)
+	if message != nil {
+		copy(valsBytes[4:24], message[:])
+	}
+	var vals secp256k1.ModNScalar
+	vals.SetBytes(&valsBytes)
+	alpha.Add(vals.Negate())
+
+	// A = alpha*G + sum(v_i ? G_i : -H_i), S = rho*G + sum(sl_i*G_i + sr_i*H_i)
+	gens := bulletproofGenerators()
+	var points [4]secp256k1.JacobianPoint
+	a, s := &points[0], &points[1]
+	secp256k1.ScalarBaseMultNonConst(&alpha, a)
+	secp256k1.ScalarBaseMultNonConst(&rho, s)
+	for i := 0; i < bulletproofBits; i++ {
+		gi := &gens[i]
+		hi := &gens[bulletproofGeneratorsCount/2+i]
+		if value>>i&1 == 1 {
+			addPoint(a, gi)
+		} else {
+			var negH secp256k1.JacobianPoint
+			negH.Set(hi)
+			negH.Y.Negate(1).Normalize()
+			addPoint(a, &negH)
+		}
+		scalarChaCha20(&rewindNonce, uint64(i)+2, &bp.sl[i], &bp.sr[i])
+		addScalarMult(s, &bp.sl[i], gi)
+		addScalarMult(s, &bp.sr[i], hi)
+	}
+	a.ToAffine()
+	s.ToAffine()
+
+	// Challenges y and z
+	updateBulletproofCommit(&commit, a, s)
+	if !nonZeroScalar(commit[:], &bp.y) {
+		return nil, ErrProofCreation
+	}
+	updateBulletproofCommit(&commit, a, s)
+	if !nonZeroScalar(commit[:], &bp.z) {
+		return nil, ErrProofCreation
+	}
+
+	// Coefficients of t(x) = <l(x), r(x)> = t0 + t1*x + t2*x^2, from its
+	// values at 0, 1 and -1
+	var l, r [bulletproofBits]secp256k1.ModNScalar
+	var zero, one, negOne secp256k1.ModNScalar
+	one.SetInt(1)
+	negOne.NegateVal(&one)
+	bp.lr(&zero, l[:], r[:])
+	t0 := innerProduct(l[:], r[:])
+	bp.lr(&one, l[:], r[:])
+	tOne := innerProduct(l[:], r[:])
+	bp.lr(&negOne, l[:], r[:])
+	tNegOne := innerProduct(l[:], r[:])
+	var half, t1, t2 secp256k1.ModNScalar
+	half.SetInt(2).InverseNonConst()
+	t1.NegateVal(&tNegOne).Add(&tOne).Mul(&half)
+	t2.NegateVal(&t0).Add(&tNegOne).Add(&t1)
+
+	// T1 = t1*H + tau1*G, T2 = t2*H + tau2*G and challenge x
+	t1p, t2p := &points[2], &points[3]
+	secp256k1.ScalarBaseMultNonConst(&tau1, t1p)
+	addScalarMult(t1p, &t1, &generatorH)
+	secp256k1.ScalarBaseMultNonConst(&tau2, t2p)
+	addScalarMult(t2p, &t2, &generatorH)
+	t1p.ToAffine()
+	t2p.ToAffine()
+	updateBulletproofCommit(&commit, t1p, t2p)
+	var x secp256k1.ModNScalar
+	if !nonZeroScalar(commit[:], &x) {
+		return nil, ErrProofCreation
+	}
+
+	// taux = tau1*x + tau2*x^2 + z^2*blind and mu = rho*x + alpha, negated
+	// so that the verifier doesn't have to
+	var taux, mu, tmp secp256k1.ModNScalar
+	taux.Mul2(&tau1, &x)
+	taux.Add(tmp.SquareVal(&x).Mul(&tau2))
+	taux.Add(tmp.SquareVal(&bp.z).Mul(blindSc))
+	taux.Negate()
+	mu.Mul2(&rho, &x).Add(&alpha).Negate()
+
+	proof := make([]byte, MaxProofSize)
+	taux.PutBytesUnchecked(proof[:32])
+	mu.PutBytesUnchecked(proof[32:64])
+	serializeBulletproofPoints(proof[64:], points[:])
+
+	h := sha256.New()
+	h.Write(commit[:])
+	h.Write(proof[:64])
+	h.Sum(commit[:0])
+
+	bp.lr(&x, l[:], r[:])
+	var yinv secp256k1.ModNScalar
+	yinv.InverseValNonConst(&bp.y)
+	if !proveInnerProduct(proof[ipOffset:], l[:], r[:], &yinv, commit) {
+		return nil, ErrProofCreation
+	}
+	return proof, nil
+}
+
+// RewindBulletproof recovers the value, blinding factor and message of a range
+// proof created with the rewind nonce, as secp256k1-zkp does. The blinding
+// factor is only correct if the private nonce was the rewind nonce too. The
+// proof itself isn't verified.
+func RewindBulletproof(commit Commitment, proof []byte, rewindNonce SecretKey, extraData []byte) (ProofInfo, error) {
+	var info ProofInfo
+	if len(proof) != MaxProofSize {
+		return info, ErrProofRewind
+	}
+	var taux, mu secp256k1.ModNScalar
+	if !nonZeroScalar(proof[:32], &taux) || !nonZeroScalar(proof[32:64], &mu) {
+		return info, ErrProofRewind
+	}
+	if _, err := rewindNonce.scalar(); err != nil {
+		return info, err
+	}
+	var v secp256k1.JacobianPoint
+	if err := commit.load(&v); err != nil {
+		return info, err
+	}
+
+	var alpha, rho, tau1, tau2 secp256k1.ModNScalar
+	scalarChaCha20(&rewindNonce, 0, &alpha, &rho)
+	scalarChaCha20(&rewindNonce, 1, &tau1, &tau2)
+
+	// Recompute the challenges z and x from A, S, T1 and T2
+	var c [32]byte
+	bulletproofInitialCommit(&c, &v, extraData)
+	var points [4]secp256k1.JacobianPoint
+	for i := range points {
+		if !deserializeBulletproofPoint(proof[64:], i, len(points), &points[i]) {
+			return info, ErrProofRewind
+		}
+	}
+	var z, x secp256k1.ModNScalar
+	updateBulletproofCommit(&c, &points[0], &points[1])
+	updateBulletproofCommit(&c, &points[0], &points[1])
+	if !nonZeroScalar(c[:], &z) {
+		return info, ErrProofRewind
+	}
+	updateBulletproofCommit(&c, &points[2], &points[3])
+	if !nonZeroScalar(c[:], &x) {
+		return info, ErrProofRewind
+	}
+
+	// -mu + rho*x + alpha leaves the value and message
+	mu.Add(rho.Mul(&x)).Add(&alpha)
+	vals := mu.Bytes()
+	if vals[0] != 0 || vals[1] != 0 || vals[2] != 0 || vals[3] != 0 {
+		return info, ErrProofRewind
+	}
+	info.Value = binary.BigEndian.Uint64(vals[24:])
+	copy(info.Message[:], vals[4:24])
+
+	// blind = -(-taux + tau1*x + tau2*x^2)/z^2
+	var tmp secp256k1.ModNScalar
+	taux.Add(tau1.Mul(&x))
+	taux.Add(tmp.SquareVal(&x).Mul(&tau2))
+	taux.Mul(tmp.SquareVal(&z).InverseNonConst()).Negate()
+	info.Blind = secretKeyFromScalar(&taux)
+	return info, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secp
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// Range proofs created by secp256k1-zkp with the keys and message below
+const (
+	proveTestBlind        = "0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f20"
+	proveTestRewindNonce  = "404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f"
+	proveTestPrivateNonce = "808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f"
+	proveTestMessage      = "000001131415161718191a1b1c1d1e1f20212223"
+
+	// 12345678 with the message and without extra data
+	proveTestCommit = "08ca7978ad680023598766456627b0f074cafeb96f5a0b9f6cd259c61dced083fb"
+	proveTestProof  = "d2e999a6f7ffd2eabc03d0553c1f8b8753707c11285a8e7a31f03581e8338cfa9efa6343622a89b96d7aecd9c9e8c77544ba5ba4c20d07aa34c82449ef5b2c0b05aa452c9779bc0b70a5e7b7568a443be32b716e18d4d68c21e067683126ef98c0233859c5b3bb821e2bd02b965269b3126ebe1b0deb330477d3c21deccb36157caaf3631f568d98aaf5a1ee8db03fe2b2b0c8e3c767805027fd844c12d0d5ee7347c1da02d65a37afca675dfde4cfd50c966029ce3722b134cf887c2c01a027a1d7819d0cfed78a8563b254c8889c8cfea4a7f2bf4571de4b7e5eda9bd27de7d110f8bae526737e358b3eaf3052dccb75981df888e31177a945730724c240ccc944ca39d1e5c64ec40922d27f42fca5176fd48b39508ae209210e98bafdb718ca895b7f2270217d71ce20d8607e8e6885964c881fdc90a74c0f1c63fecacf5c6ec341b8ad3b652ed9960edd3207d150f6dfc43cb4ba0f4d35584cef765b1192ed650275347cec6b310f4f2d719f09a97096ae4a0ae5e2f17fd79cd27481084e090d47bf637d6304aceb53b5bfe67c22c994c6e396a87319942b2c55572cf97c5dcbff974cb77ed8b7006cb4dde56773804ed2b69aa5107da8b2d16462b4625357321a53b83165886b70538f9b36eefcf700ce55abad56de5a1524dc57a906f8454efd4505c4990b77af88d272a4b3e31f351ff4bcf83ced8fc0f134a41aed7793b9898369fa8fcae104f069ffebd257912a57559654adcf53ffa13e12755ac3f5d0156f870ebf72846b0c16ea76adfee0d660d79ba018bbed51852b2f304a3dfa195069d8ff385b695483a2fbc03aec8b5d8206616613ecce06a750b47b97481ea6a5af8456f4ada2b300c92abaf830363f927f38e1bde736f578e08b67084fc817b846d95fbfaec133e46247d109e7310bfe606ac5845b45d01e90a8689449f154bf"
+	// 0 without message and with "grin" as extra data
+	proveTestZeroCommit = "0884bf7562262bbd6940085748f3be6afa52ae317155181ece31b66351ccffa4b0"
+	proveTestZeroProof  = "2ffa0c721c14c39fdc6e1f7ad3828e0e788d8d28aa6f12f7d8e173b464b3c0d87455382db0e35afa73596935f94021a1e44722934b05b5ce54c70a780b1eacad087aaeb5a896b15507b66303c5e1163c31dad091750e814f6e65a1dad968b137c4233859c5b3bb821e2bd02b965269b3126ebe1b0deb330477d3c21deccb36157ce8c23c895e324bcc6cdfe9bdcb4838f49ea8c9a84f0712511971ad37ea7d1dd82ee2bc19c2906aa8a31c1b7865eec2caff9482e2efcdeaee05ed57ce97441efbc57a1a4bb4fb83bcca5d354401b00a4d13299e34d5add9497581051e9c9f27b7769a63eb5eebb799405041d60777252c48721399a83471281e247582f0ca8c3815d19cb84c799bacccde1a6786ab457cd5fa86dc6680dcfc64b1bdec49ac2115bd1b83fad4eea6448be64008a56e57d39da1e4522c90bcec103f6df102dc08ca48190345e32badfaffadd0afe9f346c680dcada4c42f0b7d8813ee07a8bed07d4b00e8957360779caec57ed711434f1096a3f48f66a3533e982eaf666d7190a53b8652ae8cdf8218c0acfb9b5b27f866c629b24bc6b713b63fd62ba4d5b4355394eedba936fb06fc37ae693a4079302fa817bb9851af5d84ece7d7d64e8f9016fca22acdd09a097a2617d176655d0548c8f19f5760ac055cfd327e405086645110b8f96bb95be140df3491c300c4cacbe6bb1da898952690ba75f49685925c5fb75bbdc0da85d2075f22e5cf23f46ddfc05bd7c07c6154bd5853a2328111a855541f1f96e5258e50d81ffed0f14a42ae61e3e67c2365501b4389f8ece724c5664c2e5babdc86de35bd54eac4fc65be148d5fb68b7aa7b9efebd55da28cc9049f86e70e266f24d311c99ca6ee04fad3eef08832b3d1e9241465d53f457fcdf0656cbd9f71a3c78a813d302721cea54c6165f9619b80cad08965026d202978beec10cc"
+)
+
+func proveTestKeys(t *testing.T) (SecretKey, SecretKey, SecretKey, ProofMessage) {
+	blind := mustSecretKey(t, proveTestBlind)
+	rewindNonce := mustSecretKey(t, proveTestRewindNonce)
+	privateNonce := mustSecretKey(t, proveTestPrivateNonce)
+	var message ProofMessage
+	b, err := hex.DecodeString(proveTestMessage)
+	assert.NoError(t, err)
+	copy(message[:], b)
+	return blind, rewindNonce, privateNonce, message
+}
+
+func TestScalarChaCha20(t *testing.T) {
+	var r1, r2 secp256k1.ModNScalar
+	var seed SecretKey
+	scalarChaCha20(&seed, 0, &r1, &r2)
+	assert.Equal(t, "76b8e0ada0f13d90405d6ae55386bd28bdd219b8a08ded1aa836efcc8b770dc7", secretKeyFromScalar(&r1).String())
+	assert.Equal(t, "da41597c5157488d7724e03fb8d84a376a43b8f41518a11cc387b669b2ee6586", secretKeyFromScalar(&r2).String())
+
+	seed[31] = 1
+	scalarChaCha20(&seed, 0, &r1, &r2)
+	assert.Equal(t, "4540f05a9f1fb296d7736e7b208e3c96eb4fe1834688d2604f450952ed432d41", secretKeyFromScalar(&r1).String())
+	assert.Equal(t, "bbe2a0b6ea7566d2a5d1e7e20d42af2c53d792b1c43fea817e9ad275ae546963", secretKeyFromScalar(&r2).String())
+
+	scalarChaCha20(&seed, 100, &r1, &r2)
+	assert.Equal(t, "474a4f354fee9359bb6581e5d915a601b68c680338ff65e6564a3e6559fc123f", secretKeyFromScalar(&r1).String())
+	assert.Equal(t, "a9b2f93e57c3a5cbe0727427881c23dfe2b6ccfb93edcb02d75052458488bbea", secretKeyFromScalar(&r2).String())
+}
+
+func TestCreateBulletproof(t *testing.T) {
+	blind, rewindNonce, privateNonce, message := proveTestKeys(t)
+
+	proof, err := CreateBulletproof(12345678, blind, rewindNonce, privateNonce, nil, &message)
+	assert.NoError(t, err)
+	assert.Equal(t, proveTestProof, hex.EncodeToString(proof))
+	commit, err := Commit(12345678, blind)
+	assert.NoError(t, err)
+	assert.Equal(t, proveTestCommit, commit.String())
+	assert.True(t, VerifyBulletproof(commit, proof, nil))
+
+	proof, err = CreateBulletproof(0, blind, rewindNonce, privateNonce, []byte("grin"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, proveTestZeroProof, hex.EncodeToString(proof))
+	commit, err = Commit(0, blind)
+	assert.NoError(t, err)
+	assert.Equal(t, proveTestZeroCommit, commit.String())
+	assert.True(t, VerifyBulletproof(commit, proof, []byte("grin")))
+	assert.False(t, VerifyBulletproof(commit, proof, nil))
+
+	// The largest value is in range too
+	proof, err = CreateBulletproof(^uint64(0), blind, rewindNonce, privateNonce, nil, nil)
+	assert.NoError(t, err)
+	commit, err = Commit(^uint64(0), blind)
+	assert.NoError(t, err)
+	assert.True(t, VerifyBulletproof(commit, proof, nil))
+
+	_, err = CreateBulletproof(1, ZeroKey, rewindNonce, privateNonce, nil, nil)
+	assert.Equal(t, ErrInvalidSecretKey, err)
+}
+
+func TestRewindBulletproof(t *testing.T) {
+	blind, rewindNonce, privateNonce, message := proveTestKeys(t)
+	commit, err := CommitmentFromHex(proveTestCommit)
+	assert.NoError(t, err)
+	proof, err := hex.DecodeString(proveTestProof)
+	assert.NoError(t, err)
+
+	info, err := RewindBulletproof(commit, proof, rewindNonce, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(12345678), info.Value)
+	assert.Equal(t, message, info.Message)
+
+	commit, err = CommitmentFromHex(proveTestZeroCommit)
+	assert.NoError(t, err)
+	proof, err = hex.DecodeString(proveTestZeroProof)
+	assert.NoError(t, err)
+	info, err = RewindBulletproof(commit, proof, rewindNonce, []byte("grin"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), info.Value)
+	assert.Equal(t, ProofMessage{}, info.Message)
+
+	// The blinding factor is recovered when there is no private nonce
+	selfProof, err := CreateBulletproof(42, blind, rewindNonce, rewindNonce, nil, &message)
+	assert.NoError(t, err)
+	selfCommit, err := Commit(42, blind)
+	assert.NoError(t, err)
+	info, err = RewindBulletproof(selfCommit, selfProof, rewindNonce, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ProofInfo{Value: 42, Blind: blind, Message: message}, info)
+
+	// Only the rewind nonce, with the same commitment and extra data, can
+	// rewind the proof
+	_, err = RewindBulletproof(commit, proof, privateNonce, []byte("grin"))
+	assert.Equal(t, ErrProofRewind, err)
+	_, err = RewindBulletproof(commit, proof, rewindNonce, nil)
+	assert.Equal(t, ErrProofRewind, err)
+	genesisCommit, genesisProof := genesisBulletproof(t)
+	_, err = RewindBulletproof(genesisCommit, genesisProof, rewindNonce, nil)
+	assert.Equal(t, ErrProofRewind, err)
+	_, err = RewindBulletproof(commit, proof[:MaxProofSize-1], rewindNonce, []byte("grin"))
+	assert.Equal(t, ErrProofRewind, err)
+}
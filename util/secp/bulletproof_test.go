@@ -0,0 +1,83 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secp
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Mainnet genesis coinbase output range proof
+const genesisOutputProof = "9330ad8cde205f317c6537eca96b866293a0489615a9a277b4d3a597c873544c82474932b641e06ac8719604ee52e895e8cd4621b6bfb85780cd9becce14d0700b83a664db2f52a26c425fd777ad88944cdfff38043a2793ed4d9aa67e36cbfd5585579fc69dda930418af5eaf603654f6f751258d2dfc8c2113c171e130f31ec1e6cce2a718e435298fce5d64ffe1bd3464fd7c87cfa92093855be034bfe4439e928bd92ad77fd0a0e00355ee1d1a9ceb1ed0c408dcfdba8c583e7598dc700aaa9f91432097259a405f5b7315a2f7658861e3349bb0dc8bf883726a215f0149ded6613e5ac0670c0c5202247d7c27c8a7d03bdb03c9cf5455463f9b42cf87403e31f8383cc4f49a34c62ae459f5801a9eed4f0ee3dfd5f55b7011c0cae393c474abd6f8c7965b9b5fff3104dd4e39542077c0c8dd2f8ffceb6bb598512d90506d0a7184f20f1498cf458787f23284b54888c9be416d103f760406357a16b6d841a303d5c95b6b474d2d7f0fea0a2a76c897dd2110e9303f54684169421147684c6f1819c33cef3f38ec995a508450c02cd1872f8065fdee723109c18b1dd2ddde75825546ecf0df0793c353b20c946cd64122cea8c116f432336899a16ad24a2aafcb8f900e09a1147135fcf2a54cbf81db308a47a08a49c77c130e5dc5e661cd55a5cc69e607055a5b08111bf61a62ea5778f85119043633f1cab8c756d756c5a34851024ac311a596b1cd919bbca43226f0ba057f6b57de2f6955b0823c3826de7f6096c1c1b6b9b8e4063e1645c0bff32f80561aaa959d97120fbc2ecd9d2be28bd0c17811dc59a88049f6d8952ee9a0a0207693c89ca3ad1197e9bfdfc03be9d845aea8d663969217e3b494cee9e652bc9f8713e2fd5cb1843848f46c3a6ab024d0e3d57ca45454cdbda414adaa835fa147deb4ffb7129cf3a8d86726a0144794"
+
+func genesisBulletproof(t *testing.T) (Commitment, []byte) {
+	commit, err := CommitmentFromHex(genesisOutputCommit)
+	assert.NoError(t, err)
+	proof, err := hex.DecodeString(genesisOutputProof)
+	assert.NoError(t, err)
+	return commit, proof
+}
+
+func TestVerifyBulletproof(t *testing.T) {
+	commit, proof := genesisBulletproof(t)
+	assert.Len(t, proof, MaxProofSize)
+	assert.True(t, VerifyBulletproof(commit, proof, nil))
+}
+
+func TestVerifyBulletproofTampered(t *testing.T) {
+	commit, proof := genesisBulletproof(t)
+
+	// taux, mu, A, T1, t, a1, L and the last R
+	for _, i := range []int{0, 40, 70, 170, 200, 250, 400, MaxProofSize - 1} {
+		tampered := make([]byte, len(proof))
+		copy(tampered, proof)
+		tampered[i] ^= 1
+		assert.False(t, VerifyBulletproof(commit, tampered, nil), "byte %d", i)
+	}
+	assert.False(t, VerifyBulletproof(commit, proof[:MaxProofSize-1], nil))
+	assert.False(t, VerifyBulletproof(commit, append(proof, 0), nil))
+
+	// Commitment and extra data are committed to
+	excess, err := CommitmentFromHex(genesisKernelExcess)
+	assert.NoError(t, err)
+	assert.False(t, VerifyBulletproof(excess, proof, nil))
+	assert.False(t, VerifyBulletproof(commit, proof, []byte{}))
+	assert.False(t, VerifyBulletproof(commit, proof, []byte("grin")))
+}
+
+func TestVerifyBulletproofs(t *testing.T) {
+	commit, proof := genesisBulletproof(t)
+	commits := []Commitment{commit, commit, commit}
+	proofs := [][]byte{proof, proof, proof}
+	assert.True(t, VerifyBulletproofs(commits, proofs, nil))
+	assert.True(t, VerifyBulletproofs(commits, proofs, [][]byte{nil, nil, nil}))
+	assert.True(t, VerifyBulletproofs(nil, nil, nil))
+
+	tampered := make([]byte, len(proof))
+	copy(tampered, proof)
+	tampered[300] ^= 1
+	proofs[1] = tampered
+	assert.False(t, VerifyBulletproofs(commits, proofs, nil))
+
+	excess, err := CommitmentFromHex(genesisKernelExcess)
+	assert.NoError(t, err)
+	proofs[1] = proof
+	commits[2] = excess
+	assert.False(t, VerifyBulletproofs(commits, proofs, nil))
+	assert.False(t, VerifyBulletproofs(commits[:2], proofs, nil))
+	assert.False(t, VerifyBulletproofs(commits[:2], proofs[:2], [][]byte{nil}))
+}
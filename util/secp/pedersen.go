@@ -0,0 +1,239 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// PedersenCommitmentSize is the size of a serialized Pedersen commitment
+const PedersenCommitmentSize = 33
+
+var (
+	// ErrInvalidCommit is returned when a commitment can't be parsed
+	ErrInvalidCommit = errors.New("invalid commitment")
+	// ErrIncorrectCommitSum is returned when commitments sum to the point
+	// at infinity, which has no commitment encoding
+	ErrIncorrectCommitSum = errors.New("incorrect commitment sum")
+)
+
+// Generator H used for the values in Pedersen commitments. This is the sha256
+// of 'g' after DER encoding (without compression), which happens to be a point
+// on the curve.
+var generatorH = newGeneratorPoint(
+	"50929b74c1a04954b78b4b6035e97a5e078a5a0f28ec96d547bfee9ace803ac0",
+	"31d3c6863973926e049e637cb1b5f40a36dac28af1766968c30c2313f3a38904",
+)
+
+func newGeneratorPoint(x, y string) secp256k1.JacobianPoint {
+	var p secp256k1.JacobianPoint
+	xb, _ := hex.DecodeString(x)
+	yb, _ := hex.DecodeString(y)
+	p.X.SetByteSlice(xb)
+	p.Y.SetByteSlice(yb)
+	p.Z.SetInt(1)
+	return p
+}
+
+// Commitment is a Pedersen commitment blind*G + value*H. Its serialization is
+// the x coordinate prefixed by 0x08 if y is a quadratic residue and 0x09
+// otherwise.
+type Commitment [PedersenCommitmentSize]byte
+
+// CommitmentFromBytes parses a serialized commitment
+func CommitmentFromBytes(b []byte) (Commitment, error) {
+	var commit Commitment
+	if len(b) != PedersenCommitmentSize {
+		return commit, ErrInvalidCommit
+	}
+	copy(commit[:], b)
+	var p secp256k1.JacobianPoint
+	if err := commit.load(&p); err != nil {
+		return commit, err
+	}
+	return commit, nil
+}
+
+// CommitmentFromHex parses an hex encoded commitment
+func CommitmentFromHex(s string) (Commitment, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return Commitment{}, err
+	}
+	return CommitmentFromBytes(b)
+}
+
+// String returns the hex encoding of the commitment
+func (c Commitment) String() string {
+	return hex.EncodeToString(c[:])
+}
+
+// Loads the commitment point
+func (c *Commitment) load(p *secp256k1.JacobianPoint) error {
+	if c[0]&0xfe != 8 {
+		return ErrInvalidCommit
+	}
+	var x secp256k1.FieldVal
+	if overflow := x.SetByteSlice(c[1:]); overflow {
+		return ErrInvalidCommit
+	}
+	if !pointFromXQuad(&x, p) {
+		return ErrInvalidCommit
+	}
+	if c[0]&1 == 1 {
+		p.Y.Negate(1).Normalize()
+	}
+	return nil
+}
+
+// Saves a point as a commitment, the point must not be the point at infinity
+func commitmentFromPoint(p *secp256k1.JacobianPoint) Commitment {
+	var commit Commitment
+	p.ToAffine()
+	p.X.PutBytesUnchecked(commit[1:])
+	commit[0] = 9
+	if isQuad(&p.Y) {
+		commit[0] = 8
+	}
+	return commit
+}
+
+// Commit creates a Pedersen commitment to a value with a blinding factor:
+// blind*G + value*H
+func Commit(value uint64, blind SecretKey) (Commitment, error) {
+	b, err := blind.scalar()
+	if err != nil {
+		return Commitment{}, err
+	}
+	var bG, vH, r secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(b, &bG)
+	valueMultH(value, &vH)
+	secp256k1.AddNonConst(&bG, &vH, &r)
+	if isInfinity(&r) {
+		return Commitment{}, ErrInvalidCommit
+	}
+	return commitmentFromPoint(&r), nil
+}
+
+// CommitValue creates a Pedersen commitment to a value with a zero blinding
+// factor: value*H
+func CommitValue(value uint64) (Commitment, error) {
+	return Commit(value, ZeroKey)
+}
+
+// Computes value*H
+func valueMultH(value uint64, result *secp256k1.JacobianPoint) {
+	var b [32]byte
+	binary.BigEndian.PutUint64(b[24:], value)
+	var v secp256k1.ModNScalar
+	v.SetBytes(&b)
+	h := generatorH
+	secp256k1.ScalarMultNonConst(&v, &h, result)
+}
+
+// Sums the positive commitments and subtracts the negative ones
+func sumCommitments(positive, negative []Commitment, result *secp256k1.JacobianPoint) error {
+	var acc secp256k1.JacobianPoint
+	add := func(commit *Commitment, negate bool) error {
+		var p, sum secp256k1.JacobianPoint
+		if err := commit.load(&p); err != nil {
+			return err
+		}
+		if negate {
+			p.Y.Negate(1).Normalize()
+		}
+		secp256k1.AddNonConst(&acc, &p, &sum)
+		acc.Set(&sum)
+		return nil
+	}
+	for i := range negative {
+		if err := add(&negative[i], true); err != nil {
+			return err
+		}
+	}
+	for i := range positive {
+		if err := add(&positive[i], false); err != nil {
+			return err
+		}
+	}
+	result.Set(&acc)
+	return nil
+}
+
+// CommitSum sums the positive commitments and subtracts the negative ones.
+// A sum to zero can't be represented and returns ErrIncorrectCommitSum.
+func CommitSum(positive, negative []Commitment) (Commitment, error) {
+	var sum secp256k1.JacobianPoint
+	if err := sumCommitments(positive, negative, &sum); err != nil {
+		return Commitment{}, err
+	}
+	if isInfinity(&sum) {
+		return Commitment{}, ErrIncorrectCommitSum
+	}
+	return commitmentFromPoint(&sum), nil
+}
+
+// VerifyCommitSum verifies that the positive commitments minus the negative
+// ones sum to zero
+func VerifyCommitSum(positive, negative []Commitment) bool {
+	var sum secp256k1.JacobianPoint
+	if err := sumCommitments(positive, negative, &sum); err != nil {
+		return false
+	}
+	return isInfinity(&sum)
+}
+
+// BlindSum sums the positive blinding factors and subtracts the negative ones
+// modulo the curve order
+func BlindSum(positive, negative []SecretKey) (SecretKey, error) {
+	var acc secp256k1.ModNScalar
+	for _, blind := range negative {
+		s, err := blind.scalar()
+		if err != nil {
+			return ZeroKey, err
+		}
+		acc.Add(s)
+	}
+	acc.Negate()
+	for _, blind := range positive {
+		s, err := blind.scalar()
+		if err != nil {
+			return ZeroKey, err
+		}
+		acc.Add(s)
+	}
+	return secretKeyFromScalar(&acc), nil
+}
+
+// ToPubKey converts the commitment to a public key, used to verify the kernel
+// excess signatures
+func (c Commitment) ToPubKey() (*secp256k1.PublicKey, error) {
+	var p secp256k1.JacobianPoint
+	if err := c.load(&p); err != nil {
+		return nil, err
+	}
+	return secp256k1.NewPublicKey(&p.X, &p.Y), nil
+}
+
+// CommitmentFromPubKey converts a public key to a commitment
+func CommitmentFromPubKey(pubKey *secp256k1.PublicKey) Commitment {
+	var p secp256k1.JacobianPoint
+	pubKey.AsJacobian(&p)
+	return commitmentFromPoint(&p)
+}
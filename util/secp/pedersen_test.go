@@ -0,0 +1,132 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secp
+
+import (
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mainnet genesis coinbase output commitment and kernel excess
+const (
+	genesisOutputCommit = "08b7e57c448db5ef25aa119dde2312c64d7ff1b890c416c6dda5ec73cbfed2edea"
+	genesisKernelExcess = "096385d86c5cfda718aa0b7295be0adf7e5ac051edfe130593a2a257f09f78a3b1"
+	genesisReward       = 60000000000
+)
+
+func mustSecretKey(t *testing.T, s string) SecretKey {
+	key, err := SecretKeyFromHex(s)
+	assert.NoError(t, err)
+	return key
+}
+
+func TestCommitValue(t *testing.T) {
+	// value*H with a zero blinding factor is H itself for 1
+	commit, err := CommitValue(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "0950929b74c1a04954b78b4b6035e97a5e078a5a0f28ec96d547bfee9ace803ac0", commit.String())
+
+	// blind*G with a zero value is the public key of the blinding factor
+	blind := mustSecretKey(t, "0000000000000000000000000000000000000000000000000000000000000001")
+	commit, err = Commit(0, blind)
+	assert.NoError(t, err)
+	assert.Equal(t, "0879be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", commit.String())
+	pubKey, err := commit.ToPubKey()
+	assert.NoError(t, err)
+	assert.True(t, pubKey.IsEqual(secp256k1.NewPrivateKey(new(secp256k1.ModNScalar).SetInt(1)).PubKey()))
+	assert.Equal(t, commit, CommitmentFromPubKey(pubKey))
+
+	// zero commitment
+	_, err = CommitValue(0)
+	assert.Equal(t, ErrInvalidCommit, err)
+}
+
+func TestGenesisKernelSum(t *testing.T) {
+	output, err := CommitmentFromHex(genesisOutputCommit)
+	assert.NoError(t, err)
+	excess, err := CommitmentFromHex(genesisKernelExcess)
+	assert.NoError(t, err)
+	assert.Equal(t, genesisOutputCommit, output.String())
+
+	// outputs - reward*H = kernel excess + offset*G, with a zero offset
+	reward, err := CommitValue(genesisReward)
+	assert.NoError(t, err)
+	sum, err := CommitSum([]Commitment{output}, []Commitment{reward})
+	assert.NoError(t, err)
+	assert.Equal(t, excess, sum)
+	assert.True(t, VerifyCommitSum([]Commitment{output}, []Commitment{reward, excess}))
+	assert.False(t, VerifyCommitSum([]Commitment{output}, []Commitment{excess}))
+
+	_, err = CommitSum([]Commitment{output}, []Commitment{reward, excess})
+	assert.Equal(t, ErrIncorrectCommitSum, err)
+}
+
+func TestCommitSum(t *testing.T) {
+	blind1 := mustSecretKey(t, "2e8b7b6ad4b2a8f3df6bab3b6e1dc4d5ba7b0a5f3f2c8d1e4b6a7c8d9e0f1a2b")
+	blind2 := mustSecretKey(t, "4f1e2d3c4b5a69788796a5b4c3d2e1f00f1e2d3c4b5a69788796a5b4c3d2e1f0")
+
+	commit1, err := Commit(3, blind1)
+	assert.NoError(t, err)
+	commit2, err := Commit(2, blind2)
+	assert.NoError(t, err)
+
+	// (3, blind1) - (2, blind2) = (1, blind1 - blind2)
+	diffBlind, err := BlindSum([]SecretKey{blind1}, []SecretKey{blind2})
+	assert.NoError(t, err)
+	expected, err := Commit(1, diffBlind)
+	assert.NoError(t, err)
+	diff, err := CommitSum([]Commitment{commit1}, []Commitment{commit2})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, diff)
+
+	// (3, blind1) + (2, blind2) = (5, blind1 + blind2)
+	sumBlind, err := BlindSum([]SecretKey{blind1, blind2}, nil)
+	assert.NoError(t, err)
+	expected, err = Commit(5, sumBlind)
+	assert.NoError(t, err)
+	sum, err := CommitSum([]Commitment{commit1, commit2}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, sum)
+
+	// Adding a commitment to itself doubles it
+	double, err := CommitSum([]Commitment{commit1, commit1}, nil)
+	assert.NoError(t, err)
+	doubleBlind, err := BlindSum([]SecretKey{blind1, blind1}, nil)
+	assert.NoError(t, err)
+	expected, err = Commit(6, doubleBlind)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, double)
+
+	zero, err := BlindSum([]SecretKey{blind1}, []SecretKey{blind1})
+	assert.NoError(t, err)
+	assert.True(t, zero.IsZero())
+}
+
+func TestParseCommitment(t *testing.T) {
+	_, err := CommitmentFromHex("02b7e57c448db5ef25aa119dde2312c64d7ff1b890c416c6dda5ec73cbfed2edea")
+	assert.Equal(t, ErrInvalidCommit, err)
+	_, err = CommitmentFromHex("08b7e57c")
+	assert.Equal(t, ErrInvalidCommit, err)
+	// x not on the curve
+	_, err = CommitmentFromHex("080000000000000000000000000000000000000000000000000000000000000005")
+	assert.Equal(t, ErrInvalidCommit, err)
+
+	_, err = SecretKeyFromHex("ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	assert.Equal(t, ErrInvalidSecretKey, err)
+	_, err = ZeroKey.PubKey()
+	assert.Equal(t, ErrInvalidSecretKey, err)
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 BlockCypher
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secp implements the secp256k1-zkp primitives used by grin (Pedersen
+// commitments, aggregated signatures and bulletproofs) on top of the secp256k1
+// field and group arithmetic of github.com/decred/dcrd/dcrec/secp256k1. All
+// the encodings are bit-for-bit compatible with secp256k1-zkp.
+package secp
+
+import (
+	"encoding/hex"
+	"errors"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// SecretKeySize is the size of a secret key (or blinding factor)
+const SecretKeySize = 32
+
+var (
+	// ErrInvalidSecretKey is returned when a secret key is not a valid
+	// scalar (above the curve order)
+	ErrInvalidSecretKey = errors.New("invalid secret key")
+	// ErrInvalidPublicKey is returned when a public key can't be parsed
+	ErrInvalidPublicKey = errors.New("invalid public key")
+)
+
+// SecretKey is a secret key or blinding factor: a 32 bytes big-endian scalar
+// modulo the curve order. Unlike secp256k1 secret keys it can be zero, as the
+// kernel offset of a transaction without offset.
+type SecretKey [SecretKeySize]byte
+
+// ZeroKey is the zero secret key
+var ZeroKey SecretKey
+
+// SecretKeyFromHex parses an hex encoded secret key
+func SecretKeyFromHex(s string) (SecretKey, error) {
+	var key SecretKey
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return key, err
+	}
+	if len(b) != SecretKeySize {
+		return key, ErrInvalidSecretKey
+	}
+	copy(key[:], b)
+	if _, err := key.scalar(); err != nil {
+		return key, err
+	}
+	return key, nil
+}
+
+// String returns the hex encoding of the secret key
+func (k SecretKey) String() string {
+	return hex.EncodeToString(k[:])
+}
+
+// IsZero is whether the secret key is zero
+func (k SecretKey) IsZero() bool {
+	return k == ZeroKey
+}
+
+// PubKey computes the public key of the secret key, which must not be zero
+func (k SecretKey) PubKey() (*secp256k1.PublicKey, error) {
+	s, err := k.scalar()
+	if err != nil {
+		return nil, err
+	}
+	if s.IsZero() {
+		return nil, ErrInvalidSecretKey
+	}
+	var p secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(s, &p)
+	p.ToAffine()
+	return secp256k1.NewPublicKey(&p.X, &p.Y), nil
+}
+
+func (k SecretKey) scalar() (*secp256k1.ModNScalar, error) {
+	var s secp256k1.ModNScalar
+	if overflow := s.SetBytes((*[32]byte)(&k)); overflow != 0 {
+		return nil, ErrInvalidSecretKey
+	}
+	return &s, nil
+}
+
+func secretKeyFromScalar(s *secp256k1.ModNScalar) SecretKey {
+	return SecretKey(s.Bytes())
+}
+
+// Whether the point is the point at infinity
+func isInfinity(p *secp256k1.JacobianPoint) bool {
+	return (p.X.IsZero() && p.Y.IsZero()) || p.Z.IsZero()
+}
+
+// Whether the field element is a quadratic residue (has a square root)
+func isQuad(f *secp256k1.FieldVal) bool {
+	var root secp256k1.FieldVal
+	return root.SquareRootVal(f)
+}
+
+// Sets the point from its x coordinate, picking the y coordinate which is a
+// quadratic residue
+func pointFromXQuad(x *secp256k1.FieldVal, p *secp256k1.JacobianPoint) bool {
+	// y^2 = x^3 + 7
+	var y2 secp256k1.FieldVal
+	y2.SquareVal(x).Mul(x).AddInt(7).Normalize()
+	var y secp256k1.FieldVal
+	if !y.SquareRootVal(&y2) {
+		return false
+	}
+	y.Normalize()
+	if !isQuad(&y) {
+		y.Negate(1).Normalize()
+	}
+	p.X.Set(x)
+	p.Y.Set(&y)
+	p.Z.SetInt(1)
+	return true
+}